@@ -7,13 +7,18 @@ import (
 	"os/signal"
 	"syscall"
 
+	"github.com/gxravel/bus-routes/internal/api/amqp"
 	"github.com/gxravel/bus-routes/internal/api/http/handler"
+	"github.com/gxravel/bus-routes/internal/apikey"
 	"github.com/gxravel/bus-routes/internal/busroutes"
+	"github.com/gxravel/bus-routes/internal/busroutescontext"
 	"github.com/gxravel/bus-routes/internal/config"
 	"github.com/gxravel/bus-routes/internal/database"
 	"github.com/gxravel/bus-routes/internal/dataprovider/mysql"
 	"github.com/gxravel/bus-routes/internal/jwt"
 	log "github.com/gxravel/bus-routes/internal/logger"
+	"github.com/gxravel/bus-routes/internal/mail"
+	"github.com/gxravel/bus-routes/internal/outbox"
 	"github.com/gxravel/bus-routes/internal/storage"
 
 	_ "github.com/go-sql-driver/mysql"
@@ -67,6 +72,16 @@ func main() {
 
 	txer := mysql.NewTxManager(db)
 
+	tokenManager, err := jwt.New(storage, *cfg)
+	if err != nil {
+		logger.WithErr(err).Fatal("construct jwt manager")
+	}
+
+	mailer, err := mail.New(*cfg, logger)
+	if err != nil {
+		logger.WithErr(err).Fatal("construct mailer")
+	}
+
 	busroutes := busroutes.New(
 		cfg,
 		db,
@@ -76,19 +91,65 @@ func main() {
 		mysql.NewStopStore(db, txer),
 		mysql.NewRouteStore(db, txer),
 		mysql.NewUserStore(db, txer),
+		mysql.NewTimetableStore(db, txer),
+		mysql.NewAPIKeyStore(db, txer),
+		mysql.NewTOTPStore(db, txer),
 		txer,
-		jwt.New(storage, *cfg),
+		tokenManager,
+		apikey.NewTracker(storage),
+		storage,
+		mailer,
+	)
+
+	if flag.Arg(0) == cmdGTFSImport {
+		// the command line is run by the operator, so it is trusted.
+		importCtx := busroutescontext.WithTrusted(log.CtxWithLogger(ctx, logger))
+		if err := runGTFSImport(importCtx, busroutes, flag.Args()[1:]); err != nil {
+			logger.WithErr(err).Fatal("import gtfs feed")
+		}
+		return
+	}
+
+	apiServer, err := handler.NewServer(
+		cfg,
+		busroutes,
+		logger,
 	)
+	if err != nil {
+		logger.WithErr(err).Fatal("construct api server")
+	}
+
+	broker, err := amqp.NewBroker(cfg, logger)
+	if err != nil {
+		logger.WithErr(err).Fatal("construct broker")
+	}
+
+	// the broker is closed after the amqp server and the relay are stopped.
+	defer func() {
+		if err := broker.Close(); err != nil {
+			logger.WithErr(err).Error("close broker")
+		}
+	}()
 
-	apiServer := handler.NewServer(
+	amqpServer := amqp.NewServer(
 		cfg,
+		broker,
 		busroutes,
 		logger,
 	)
 
+	relay := outbox.NewRelay(
+		cfg,
+		mysql.NewOutboxStore(db, txer),
+		txer,
+		broker,
+		logger,
+	)
+
 	var (
-		shutdown     = make(chan os.Signal, 1)
-		serverErrors = make(chan error, 1)
+		shutdown         = make(chan os.Signal, 1)
+		serverErrors     = make(chan error, 1)
+		amqpServerErrors = make(chan error, 1)
 	)
 
 	signal.Notify(shutdown, os.Interrupt, syscall.SIGTERM)
@@ -98,17 +159,45 @@ func main() {
 	}()
 
 	go func() {
-		amqpServer.ListenAndServe()
+		amqpServerErrors <- amqpServer.ListenAndServe()
+	}()
+
+	relayCtx, relayCancel := context.WithCancel(ctx)
+	relayDone := make(chan struct{})
+
+	go func() {
+		defer close(relayDone)
+
+		if err := relay.Run(relayCtx); err != nil {
+			logger.WithErr(err).Error("outbox relay stopped")
+		}
 	}()
 
 	logger.Info("started")
 
 	defer logger.Info("stopped")
 
+	// the relay is stopped after the servers, so it publishes the events of their last commands.
+	defer func() {
+		relayCancel()
+		<-relayDone
+	}()
+
 	select {
 	case err = <-serverErrors:
 		logger.WithErr(err).Error("api server stopped")
 
+		if err := amqpServer.Close(); err != nil {
+			logger.WithErr(err).Error("close amqp server")
+		}
+
+	case err = <-amqpServerErrors:
+		logger.WithErr(err).Error("amqp server stopped")
+
+		if err := apiServer.Close(); err != nil {
+			logger.WithErr(err).Error("close api server")
+		}
+
 	case sig := <-shutdown:
 		logger.WithField("signal", sig.String()).Info("gracefully shutdown application")
 
@@ -123,5 +212,14 @@ func main() {
 		if err != nil {
 			logger.WithErr(err).Error("could not stopped api server gracefully")
 		}
+
+		if err = amqpServer.Shutdown(ctx); err != nil {
+			logger.WithErr(err).Error("amqp server shutdown error")
+			err = amqpServer.Close()
+		}
+
+		if err != nil {
+			logger.WithErr(err).Error("could not stopped amqp server gracefully")
+		}
 	}
 }
@@ -0,0 +1,55 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"os"
+
+	"github.com/gxravel/bus-routes/internal/busroutes"
+	"github.com/gxravel/bus-routes/internal/gtfs"
+
+	"github.com/pkg/errors"
+)
+
+const cmdGTFSImport = "gtfs-import"
+
+// runGTFSImport imports the GTFS zip and prints the report.
+// Usage: gtfs-import [-city name] feed.zip
+func runGTFSImport(ctx context.Context, busroutes *busroutes.BusRoutes, args []string) error {
+	flags := flag.NewFlagSet(cmdGTFSImport, flag.ContinueOnError)
+	city := flags.String("city", "", "city of the feed, defaults to the name of the only agency")
+
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if flags.NArg() != 1 {
+		return errors.New("usage: " + cmdGTFSImport + " [-city name] feed.zip")
+	}
+
+	file, err := os.Open(flags.Arg(0))
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	info, err := file.Stat()
+	if err != nil {
+		return err
+	}
+
+	feed, err := gtfs.Read(file, info.Size())
+	if err != nil {
+		return err
+	}
+
+	report, err := busroutes.ImportGTFS(ctx, feed, *city)
+	if err != nil {
+		return err
+	}
+
+	encoder := json.NewEncoder(os.Stdout)
+	encoder.SetIndent("", "  ")
+
+	return encoder.Encode(report)
+}
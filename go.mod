@@ -3,3 +3,7 @@ module github.com/gxravel/bus-routes
 go 1.16
 
 require github.com/streadway/amqp v1.0.0
+
+require github.com/gxravel/bus-routes/pkg/rmq v0.0.0
+
+replace github.com/gxravel/bus-routes/pkg/rmq => ./pkg/rmq
@@ -0,0 +1,45 @@
+package amqp
+
+import (
+	"github.com/gxravel/bus-routes/internal/config"
+	log "github.com/gxravel/bus-routes/internal/logger"
+	rmq "github.com/gxravel/bus-routes/pkg/rmq"
+
+	"github.com/pkg/errors"
+)
+
+// NewBroker connects to rabbitmq of the config, or creates the in-memory broker if it is configured.
+func NewBroker(cfg *config.Config, logger log.Logger) (rmq.MessageBroker, error) {
+	if cfg.RabbitMQ.InMemory {
+		logger.Warn("using in-memory broker, the messages are lost on restart")
+		return rmq.NewMemory(cfg.RabbitMQ.Config), nil
+	}
+
+	broker, err := rmq.NewClient(cfg.RabbitMQ.Config, brokerLogger{logger})
+	if err != nil {
+		return nil, errors.Wrap(err, "connect to rabbitmq")
+	}
+
+	return broker, nil
+}
+
+// brokerLogger adapts log.Logger to the logger of the broker client.
+type brokerLogger struct {
+	log.Logger
+}
+
+func (l brokerLogger) WithField(name string, value interface{}) rmq.Logger {
+	return brokerLogger{l.Logger.WithField(name, value)}
+}
+
+func (l brokerLogger) WithFields(pairs ...interface{}) rmq.Logger {
+	return brokerLogger{l.Logger.WithFields(pairs...)}
+}
+
+func (l brokerLogger) WithErr(err error) rmq.Logger {
+	return brokerLogger{l.Logger.WithErr(err)}
+}
+
+func (l brokerLogger) WithStr(key string, val string) rmq.Logger {
+	return brokerLogger{l.Logger.WithStr(key, val)}
+}
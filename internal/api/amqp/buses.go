@@ -0,0 +1,54 @@
+package amqp
+
+import (
+	"context"
+
+	httpv1 "github.com/gxravel/bus-routes/internal/api/http/handler/v1"
+	"github.com/gxravel/bus-routes/internal/dataprovider"
+	ierr "github.com/gxravel/bus-routes/internal/errors"
+)
+
+var (
+	errMustProvideBus = ierr.NewReason(ierr.ErrMustProvide).WithMessage("bus")
+)
+
+// busDelete is the body of the command deleting the bus by id.
+type busDelete struct {
+	ID int64 `json:"id"`
+}
+
+func (s *Server) addBuses(ctx context.Context, body []byte) (interface{}, error) {
+	var buses = make([]*httpv1.Bus, 0)
+	if err := decode(body, &buses); err != nil {
+		return nil, err
+	}
+	if len(buses) == 0 {
+		return nil, errMustProvideBus
+	}
+
+	return nil, s.busroutes.AddBuses(ctx, buses...)
+}
+
+func (s *Server) updateBus(ctx context.Context, body []byte) (interface{}, error) {
+	var bus = &httpv1.Bus{}
+	if err := decode(body, bus); err != nil {
+		return nil, err
+	}
+	if bus.ID == 0 {
+		return nil, errMustProvideBus
+	}
+
+	return nil, s.busroutes.UpdateBus(ctx, bus)
+}
+
+func (s *Server) deleteBus(ctx context.Context, body []byte) (interface{}, error) {
+	var bus = &busDelete{}
+	if err := decode(body, bus); err != nil {
+		return nil, err
+	}
+	if bus.ID == 0 {
+		return nil, errMustProvideBus
+	}
+
+	return nil, s.busroutes.DeleteBus(ctx, dataprovider.NewBusFilter().ByIDs(bus.ID))
+}
@@ -0,0 +1,62 @@
+package amqp
+
+import (
+	"context"
+
+	httpv1 "github.com/gxravel/bus-routes/internal/api/http/handler/v1"
+	"github.com/gxravel/bus-routes/internal/dataprovider"
+	ierr "github.com/gxravel/bus-routes/internal/errors"
+)
+
+var (
+	errMustProvideCity = ierr.NewReason(ierr.ErrMustProvide).WithMessage("city")
+)
+
+// cityDelete is the body of the command deleting the city by id or name.
+type cityDelete struct {
+	ID   int    `json:"id"`
+	Name string `json:"name"`
+}
+
+func (s *Server) addCities(ctx context.Context, body []byte) (interface{}, error) {
+	var cities = make([]*httpv1.City, 0)
+	if err := decode(body, &cities); err != nil {
+		return nil, err
+	}
+	if len(cities) == 0 {
+		return nil, errMustProvideCity
+	}
+
+	return nil, s.busroutes.AddCities(ctx, cities...)
+}
+
+func (s *Server) updateCity(ctx context.Context, body []byte) (interface{}, error) {
+	var city = &httpv1.City{}
+	if err := decode(body, city); err != nil {
+		return nil, err
+	}
+	if city.ID == 0 {
+		return nil, errMustProvideCity
+	}
+
+	return nil, s.busroutes.UpdateCity(ctx, city)
+}
+
+func (s *Server) deleteCity(ctx context.Context, body []byte) (interface{}, error) {
+	var city = &cityDelete{}
+	if err := decode(body, city); err != nil {
+		return nil, err
+	}
+
+	filter := dataprovider.NewCityFilter()
+	switch {
+	case city.ID != 0:
+		filter = filter.ByIDs(city.ID)
+	case city.Name != "":
+		filter = filter.ByNames(city.Name)
+	default:
+		return nil, errMustProvideCity
+	}
+
+	return nil, s.busroutes.DeleteCity(ctx, filter)
+}
@@ -0,0 +1,91 @@
+package amqp
+
+import (
+	"context"
+	"fmt"
+
+	httpv1 "github.com/gxravel/bus-routes/internal/api/http/handler/v1"
+	"github.com/gxravel/bus-routes/internal/dataprovider"
+	ierr "github.com/gxravel/bus-routes/internal/errors"
+	"github.com/gxravel/bus-routes/internal/model"
+)
+
+var (
+	errMustProvideRoute = ierr.NewReason(ierr.ErrMustProvide).WithMessage("route")
+)
+
+// routeDelete is the body of the command deleting the routes of the bus, or the step of the route pattern.
+// The step of the default pattern is deleted if the direction and the variant are not set.
+type routeDelete struct {
+	BusID     int64            `json:"bus_id"`
+	Direction *model.Direction `json:"direction"`
+	Variant   *string          `json:"variant"`
+	StopID    int64            `json:"stop_id"`
+	Step      int              `json:"step"`
+}
+
+func (s *Server) addRoutes(ctx context.Context, body []byte) (interface{}, error) {
+	var routes = make([]*httpv1.Route, 0)
+	if err := decode(body, &routes); err != nil {
+		return nil, err
+	}
+	if len(routes) == 0 {
+		return nil, errMustProvideRoute
+	}
+
+	return nil, s.busroutes.AddRoutes(ctx, routes...)
+}
+
+func (s *Server) updateRoute(ctx context.Context, body []byte) (interface{}, error) {
+	var route = &httpv1.Route{}
+	if err := decode(body, route); err != nil {
+		return nil, err
+	}
+	if route.BusID == 0 || route.Step == 0 {
+		return nil, errMustProvideRoute
+	}
+
+	return nil, s.busroutes.UpdateRoute(ctx, route)
+}
+
+func (s *Server) deleteRoute(ctx context.Context, body []byte) (interface{}, error) {
+	var route = &routeDelete{}
+	if err := decode(body, route); err != nil {
+		return nil, err
+	}
+	if route.BusID == 0 && route.Step == 0 {
+		return nil, errMustProvideRoute
+	}
+	if route.Direction != nil && !route.Direction.IsValid() {
+		return nil, ierr.NewReason(ierr.ErrValidationFailed).
+			WithMessage(fmt.Sprintf("invalid direction %q", *route.Direction))
+	}
+
+	filter := dataprovider.NewRouteFilter()
+	if route.BusID != 0 {
+		filter = filter.ByBusIDs(route.BusID)
+	}
+	if route.StopID != 0 {
+		filter = filter.ByStopIDs(route.StopID)
+	}
+	if route.Step != 0 {
+		filter = filter.BySteps(route.Step)
+
+		if route.Direction == nil {
+			direction := model.DefaultDirection
+			route.Direction = &direction
+		}
+		if route.Variant == nil {
+			variant := ""
+			route.Variant = &variant
+		}
+	}
+	if route.Direction != nil {
+		filter = filter.ByDirections(*route.Direction)
+	}
+	if route.Variant != nil {
+		filter = filter.ByVariants(*route.Variant)
+	}
+
+	return nil, s.busroutes.DeleteRoute(ctx, filter)
+}
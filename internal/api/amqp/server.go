@@ -0,0 +1,352 @@
+package amqp
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+
+	httpv1 "github.com/gxravel/bus-routes/internal/api/http/handler/v1"
+	"github.com/gxravel/bus-routes/internal/busroutes"
+	"github.com/gxravel/bus-routes/internal/busroutescontext"
+	"github.com/gxravel/bus-routes/internal/config"
+	ierr "github.com/gxravel/bus-routes/internal/errors"
+	log "github.com/gxravel/bus-routes/internal/logger"
+	"github.com/gxravel/bus-routes/internal/model"
+	rmq "github.com/gxravel/bus-routes/pkg/rmq"
+
+	"github.com/pkg/errors"
+	"github.com/streadway/amqp"
+)
+
+// Command types of the messages, set in the type property.
+const (
+	CommandAdd    = "add"
+	CommandUpdate = "update"
+	CommandDelete = "delete"
+)
+
+const mimeApplicationJSON = "application/json"
+
+// The headers of the command message authenticating its sender, as the http api does.
+const (
+	// HeaderAuthorization is the header with the token of the user in the format "Bearer token".
+	HeaderAuthorization = "Authorization"
+	// HeaderAPIKey is the header with the API key of the service user.
+	HeaderAPIKey = "X-API-Key"
+)
+
+// ErrServerClosed is returned by ListenAndServe after Shutdown or Close.
+var ErrServerClosed = errors.New("amqp: server closed")
+
+// errPanic is the cause of the error of the handler which panicked.
+var errPanic = errors.New("panic")
+
+// handlerFunc handles the body of the command message and returns the data of the reply.
+type handlerFunc func(ctx context.Context, body []byte) (interface{}, error)
+
+// commands are the handlers of the queue by the command type.
+type commands map[string]handlerFunc
+
+// queue is the commands of the queue and the permission they require from the sender.
+type queue struct {
+	permission model.Permission
+	commands   commands
+}
+
+var (
+	errMustProvideCredentials = ierr.NewReason(ierr.ErrUnauthorized).WithMessage("must provide token or API key")
+	errEmailNotVerified       = ierr.NewReason(ierr.ErrPermissionDenied).WithMessage("email is not verified")
+)
+
+// Server consumes the command messages from the queues and dispatches them to BusRoutes.
+// The reply is sent to the reply_to queue with the correlation_id of the command if the former is set.
+// The commands are authorized by the token or the API key of the sender as the http requests are,
+// so the permissions and the city scope of the sender are checked.
+type Server struct {
+	broker    rmq.MessageBroker
+	logger    log.Logger
+	busroutes *busroutes.BusRoutes
+	queues    map[string]*queue
+
+	wg        sync.WaitGroup
+	done      chan struct{}
+	closeOnce sync.Once
+}
+
+// NewServer creates new instance of Server, the broker is closed by the caller after the server.
+func NewServer(
+	cfg *config.Config,
+	broker rmq.MessageBroker,
+	busroutes *busroutes.BusRoutes,
+	logger log.Logger,
+) *Server {
+	logger = logger.WithModule("api:amqp")
+
+	srv := &Server{
+		broker:    broker,
+		logger:    logger,
+		busroutes: busroutes,
+		done:      make(chan struct{}),
+	}
+
+	srv.queues = map[string]*queue{
+		cfg.RabbitMQ.Queues.Cities: {
+			permission: model.PermissionCitiesWrite,
+			commands: commands{
+				CommandAdd:    srv.addCities,
+				CommandUpdate: srv.updateCity,
+				CommandDelete: srv.deleteCity,
+			},
+		},
+		cfg.RabbitMQ.Queues.Stops: {
+			permission: model.PermissionStopsWrite,
+			commands: commands{
+				CommandAdd:    srv.addStops,
+				CommandUpdate: srv.updateStop,
+				CommandDelete: srv.deleteStop,
+			},
+		},
+		cfg.RabbitMQ.Queues.Buses: {
+			permission: model.PermissionBusesWrite,
+			commands: commands{
+				CommandAdd:    srv.addBuses,
+				CommandUpdate: srv.updateBus,
+				CommandDelete: srv.deleteBus,
+			},
+		},
+		cfg.RabbitMQ.Queues.Routes: {
+			permission: model.PermissionRoutesWrite,
+			commands: commands{
+				CommandAdd:    srv.addRoutes,
+				CommandUpdate: srv.updateRoute,
+				CommandDelete: srv.deleteRoute,
+			},
+		},
+	}
+
+	return srv
+}
+
+// ListenAndServe declares the queues and serves their messages until the server is shut down.
+// The consumers are resumed by the broker client after the connection is restored.
+func (s *Server) ListenAndServe() error {
+	select {
+	case <-s.done:
+		return ErrServerClosed
+	default:
+	}
+
+	for name, queue := range s.queues {
+		if _, err := s.broker.DeclareQueue(name); err != nil {
+			return errors.Wrapf(err, "declare queue %s", name)
+		}
+
+		deliveries, err := s.broker.Consume(name)
+		if err != nil {
+			return errors.Wrapf(err, "consume queue %s", name)
+		}
+
+		s.wg.Add(1)
+		go s.serve(name, queue, deliveries)
+	}
+
+	s.wg.Wait()
+
+	select {
+	case <-s.done:
+		return ErrServerClosed
+	default:
+		return errors.New("amqp: deliveries are closed by the broker")
+	}
+}
+
+// Shutdown stops taking new messages and waits for the current ones to be handled.
+// The taken but not handled messages are redelivered after the broker is closed.
+func (s *Server) Shutdown(ctx context.Context) error {
+	s.closeOnce.Do(func() { close(s.done) })
+
+	var served = make(chan struct{})
+	go func() {
+		s.wg.Wait()
+		close(served)
+	}()
+
+	select {
+	case <-served:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Close stops taking new messages without waiting for the current ones.
+func (s *Server) Close() error {
+	s.closeOnce.Do(func() { close(s.done) })
+	return nil
+}
+
+func (s *Server) serve(name string, queue *queue, deliveries <-chan amqp.Delivery) {
+	defer s.wg.Done()
+
+	logger := s.logger.WithStr("queue", name)
+
+	for {
+		select {
+		case <-s.done:
+			return
+
+		case delivery, ok := <-deliveries:
+			if !ok {
+				logger.Warn("deliveries are closed")
+				return
+			}
+
+			s.handle(logger, queue, delivery)
+		}
+	}
+}
+
+func (s *Server) handle(logger log.Logger, queue *queue, delivery amqp.Delivery) {
+	logger = logger.WithFields(
+		"type", delivery.Type,
+		"correlation_id", delivery.CorrelationId,
+	)
+
+	ctx := log.CtxWithLogger(context.Background(), logger)
+
+	data, err := s.dispatch(ctx, queue, delivery)
+	if err != nil {
+		logger.WithErr(err).Error("handle command")
+	}
+
+	// the command refused because of the sender is not handled again.
+	// The one failed because of the service is requeued once and then goes to the dead letter queue,
+	// the one which panicked goes there at once.
+	var (
+		failed  = err != nil && !isClientError(err)
+		requeue = failed && errors.Cause(err) != errPanic && !delivery.Redelivered
+	)
+
+	// the requeued command is replied after it is handled again.
+	if delivery.ReplyTo != "" && !requeue {
+		if err := s.reply(delivery, data, err); err != nil {
+			logger.WithErr(err).Error("reply to command")
+		}
+	}
+
+	if failed {
+		err = delivery.Nack(false, requeue)
+	} else {
+		err = delivery.Ack(false)
+	}
+	if err != nil {
+		logger.WithErr(err).Error("acknowledge command")
+	}
+}
+
+// isClientError returns true if the command is refused because of the sender, e.g. it is invalid or not permitted.
+func isClientError(err error) bool {
+	reason := ierr.ConvertToReason(errors.Cause(err))
+	return ierr.ResolveStatusCode(reason.Err) < http.StatusInternalServerError
+}
+
+func (s *Server) dispatch(ctx context.Context, queue *queue, delivery amqp.Delivery) (data interface{}, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			log.FromContext(ctx).Errorf("panic: %v", r)
+			err = errors.Wrapf(errPanic, "%v", r)
+		}
+	}()
+
+	handler, ok := queue.commands[delivery.Type]
+	if !ok {
+		return nil, ierr.NewReason(ierr.ErrBadRequest).
+			WithMessage(fmt.Sprintf("unknown command %q", delivery.Type))
+	}
+
+	ctx, err = s.authorize(ctx, queue.permission, delivery)
+	if err != nil {
+		return nil, err
+	}
+
+	return handler(ctx, delivery.Body)
+}
+
+// authorize finds the sender of the command by API key or token and adds its data to ctx, as the http api does.
+// The sender with unverified email is denied the commands, as they all change the data.
+func (s *Server) authorize(ctx context.Context, permission model.Permission, delivery amqp.Delivery) (context.Context, error) {
+	var (
+		token string
+		user  *httpv1.User
+		err   error
+	)
+
+	if key := headerString(delivery.Headers, HeaderAPIKey); key != "" {
+		user, err = s.busroutes.GetUserByAPIKey(ctx, key, permission)
+	} else {
+		token = strings.TrimPrefix(headerString(delivery.Headers, HeaderAuthorization), "Bearer ")
+		if token == "" {
+			return nil, errMustProvideCredentials
+		}
+
+		user, err = s.busroutes.GetUserByToken(ctx, token, permission)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if len(user.Scopes) == 0 && !user.Verified {
+		return nil, errEmailNotVerified
+	}
+
+	ctx = context.WithValue(ctx, busroutescontext.UserKey, user)
+	ctx = context.WithValue(ctx, busroutescontext.TokenKey, token)
+
+	return ctx, nil
+}
+
+// headerString returns the string value of the header of the message.
+func headerString(headers amqp.Table, name string) string {
+	value, _ := headers[name].(string)
+	return value
+}
+
+// reply sends the response in the same format as the http api does.
+func (s *Server) reply(delivery amqp.Delivery, data interface{}, err error) error {
+	var response = &httpv1.Response{Data: data}
+	if err != nil {
+		reason := ierr.ConvertToReason(err)
+		response = &httpv1.Response{
+			Error: &httpv1.APIError{
+				Reason: &httpv1.APIReason{
+					RType:   string(reason.RType),
+					Err:     reason.Error(),
+					Message: reason.Message,
+				},
+			},
+		}
+	}
+
+	body, err := json.Marshal(response)
+	if err != nil {
+		return errors.Wrap(err, "encode reply")
+	}
+
+	return s.broker.Publish("", delivery.ReplyTo, amqp.Publishing{
+		ContentType:   mimeApplicationJSON,
+		CorrelationId: delivery.CorrelationId,
+		Body:          body,
+	})
+}
+
+// decode decodes the json body of the command.
+func decode(body []byte, data interface{}) error {
+	if err := json.Unmarshal(body, data); err != nil {
+		return ierr.NewReason(ierr.ErrBadRequest).WithMessage(err.Error())
+	}
+
+	return nil
+}
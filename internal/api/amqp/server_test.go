@@ -0,0 +1,154 @@
+package amqp
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	httpv1 "github.com/gxravel/bus-routes/internal/api/http/handler/v1"
+	"github.com/gxravel/bus-routes/internal/config"
+	ierr "github.com/gxravel/bus-routes/internal/errors"
+	log "github.com/gxravel/bus-routes/internal/logger"
+	rmq "github.com/gxravel/bus-routes/pkg/rmq"
+
+	"github.com/streadway/amqp"
+)
+
+const (
+	testTimeout = time.Second
+	// testQuiet is the time to wait to make sure no message is delivered.
+	testQuiet = time.Millisecond * 50
+)
+
+// TestServerRefusesCommand checks the commands refused because of the sender are replied and acknowledged,
+// so they are neither requeued nor dead-lettered.
+func TestServerRefusesCommand(t *testing.T) {
+	tests := []struct {
+		name    string
+		command amqp.Publishing
+		wantErr string
+	}{
+		{
+			name:    "unknown command",
+			command: amqp.Publishing{Type: "rename", Body: []byte(`{}`)},
+			wantErr: ierr.ErrBadRequest.Error(),
+		},
+		{
+			name:    "no credentials",
+			command: amqp.Publishing{Type: CommandAdd, Body: []byte(`[{"name":"Moscow"}]`)},
+			wantErr: ierr.ErrUnauthorized.Error(),
+		},
+		{
+			name: "not bearer token",
+			command: amqp.Publishing{
+				Type:    CommandDelete,
+				Headers: amqp.Table{HeaderAuthorization: "Bearer "},
+				Body:    []byte(`{"name":"Moscow"}`),
+			},
+			wantErr: ierr.ErrUnauthorized.Error(),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := &config.Config{}
+			cfg.RabbitMQ.Queues.Cities = "cities"
+			cfg.RabbitMQ.Queues.Stops = "stops"
+			cfg.RabbitMQ.Queues.Buses = "buses"
+			cfg.RabbitMQ.Queues.Routes = "routes"
+			cfg.RabbitMQ.DeadLetterExchange = "dlx"
+
+			broker := rmq.NewMemory(cfg.RabbitMQ.Config)
+			defer broker.Close()
+
+			// the queues are declared before the server starts, so the command is not dropped.
+			for _, name := range []string{"cities", "replies"} {
+				if _, err := broker.DeclareQueue(name); err != nil {
+					t.Fatal(err)
+				}
+			}
+			replies := consume(t, broker, "replies")
+			dead := consume(t, broker, "cities.dead")
+
+			srv := NewServer(cfg, broker, nil, log.Default())
+			var served = make(chan error, 1)
+			go func() { served <- srv.ListenAndServe() }()
+			defer func() {
+				if err := srv.Shutdown(context.Background()); err != nil {
+					t.Error(err)
+				}
+				if err := <-served; err != ErrServerClosed {
+					t.Errorf("ListenAndServe() = %v, want %v", err, ErrServerClosed)
+				}
+			}()
+
+			command := tt.command
+			command.ReplyTo = "replies"
+			command.CorrelationId = tt.name
+			if err := broker.Publish("", "cities", command); err != nil {
+				t.Fatal(err)
+			}
+
+			var reply = receive(t, replies)
+			if reply.CorrelationId != tt.name {
+				t.Errorf("got correlation id %q, want %q", reply.CorrelationId, tt.name)
+			}
+
+			var response httpv1.Response
+			if err := json.Unmarshal(reply.Body, &response); err != nil {
+				t.Fatal(err)
+			}
+			if response.Error == nil || response.Error.Reason == nil {
+				t.Fatalf("got reply %s, want error", reply.Body)
+			}
+			if response.Error.Reason.Err != tt.wantErr {
+				t.Errorf("got error %q, want %q", response.Error.Reason.Err, tt.wantErr)
+			}
+
+			// the refused command is neither replied again after the requeue nor dead-lettered.
+			expectNone(t, replies)
+			expectNone(t, dead)
+		})
+	}
+}
+
+func consume(t *testing.T, broker rmq.MessageBroker, queue string) <-chan amqp.Delivery {
+	t.Helper()
+
+	deliveries, err := broker.Consume(queue)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return deliveries
+}
+
+func receive(t *testing.T, deliveries <-chan amqp.Delivery) amqp.Delivery {
+	t.Helper()
+
+	select {
+	case d, ok := <-deliveries:
+		if !ok {
+			t.Fatal("the deliveries are closed")
+		}
+		if err := d.Ack(false); err != nil {
+			t.Fatal(err)
+		}
+		return d
+	case <-time.After(testTimeout):
+		t.Fatal("no delivery")
+	}
+
+	return amqp.Delivery{}
+}
+
+func expectNone(t *testing.T, deliveries <-chan amqp.Delivery) {
+	t.Helper()
+
+	select {
+	case d := <-deliveries:
+		t.Fatalf("got unexpected delivery %s", d.Body)
+	case <-time.After(testQuiet):
+	}
+}
@@ -0,0 +1,62 @@
+package amqp
+
+import (
+	"context"
+
+	httpv1 "github.com/gxravel/bus-routes/internal/api/http/handler/v1"
+	"github.com/gxravel/bus-routes/internal/dataprovider"
+	ierr "github.com/gxravel/bus-routes/internal/errors"
+)
+
+var (
+	errMustProvideStop = ierr.NewReason(ierr.ErrMustProvide).WithMessage("stop")
+)
+
+// stopDelete is the body of the command deleting the stop by id or address.
+type stopDelete struct {
+	ID      int64  `json:"id"`
+	Address string `json:"address"`
+}
+
+func (s *Server) addStops(ctx context.Context, body []byte) (interface{}, error) {
+	var stops = make([]*httpv1.Stop, 0)
+	if err := decode(body, &stops); err != nil {
+		return nil, err
+	}
+	if len(stops) == 0 {
+		return nil, errMustProvideStop
+	}
+
+	return nil, s.busroutes.AddStops(ctx, stops...)
+}
+
+func (s *Server) updateStop(ctx context.Context, body []byte) (interface{}, error) {
+	var stop = &httpv1.Stop{}
+	if err := decode(body, stop); err != nil {
+		return nil, err
+	}
+	if stop.ID == 0 {
+		return nil, errMustProvideStop
+	}
+
+	return nil, s.busroutes.UpdateStops(ctx, stop)
+}
+
+func (s *Server) deleteStop(ctx context.Context, body []byte) (interface{}, error) {
+	var stop = &stopDelete{}
+	if err := decode(body, stop); err != nil {
+		return nil, err
+	}
+
+	filter := dataprovider.NewStopFilter()
+	switch {
+	case stop.ID != 0:
+		filter = filter.ByIDs(stop.ID)
+	case stop.Address != "":
+		filter = filter.ByAddresses(stop.Address)
+	default:
+		return nil, errMustProvideStop
+	}
+
+	return nil, s.busroutes.DeleteStop(ctx, filter)
+}
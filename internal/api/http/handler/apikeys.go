@@ -0,0 +1,82 @@
+package handler
+
+import (
+	"net/http"
+
+	api "github.com/gxravel/bus-routes/internal/api/http"
+	httpv1 "github.com/gxravel/bus-routes/internal/api/http/handler/v1"
+	ierr "github.com/gxravel/bus-routes/internal/errors"
+)
+
+var (
+	errMustProvideAPIKeyID = ierr.NewReason(ierr.ErrMustProvide).WithMessage("api key id")
+)
+
+func (s *Server) getAPIKeys(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	userID, err := api.ParseURLParamInt64(r, "id")
+	if err != nil || userID == 0 {
+		api.RespondError(ctx, w, errMustProvideUserID)
+		return
+	}
+
+	keys, err := s.busroutes.GetAPIKeys(ctx, userID)
+	if err != nil {
+		api.RespondError(ctx, w, err)
+		return
+	}
+
+	api.RespondDataOK(ctx, w, httpv1.RangeItemsResponse{
+		Items: keys,
+		Total: int64(len(keys)),
+	})
+}
+
+// addAPIKey issues the API key to the service user, the response is the only place the key is shown.
+func (s *Server) addAPIKey(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	userID, err := api.ParseURLParamInt64(r, "id")
+	if err != nil || userID == 0 {
+		api.RespondError(ctx, w, errMustProvideUserID)
+		return
+	}
+
+	var create = &httpv1.APIKeyCreate{}
+	if err := s.processRequest(r, create); err != nil {
+		api.RespondError(ctx, w, err)
+		return
+	}
+
+	key, err := s.busroutes.AddAPIKey(ctx, userID, create)
+	if err != nil {
+		api.RespondError(ctx, w, err)
+		return
+	}
+
+	api.RespondData(ctx, w, http.StatusCreated, key)
+}
+
+func (s *Server) revokeAPIKey(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	userID, err := api.ParseURLParamInt64(r, "id")
+	if err != nil || userID == 0 {
+		api.RespondError(ctx, w, errMustProvideUserID)
+		return
+	}
+
+	id, err := api.ParseURLParamInt64(r, "key_id")
+	if err != nil || id == 0 {
+		api.RespondError(ctx, w, errMustProvideAPIKeyID)
+		return
+	}
+
+	if err := s.busroutes.RevokeAPIKey(ctx, userID, id); err != nil {
+		api.RespondError(ctx, w, err)
+		return
+	}
+
+	api.RespondNoContent(w)
+}
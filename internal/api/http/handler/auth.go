@@ -7,9 +7,15 @@ import (
 
 	api "github.com/gxravel/bus-routes/internal/api/http"
 	httpv1 "github.com/gxravel/bus-routes/internal/api/http/handler/v1"
+	"github.com/gxravel/bus-routes/internal/busroutescontext"
 	"github.com/gxravel/bus-routes/internal/dataprovider"
 	ierr "github.com/gxravel/bus-routes/internal/errors"
-	"github.com/gxravel/bus-routes/internal/model"
+)
+
+var (
+	errMustProvideRefreshToken = ierr.NewReason(ierr.ErrMustProvide).WithMessage("refresh_token")
+	errMustProvideToken        = ierr.NewReason(ierr.ErrMustProvide).WithMessage("token")
+	errInvalidEmail            = ierr.NewReason(ierr.ErrValidationFailed).WithMessage("invalid email")
 )
 
 var (
@@ -50,8 +56,16 @@ func (s *Server) signup(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	user.ID = id
-	user.Type = model.DefaultUserType
+	// the user is signed up already, so failing to send the email does not fail the request, it can be resent.
+	if err := s.busroutes.SendVerification(ctx, id); err != nil {
+		s.logger.WithErr(err).Error("send email verification")
+	}
+
+	user, err = s.busroutes.GetUser(ctx, id)
+	if err != nil {
+		api.RespondError(ctx, w, err)
+		return
+	}
 
 	token, err := s.busroutes.NewJWT(ctx, user)
 	if err != nil {
@@ -76,12 +90,27 @@ func (s *Server) login(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	ip := api.ClientIP(r, s.proxies)
+
+	// the attempt is counted before the password is checked, so the locked out ones cost no bcrypt compare.
+	attempt, err := s.busroutes.CheckLoginAttempts(ctx, user.Email, ip)
+	if err != nil {
+		api.RespondError(ctx, w, err)
+		return
+	}
+
 	filter := dataprovider.
 		NewUserFilter().
 		SelectPassword().
 		ByEmails(user.Email)
 
 	if err := s.busroutes.CheckPasswordHash(ctx, user.Password, filter); err != nil {
+		if reason := ierr.ConvertToReason(err); reason.Err == ierr.ErrWrongCredentials {
+			if err := s.busroutes.FailLogin(ctx, attempt); err != nil {
+				s.logger.WithErr(err).Error("count failed login")
+			}
+		}
+
 		api.RespondError(ctx, w, err)
 		return
 	}
@@ -98,6 +127,22 @@ func (s *Server) login(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// the user with 2FA gets the tokens for the code, the failed attempts are reset after it.
+	if users[0].TwoFactor {
+		challenge, err := s.busroutes.NewTwoFactorChallenge(ctx, users[0].ID)
+		if err != nil {
+			api.RespondError(ctx, w, err)
+			return
+		}
+
+		api.RespondData(ctx, w, http.StatusAccepted, challenge)
+		return
+	}
+
+	if err := s.busroutes.ResetLoginAttempts(ctx, attempt); err != nil {
+		s.logger.WithErr(err).Error("reset login attempts")
+	}
+
 	token, err := s.busroutes.NewJWT(ctx, users[0])
 	if err != nil {
 		api.RespondError(ctx, w, err)
@@ -106,3 +151,153 @@ func (s *Server) login(w http.ResponseWriter, r *http.Request) {
 
 	api.RespondDataOK(ctx, w, token)
 }
+
+func (s *Server) verifyEmail(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	var verify = &httpv1.EmailVerify{}
+	if err := s.processRequest(r, verify); err != nil {
+		api.RespondError(ctx, w, err)
+		return
+	}
+
+	if verify.Token == "" {
+		api.RespondError(ctx, w, errMustProvideToken)
+		return
+	}
+
+	if err := s.busroutes.VerifyEmail(ctx, verify.Token); err != nil {
+		api.RespondError(ctx, w, err)
+		return
+	}
+
+	api.RespondNoContent(w)
+}
+
+func (s *Server) resendVerification(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	user := busroutescontext.GetUser(ctx)
+
+	if err := s.busroutes.CheckSendLimit(ctx, user.Email, api.ClientIP(r, s.proxies)); err != nil {
+		api.RespondError(ctx, w, err)
+		return
+	}
+
+	if err := s.busroutes.SendVerification(ctx, user.ID); err != nil {
+		api.RespondError(ctx, w, err)
+		return
+	}
+
+	api.RespondNoContent(w)
+}
+
+func (s *Server) forgotPassword(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	var forgot = &httpv1.PasswordForgot{}
+	if err := s.processRequest(r, forgot); err != nil {
+		api.RespondError(ctx, w, err)
+		return
+	}
+
+	if !regEmail.MatchString(forgot.Email) {
+		api.RespondError(ctx, w, errInvalidEmail)
+		return
+	}
+
+	email := strings.ToLower(forgot.Email)
+
+	if err := s.busroutes.CheckSendLimit(ctx, email, api.ClientIP(r, s.proxies)); err != nil {
+		api.RespondError(ctx, w, err)
+		return
+	}
+
+	if err := s.busroutes.ForgotPassword(ctx, email); err != nil {
+		api.RespondError(ctx, w, err)
+		return
+	}
+
+	api.RespondNoContent(w)
+}
+
+func (s *Server) resetPassword(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	var reset = &httpv1.PasswordReset{}
+	if err := s.processRequest(r, reset); err != nil {
+		api.RespondError(ctx, w, err)
+		return
+	}
+
+	if reset.Token == "" {
+		api.RespondError(ctx, w, errMustProvideToken)
+		return
+	}
+	if !regPass.MatchString(reset.NewPassword) {
+		api.RespondError(ctx, w, errInvalidNewPassword)
+		return
+	}
+
+	if err := s.busroutes.ResetPassword(ctx, reset); err != nil {
+		api.RespondError(ctx, w, err)
+		return
+	}
+
+	api.RespondNoContent(w)
+}
+
+func (s *Server) refreshToken(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	var refresh = &httpv1.TokenRefresh{}
+	if err := s.processRequest(r, refresh); err != nil {
+		api.RespondError(ctx, w, err)
+		return
+	}
+
+	if refresh.RefreshToken == "" {
+		api.RespondError(ctx, w, errMustProvideRefreshToken)
+		return
+	}
+
+	token, err := s.busroutes.RefreshJWT(ctx, refresh.RefreshToken)
+	if err != nil {
+		api.RespondError(ctx, w, err)
+		return
+	}
+
+	api.RespondDataOK(ctx, w, token)
+}
+
+func (s *Server) logout(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	if err := s.busroutes.Logout(ctx, busroutescontext.GetToken(ctx)); err != nil {
+		api.RespondError(ctx, w, err)
+		return
+	}
+
+	api.RespondNoContent(w)
+}
+
+func (s *Server) logoutEverywhere(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	if err := s.busroutes.LogoutEverywhere(ctx, busroutescontext.GetUser(ctx).ID); err != nil {
+		api.RespondError(ctx, w, err)
+		return
+	}
+
+	api.RespondNoContent(w)
+}
+
+// jwksMaxAge lets the verifiers cache the keys, the new key must be published for longer before it is activated.
+const jwksMaxAge = "max-age=300"
+
+func (s *Server) getJWKS(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	w.Header().Set("Cache-Control", jwksMaxAge)
+	api.RespondJSON(ctx, w, http.StatusOK, s.busroutes.JWKS())
+}
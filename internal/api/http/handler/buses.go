@@ -49,3 +49,28 @@ func (s *Server) addBuses(w http.ResponseWriter, r *http.Request) {
 
 	api.RespondCreated(w)
 }
+
+// replaceBusRoute replaces the whole route of the bus, and returns the resulting route.
+func (s *Server) replaceBusRoute(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	busID, err := api.ParseURLParamInt64(r, "id")
+	if err != nil || busID == 0 {
+		api.RespondError(ctx, w, errMustProvideBusID)
+		return
+	}
+
+	var replace = &httpv1.RouteReplace{}
+	if err := s.processRequest(r, replace); err != nil {
+		api.RespondError(ctx, w, err)
+		return
+	}
+
+	route, err := s.busroutes.ReplaceRoute(ctx, busID, replace)
+	if err != nil {
+		api.RespondError(ctx, w, err)
+		return
+	}
+
+	api.RespondDataOK(ctx, w, route)
+}
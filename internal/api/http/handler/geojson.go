@@ -0,0 +1,85 @@
+package handler
+
+import (
+	"sort"
+
+	httpv1 "github.com/gxravel/bus-routes/internal/api/http/handler/v1"
+)
+
+// stopsToGeoJSON converts the stops to the collection of points.
+func stopsToGeoJSON(stops []*httpv1.Stop) *httpv1.FeatureCollection {
+	var features = make([]*httpv1.Feature, 0, len(stops))
+	for _, stop := range stops {
+		var geometry *httpv1.Geometry
+		if stop.Lat != nil && stop.Lon != nil {
+			geometry = &httpv1.Geometry{
+				Type:        httpv1.GeoJSONPoint,
+				Coordinates: []float64{*stop.Lon, *stop.Lat},
+			}
+		}
+
+		features = append(features, &httpv1.Feature{
+			Type:     httpv1.GeoJSONFeature,
+			ID:       stop.ID,
+			Geometry: geometry,
+			Properties: map[string]interface{}{
+				"city":    stop.City,
+				"address": stop.Address,
+			},
+		})
+	}
+
+	return &httpv1.FeatureCollection{
+		Type:     httpv1.GeoJSONFeatureCollection,
+		Features: features,
+	}
+}
+
+// routesToGeoJSON converts the routes to the collection of lines going through the stops in order of steps.
+// The stops without location are left out; the route with less than two located stops has no geometry.
+func routesToGeoJSON(routes []*httpv1.RouteDetailed) *httpv1.FeatureCollection {
+	var features = make([]*httpv1.Feature, 0, len(routes))
+	for _, route := range routes {
+		points := make([]httpv1.RoutePoint, len(route.Points))
+		copy(points, route.Points)
+		sort.SliceStable(points, func(i, j int) bool { return points[i].Step < points[j].Step })
+
+		var (
+			coordinates = make([][]float64, 0, len(points))
+			steps       = make([]int, 0, len(points))
+		)
+		for _, point := range points {
+			if point.Lat == nil || point.Lon == nil {
+				continue
+			}
+
+			coordinates = append(coordinates, []float64{*point.Lon, *point.Lat})
+			steps = append(steps, point.Step)
+		}
+
+		var geometry *httpv1.Geometry
+		if len(coordinates) >= 2 {
+			geometry = &httpv1.Geometry{
+				Type:        httpv1.GeoJSONLineString,
+				Coordinates: coordinates,
+			}
+		}
+
+		features = append(features, &httpv1.Feature{
+			Type:     httpv1.GeoJSONFeature,
+			Geometry: geometry,
+			Properties: map[string]interface{}{
+				"city":      route.City,
+				"bus":       route.Bus,
+				"direction": route.Direction,
+				"variant":   route.Variant,
+				"steps":     steps,
+			},
+		})
+	}
+
+	return &httpv1.FeatureCollection{
+		Type:     httpv1.GeoJSONFeatureCollection,
+		Features: features,
+	}
+}
@@ -0,0 +1,102 @@
+package handler
+
+import (
+	"net/http"
+
+	api "github.com/gxravel/bus-routes/internal/api/http"
+	ierr "github.com/gxravel/bus-routes/internal/errors"
+	"github.com/gxravel/bus-routes/internal/gtfs"
+)
+
+const (
+	// gtfsMaxMemory is the part of the uploaded feed kept in memory, the rest is stored in temporary files.
+	gtfsMaxMemory = 32 << 20
+	gtfsFormField = "feed"
+)
+
+var (
+	errMustProvideGTFSFeed = ierr.NewReason(ierr.ErrMustProvide).WithMessage("GTFS zip in the form field " + gtfsFormField)
+)
+
+// importGTFS imports the uploaded GTFS feed and responds with the report.
+func (s *Server) importGTFS(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	if err := r.ParseMultipartForm(gtfsMaxMemory); err != nil {
+		api.RespondError(ctx, w, errMustProvideGTFSFeed)
+		return
+	}
+	defer func() {
+		if err := r.MultipartForm.RemoveAll(); err != nil {
+			s.logger.WithErr(err).Error("remove multipart form files")
+		}
+	}()
+
+	file, header, err := r.FormFile(gtfsFormField)
+	if err != nil {
+		api.RespondError(ctx, w, errMustProvideGTFSFeed)
+		return
+	}
+	defer file.Close()
+
+	feed, err := gtfs.Read(file, header.Size)
+	if err != nil {
+		api.RespondError(ctx, w, ierr.NewReason(ierr.ErrValidationFailed).WithMessage(err.Error()))
+		return
+	}
+
+	city, err := api.ParseQueryParam(r, "city")
+	if err != nil {
+		api.RespondError(ctx, w, err)
+		return
+	}
+
+	report, err := s.busroutes.ImportGTFS(ctx, feed, city)
+	if err != nil {
+		api.RespondError(ctx, w, err)
+		return
+	}
+
+	api.RespondDataOK(ctx, w, report)
+}
+
+// attachmentWriter sets the headers of the zip attachment on the first write,
+// so the errors occurred before it may still be responded with JSON.
+type attachmentWriter struct {
+	http.ResponseWriter
+	filename string
+	started  bool
+}
+
+func (w *attachmentWriter) Write(p []byte) (int, error) {
+	if !w.started {
+		w.started = true
+		w.Header().Set(api.HeaderContentType, api.MIMEApplicationZip.String())
+		w.Header().Set(api.HeaderContentDisposition, `attachment; filename="`+w.filename+`"`)
+		w.WriteHeader(http.StatusOK)
+	}
+
+	return w.ResponseWriter.Write(p)
+}
+
+// exportGTFS streams the GTFS feed of the cities.
+func (s *Server) exportGTFS(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	filter, err := api.ParseGTFSExportFilter(r)
+	if err != nil {
+		api.RespondError(ctx, w, err)
+		return
+	}
+
+	aw := &attachmentWriter{ResponseWriter: w, filename: "gtfs.zip"}
+
+	if err := s.busroutes.ExportGTFS(ctx, filter, aw); err != nil {
+		if aw.started {
+			s.logger.WithErr(err).Error("export gtfs feed")
+			return
+		}
+
+		api.RespondError(ctx, w, err)
+	}
+}
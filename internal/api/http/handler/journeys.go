@@ -0,0 +1,39 @@
+package handler
+
+import (
+	"net/http"
+
+	api "github.com/gxravel/bus-routes/internal/api/http"
+	httpv1 "github.com/gxravel/bus-routes/internal/api/http/handler/v1"
+	ierr "github.com/gxravel/bus-routes/internal/errors"
+)
+
+var (
+	errMustProvideJourneyStops = ierr.NewReason(ierr.ErrMustProvide).WithMessage("from_stop, to_stop")
+)
+
+// getJourneys returns the itineraries from one stop to another.
+func (s *Server) getJourneys(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	fromStopID, toStopID, err := api.ParseJourneyStops(r)
+	if err != nil {
+		api.RespondError(ctx, w, err)
+		return
+	}
+	if fromStopID == 0 || toStopID == 0 {
+		api.RespondError(ctx, w, errMustProvideJourneyStops)
+		return
+	}
+
+	journeys, err := s.busroutes.GetJourneys(ctx, fromStopID, toStopID)
+	if err != nil {
+		api.RespondError(ctx, w, err)
+		return
+	}
+
+	api.RespondDataOK(ctx, w, httpv1.RangeItemsResponse{
+		Items: journeys,
+		Total: int64(len(journeys)),
+	})
+}
@@ -0,0 +1,84 @@
+package handler
+
+import (
+	"net/http"
+
+	api "github.com/gxravel/bus-routes/internal/api/http"
+	httpv1 "github.com/gxravel/bus-routes/internal/api/http/handler/v1"
+	"github.com/gxravel/bus-routes/internal/busroutescontext"
+	ierr "github.com/gxravel/bus-routes/internal/errors"
+)
+
+var (
+	errMustProvideOldPassword = ierr.NewReason(ierr.ErrMustProvide).WithMessage("old_password")
+	errInvalidNewPassword     = ierr.NewReason(ierr.ErrValidationFailed).WithMessage("invalid new_password: min length - 4")
+)
+
+func (s *Server) getMe(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	user, err := s.busroutes.GetUser(ctx, busroutescontext.GetUser(ctx).ID)
+	if err != nil {
+		api.RespondError(ctx, w, err)
+		return
+	}
+
+	api.RespondDataOK(ctx, w, user)
+}
+
+// changeMyPassword changes the password of the user, who gets the new token as the former ones are revoked.
+// The wrong old passwords are limited as the failed logins are.
+func (s *Server) changeMyPassword(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	var change = &httpv1.PasswordChange{}
+	if err := s.processRequest(r, change); err != nil {
+		api.RespondError(ctx, w, err)
+		return
+	}
+	if change.OldPassword == "" {
+		api.RespondError(ctx, w, errMustProvideOldPassword)
+		return
+	}
+	if !regPass.MatchString(change.NewPassword) {
+		api.RespondError(ctx, w, errInvalidNewPassword)
+		return
+	}
+
+	user := busroutescontext.GetUser(ctx)
+
+	attempt, err := s.busroutes.CheckLoginAttempts(ctx, user.Email, api.ClientIP(r, s.proxies))
+	if err != nil {
+		api.RespondError(ctx, w, err)
+		return
+	}
+
+	token, err := s.busroutes.ChangeUserPassword(ctx, user.ID, change)
+	if err != nil {
+		if reason := ierr.ConvertToReason(err); reason.Err == ierr.ErrWrongCredentials {
+			if err := s.busroutes.FailLogin(ctx, attempt); err != nil {
+				s.logger.WithErr(err).Error("count failed login")
+			}
+		}
+
+		api.RespondError(ctx, w, err)
+		return
+	}
+
+	if err := s.busroutes.ResetLoginAttempts(ctx, attempt); err != nil {
+		s.logger.WithErr(err).Error("reset login attempts")
+	}
+
+	api.RespondDataOK(ctx, w, token)
+}
+
+func (s *Server) deleteMe(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	if err := s.busroutes.DeleteUser(ctx, busroutescontext.GetUser(ctx).ID); err != nil {
+		api.RespondError(ctx, w, err)
+		return
+	}
+
+	api.RespondNoContent(w)
+}
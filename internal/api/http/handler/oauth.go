@@ -0,0 +1,105 @@
+package handler
+
+import (
+	"net/http"
+	"net/url"
+
+	api "github.com/gxravel/bus-routes/internal/api/http"
+	httpv1 "github.com/gxravel/bus-routes/internal/api/http/handler/v1"
+	ierr "github.com/gxravel/bus-routes/internal/errors"
+)
+
+const grantTypeClientCredentials = "client_credentials"
+
+var (
+	errMustProvideGrantType         = ierr.NewReason(ierr.ErrMustProvide).WithMessage("grant_type")
+	errMustProvideClientCredentials = ierr.NewReason(ierr.ErrInvalidAPIKey).WithMessage("must provide client credentials")
+)
+
+// oauthToken issues the access token by the grant of OAuth 2.0 (RFC 6749), only client_credentials is supported.
+// The client authenticates with HTTP Basic or with client_id and client_secret in the form.
+func (s *Server) oauthToken(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	w.Header().Set(api.HeaderCacheControl, "no-store")
+	w.Header().Set("Pragma", "no-cache")
+
+	if err := r.ParseForm(); err != nil {
+		api.RespondOAuthError(ctx, w, ierr.NewReason(ierr.ErrBadRequest).WithMessage(err.Error()))
+		return
+	}
+
+	switch r.PostForm.Get("grant_type") {
+	case grantTypeClientCredentials:
+	case "":
+		api.RespondOAuthError(ctx, w, errMustProvideGrantType)
+		return
+	default:
+		api.RespondOAuthError(ctx, w, ierr.ErrUnsupportedGrantType)
+		return
+	}
+
+	credentials, err := clientCredentials(r)
+	if err != nil {
+		api.RespondOAuthError(ctx, w, err)
+		return
+	}
+
+	token, err := s.busroutes.IssueClientToken(ctx, credentials)
+	if err != nil {
+		api.RespondOAuthError(ctx, w, err)
+		return
+	}
+
+	api.RespondJSON(ctx, w, http.StatusOK, token)
+}
+
+// clientCredentials returns the credentials of the client from Authorization header or from the form.
+func clientCredentials(r *http.Request) (*httpv1.ClientCredentials, error) {
+	var credentials = &httpv1.ClientCredentials{
+		Scope: r.PostForm.Get("scope"),
+	}
+
+	if id, secret, ok := r.BasicAuth(); ok {
+		var err error
+		if credentials.ClientID, err = url.QueryUnescape(id); err != nil {
+			return nil, errMustProvideClientCredentials
+		}
+		if credentials.ClientSecret, err = url.QueryUnescape(secret); err != nil {
+			return nil, errMustProvideClientCredentials
+		}
+	} else {
+		credentials.ClientID = r.PostForm.Get("client_id")
+		credentials.ClientSecret = r.PostForm.Get("client_secret")
+	}
+
+	if credentials.ClientID == "" || credentials.ClientSecret == "" {
+		return nil, errMustProvideClientCredentials
+	}
+
+	return credentials, nil
+}
+
+// introspectToken returns the state of the access token (RFC 7662).
+func (s *Server) introspectToken(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	if err := r.ParseForm(); err != nil {
+		api.RespondOAuthError(ctx, w, ierr.NewReason(ierr.ErrBadRequest).WithMessage(err.Error()))
+		return
+	}
+
+	token := r.PostForm.Get("token")
+	if token == "" {
+		api.RespondOAuthError(ctx, w, errMustProvideToken)
+		return
+	}
+
+	introspection, err := s.busroutes.IntrospectToken(ctx, token)
+	if err != nil {
+		api.RespondOAuthError(ctx, w, err)
+		return
+	}
+
+	api.RespondJSON(ctx, w, http.StatusOK, introspection)
+}
@@ -10,6 +10,7 @@ import (
 
 var (
 	errMustProvideRoute = ierr.NewReason(ierr.ErrMustProvide).WithMessage("route")
+	errMustProvideBusID = ierr.NewReason(ierr.ErrMustProvide).WithMessage("bus_id")
 )
 
 func (s *Server) getRoutes(w http.ResponseWriter, r *http.Request) {
@@ -34,6 +35,7 @@ func (s *Server) getRoutes(w http.ResponseWriter, r *http.Request) {
 }
 
 // getDetailedRoutes returns the routes detailed view: city, address, number instead of ids.
+// The routes are returned as GeoJSON lines if requested.
 func (s *Server) getDetailedRoutes(w http.ResponseWriter, r *http.Request) {
 	ctx := r.Context()
 
@@ -43,12 +45,23 @@ func (s *Server) getDetailedRoutes(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	format, err := api.ParseResponseFormat(r)
+	if err != nil {
+		api.RespondError(ctx, w, err)
+		return
+	}
+
 	routes, err := s.busroutes.GetDetailedRoutes(ctx, routeFilter)
 	if err != nil {
 		api.RespondError(ctx, w, err)
 		return
 	}
 
+	if format == api.MIMEApplicationGeoJSON {
+		api.RespondGeoJSON(ctx, w, routesToGeoJSON(routes))
+		return
+	}
+
 	api.RespondDataOK(ctx, w, httpv1.RangeItemsResponse{
 		Items: routes,
 		Total: int64(len(routes)),
@@ -113,3 +126,82 @@ func (s *Server) deleteRoute(w http.ResponseWriter, r *http.Request) {
 
 	api.RespondNoContent(w)
 }
+
+// insertRouteStop inserts the stop into the route shifting the later steps, and returns the resulting route.
+func (s *Server) insertRouteStop(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	busID, err := api.ParseURLParamInt64(r, "bus_id")
+	if err != nil || busID == 0 {
+		api.RespondError(ctx, w, errMustProvideBusID)
+		return
+	}
+
+	var insert = &httpv1.RouteStopInsert{}
+	if err := s.processRequest(r, insert); err != nil {
+		api.RespondError(ctx, w, err)
+		return
+	}
+	if insert.StopID == 0 {
+		api.RespondError(ctx, w, errMustProvideStopID)
+		return
+	}
+
+	route, err := s.busroutes.InsertRouteStop(ctx, busID, insert)
+	if err != nil {
+		api.RespondError(ctx, w, err)
+		return
+	}
+
+	api.RespondDataOK(ctx, w, route)
+}
+
+// removeRouteStop removes the stop from the route shifting the later steps, and returns the resulting route.
+func (s *Server) removeRouteStop(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	busID, err := api.ParseURLParamInt64(r, "bus_id")
+	if err != nil || busID == 0 {
+		api.RespondError(ctx, w, errMustProvideBusID)
+		return
+	}
+
+	var remove = &httpv1.RouteStopRemove{}
+	if err := s.processRequest(r, remove); err != nil {
+		api.RespondError(ctx, w, err)
+		return
+	}
+
+	route, err := s.busroutes.RemoveRouteStop(ctx, busID, remove)
+	if err != nil {
+		api.RespondError(ctx, w, err)
+		return
+	}
+
+	api.RespondDataOK(ctx, w, route)
+}
+
+// reorderRouteStops renumbers the route steps in the given order, and returns the resulting route.
+func (s *Server) reorderRouteStops(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	busID, err := api.ParseURLParamInt64(r, "bus_id")
+	if err != nil || busID == 0 {
+		api.RespondError(ctx, w, errMustProvideBusID)
+		return
+	}
+
+	var reorder = &httpv1.RouteStopsReorder{}
+	if err := s.processRequest(r, reorder); err != nil {
+		api.RespondError(ctx, w, err)
+		return
+	}
+
+	route, err := s.busroutes.ReorderRouteStops(ctx, busID, reorder)
+	if err != nil {
+		api.RespondError(ctx, w, err)
+		return
+	}
+
+	api.RespondDataOK(ctx, w, route)
+}
@@ -5,6 +5,7 @@ import (
 	"net/http"
 
 	"github.com/gxravel/bus-routes/assets"
+	api "github.com/gxravel/bus-routes/internal/api/http"
 	mw "github.com/gxravel/bus-routes/internal/api/http/middleware"
 	"github.com/gxravel/bus-routes/internal/busroutes"
 	"github.com/gxravel/bus-routes/internal/config"
@@ -18,13 +19,20 @@ type Server struct {
 	*http.Server
 	logger    log.Logger
 	busroutes *busroutes.BusRoutes
+	// proxies are the trusted proxies, the client IP is taken from their headers.
+	proxies api.TrustedProxies
 }
 
 func NewServer(
 	cfg *config.Config,
 	busroutes *busroutes.BusRoutes,
 	logger log.Logger,
-) *Server {
+) (*Server, error) {
+	proxies, err := api.ParseTrustedProxies(cfg.API.TrustedProxies)
+	if err != nil {
+		return nil, err
+	}
+
 	srv := &Server{
 		Server: &http.Server{
 			Addr:         cfg.API.Address,
@@ -33,61 +41,177 @@ func NewServer(
 		},
 		logger:    logger.WithModule("api:http"),
 		busroutes: busroutes,
+		proxies:   proxies,
 	}
 
 	r := chi.NewRouter()
 
 	r.Use(mw.Logger(srv.logger))
 	r.Use(mw.Recoverer)
+	r.Use(mw.Client(srv.proxies))
 
 	if cfg.API.ServeSwagger {
 		registerSwagger(r)
 	}
 
+	r.Get("/.well-known/jwks.json", srv.getJWKS)
+
+	r.Route("/oauth", func(r chi.Router) {
+		r.Post("/token", srv.oauthToken)
+		r.Group(func(r chi.Router) {
+			r.Use(
+				mw.RegisterPermissions(model.PermissionTokensIntrospect),
+				mw.Auth(srv.busroutes),
+			)
+			r.Post("/introspect", srv.introspectToken)
+		})
+	})
+
 	r.Route("/api", func(r chi.Router) {
 		r.Route("/v1", func(r chi.Router) {
 			r.Route("/auth", func(r chi.Router) {
 				r.Post("/signup", srv.signup)
 				r.Post("/login", srv.login)
+				r.Post("/login:2fa", srv.loginTwoFactor)
+				r.Post("/refresh", srv.refreshToken)
+				r.Post("/email:verify", srv.verifyEmail)
+				r.Post("/password:forgot", srv.forgotPassword)
+				r.Post("/password:reset", srv.resetPassword)
+				r.Group(func(r chi.Router) {
+					r.Use(mw.Auth(srv.busroutes))
+					r.Post("/logout", srv.logout)
+					r.Post("/logout:all", srv.logoutEverywhere)
+					r.Post("/email:resend", srv.resendVerification)
+				})
+			})
+			r.Route("/users", func(r chi.Router) {
+				r.Use(
+					mw.RegisterPermissions(model.PermissionUsersAdmin),
+					mw.Auth(srv.busroutes),
+				)
+				r.Get("/", srv.getUsers)
+				r.Get("/lockouts", srv.getLoginLockouts)
+				r.Delete("/lockouts", srv.unlockLogin)
+				r.Get("/{id}", srv.getUser)
+				r.Put("/{id}/type", srv.changeUserType)
+				r.Put("/{id}/cities", srv.setUserCities)
+				r.Get("/{id}/keys", srv.getAPIKeys)
+				r.Post("/{id}/keys", srv.addAPIKey)
+				r.Delete("/{id}/keys/{key_id}", srv.revokeAPIKey)
+				r.Delete("/{id}/2fa", srv.resetUserTOTP)
+				r.Get("/{id}/sessions", srv.getUserSessions)
+				r.Delete("/{id}/sessions/{session_id}", srv.revokeUserSession)
+				r.Delete("/{id}", srv.deleteUser)
+			})
+			r.Route("/me", func(r chi.Router) {
+				r.Use(mw.Auth(srv.busroutes))
+				r.Get("/", srv.getMe)
+				r.Put("/password", srv.changeMyPassword)
+				r.Get("/2fa", srv.getMyTwoFactor)
+				r.Post("/2fa", srv.enrollMyTOTP)
+				r.Post("/2fa:confirm", srv.confirmMyTOTP)
+				r.Post("/2fa:disable", srv.disableMyTOTP)
+				r.Get("/sessions", srv.getMySessions)
+				r.Delete("/sessions/{session_id}", srv.revokeMySession)
+				r.Delete("/", srv.deleteMe)
 			})
 			r.Route("/cities", func(r chi.Router) {
 				r.Get("/", srv.getCities)
-				r.Post("/", srv.addCities)
-				r.Put("/", srv.updateCity)
-				r.Delete("/", srv.deleteCity)
+				r.Group(func(r chi.Router) {
+					r.Use(
+						mw.RegisterPermissions(model.PermissionCitiesWrite),
+						mw.Auth(srv.busroutes),
+					)
+					r.Post("/", srv.addCities)
+					r.Put("/", srv.updateCity)
+					r.Delete("/", srv.deleteCity)
+				})
 			})
 			r.Route("/buses", func(r chi.Router) {
 				r.Get("/", srv.getBuses)
 				r.With(
-					mw.RegisterUserTypes(model.UserAdmin),
+					mw.RegisterPermissions(model.PermissionBusesWrite),
 					mw.Auth(srv.busroutes),
 				).Post("/", srv.addBuses)
+				r.With(
+					mw.RegisterPermissions(model.PermissionRoutesWrite),
+					mw.Auth(srv.busroutes),
+				).Put("/{id}/route", srv.replaceBusRoute)
 			})
 			r.Route("/stops", func(r chi.Router) {
 				r.Get("/", srv.getStops)
-				r.Post("/", srv.addStops)
-				r.Put("/", srv.updateStop)
-				r.Delete("/", srv.deleteStop)
+				r.Get("/nearby", srv.getNearbyStops)
+				r.Group(func(r chi.Router) {
+					r.Use(
+						mw.RegisterPermissions(model.PermissionStopsWrite),
+						mw.Auth(srv.busroutes),
+					)
+					r.Post("/", srv.addStops)
+					r.Put("/", srv.updateStop)
+					r.Delete("/", srv.deleteStop)
+				})
 			})
 			r.Route("/routes", func(r chi.Router) {
 				r.Get("/", srv.getRoutes)
-				r.Post("/", srv.addRoutes)
-				r.Put("/", srv.updateRoute)
-				r.Delete("/", srv.deleteRoute)
+				r.Group(func(r chi.Router) {
+					r.Use(
+						mw.RegisterPermissions(model.PermissionRoutesWrite),
+						mw.Auth(srv.busroutes),
+					)
+					r.Post("/", srv.addRoutes)
+					r.Put("/", srv.updateRoute)
+					r.Delete("/", srv.deleteRoute)
+				})
 				r.Route("/detailed", func(r chi.Router) {
 					r.Use(
-						mw.RegisterUserTypes(model.UserService, model.UserAdmin),
+						mw.RegisterPermissions(model.PermissionRoutesDetailed),
 						mw.Auth(srv.busroutes),
 					)
 					r.Get("/", srv.getDetailedRoutes)
 				})
+				r.Route("/{bus_id}", func(r chi.Router) {
+					r.Use(
+						mw.RegisterPermissions(model.PermissionRoutesWrite),
+						mw.Auth(srv.busroutes),
+					)
+					r.Post("/stops:insert", srv.insertRouteStop)
+					r.Post("/stops:remove", srv.removeRouteStop)
+					r.Post("/stops:reorder", srv.reorderRouteStops)
+				})
+			})
+			r.Route("/journeys", func(r chi.Router) {
+				r.Get("/", srv.getJourneys)
+			})
+			r.Route("/timetables", func(r chi.Router) {
+				r.Get("/", srv.getTimetables)
+				r.With(
+					mw.RegisterPermissions(model.PermissionTimetablesWrite),
+					mw.Auth(srv.busroutes),
+				).Put("/", srv.setTimetable)
+			})
+			r.Route("/departures", func(r chi.Router) {
+				r.Get("/", srv.getDepartures)
+			})
+			r.Route("/import", func(r chi.Router) {
+				r.Use(
+					mw.RegisterPermissions(model.PermissionGTFSImport),
+					mw.Auth(srv.busroutes),
+				)
+				r.Post("/gtfs", srv.importGTFS)
+			})
+			r.Route("/export", func(r chi.Router) {
+				r.Use(
+					mw.RegisterPermissions(model.PermissionGTFSExport),
+					mw.Auth(srv.busroutes),
+				)
+				r.Get("/gtfs", srv.exportGTFS)
 			})
 		})
 	})
 
 	srv.Handler = r
 
-	return srv
+	return srv, nil
 }
 
 func registerSwagger(r *chi.Mux) {
@@ -0,0 +1,83 @@
+package handler
+
+import (
+	"net/http"
+
+	api "github.com/gxravel/bus-routes/internal/api/http"
+	"github.com/gxravel/bus-routes/internal/busroutescontext"
+	ierr "github.com/gxravel/bus-routes/internal/errors"
+)
+
+var (
+	errMustProvideSessionID = ierr.NewReason(ierr.ErrMustProvide).WithMessage("session id")
+)
+
+func (s *Server) getMySessions(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	sessions, err := s.busroutes.GetSessions(ctx, busroutescontext.GetUser(ctx).ID)
+	if err != nil {
+		api.RespondError(ctx, w, err)
+		return
+	}
+
+	api.RespondDataOK(ctx, w, sessions)
+}
+
+func (s *Server) revokeMySession(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	id := api.ParseURLParam(r, "session_id")
+	if id == "" {
+		api.RespondError(ctx, w, errMustProvideSessionID)
+		return
+	}
+
+	if err := s.busroutes.RevokeSession(ctx, busroutescontext.GetUser(ctx).ID, id); err != nil {
+		api.RespondError(ctx, w, err)
+		return
+	}
+
+	api.RespondNoContent(w)
+}
+
+func (s *Server) getUserSessions(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	id, err := api.ParseURLParamInt64(r, "id")
+	if err != nil || id == 0 {
+		api.RespondError(ctx, w, errMustProvideUserID)
+		return
+	}
+
+	sessions, err := s.busroutes.GetSessions(ctx, id)
+	if err != nil {
+		api.RespondError(ctx, w, err)
+		return
+	}
+
+	api.RespondDataOK(ctx, w, sessions)
+}
+
+func (s *Server) revokeUserSession(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	userID, err := api.ParseURLParamInt64(r, "id")
+	if err != nil || userID == 0 {
+		api.RespondError(ctx, w, errMustProvideUserID)
+		return
+	}
+
+	id := api.ParseURLParam(r, "session_id")
+	if id == "" {
+		api.RespondError(ctx, w, errMustProvideSessionID)
+		return
+	}
+
+	if err := s.busroutes.RevokeSession(ctx, userID, id); err != nil {
+		api.RespondError(ctx, w, err)
+		return
+	}
+
+	api.RespondNoContent(w)
+}
@@ -1,6 +1,8 @@
 package handler
 
 import (
+	"fmt"
+	"math"
 	"net/http"
 
 	api "github.com/gxravel/bus-routes/internal/api/http"
@@ -8,8 +10,18 @@ import (
 	ierr "github.com/gxravel/bus-routes/internal/errors"
 )
 
+const (
+	defaultNearbyRadius = 500
+	maxNearbyRadius     = 5000
+)
+
 var (
-	errMustProvideStop = ierr.NewReason(ierr.ErrMustProvide).WithMessage("stop")
+	errMustProvideStop     = ierr.NewReason(ierr.ErrMustProvide).WithMessage("stop")
+	errMustProvideLocation = ierr.NewReason(ierr.ErrMustProvide).WithMessage("lat and lon")
+	errInvalidLocation     = ierr.NewReason(ierr.ErrValidationFailed).
+				WithMessage("lat must be in [-90, 90], lon in [-180, 180]")
+	errInvalidRadius = ierr.NewReason(ierr.ErrValidationFailed).
+				WithMessage(fmt.Sprintf("radius must be in (0, %d]", maxNearbyRadius))
 )
 
 func (s *Server) getStops(w http.ResponseWriter, r *http.Request) {
@@ -21,12 +33,65 @@ func (s *Server) getStops(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	format, err := api.ParseResponseFormat(r)
+	if err != nil {
+		api.RespondError(ctx, w, err)
+		return
+	}
+
 	stops, err := s.busroutes.GetStops(ctx, stopFilter)
 	if err != nil {
 		api.RespondError(ctx, w, err)
 		return
 	}
 
+	if format == api.MIMEApplicationGeoJSON {
+		api.RespondGeoJSON(ctx, w, stopsToGeoJSON(stops))
+		return
+	}
+
+	api.RespondDataOK(ctx, w, httpv1.RangeItemsResponse{
+		Items: stops,
+		Total: int64(len(stops)),
+	})
+}
+
+// getNearbyStops returns the stops within the radius around the location ordered by the distance.
+func (s *Server) getNearbyStops(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	filter, err := api.ParseNearbyStopFilter(r, defaultNearbyRadius)
+	if err != nil {
+		api.RespondError(ctx, w, err)
+		return
+	}
+
+	location := filter.Location
+	if location == nil {
+		api.RespondError(ctx, w, errMustProvideLocation)
+		return
+	}
+	if math.Abs(location.Lat) > 90 || math.Abs(location.Lon) > 180 {
+		api.RespondError(ctx, w, errInvalidLocation)
+		return
+	}
+	if location.Radius <= 0 || location.Radius > maxNearbyRadius {
+		api.RespondError(ctx, w, errInvalidRadius)
+		return
+	}
+
+	paginator, err := api.ParsePaginator(r)
+	if err != nil {
+		api.RespondError(ctx, w, err)
+		return
+	}
+
+	stops, err := s.busroutes.GetNearbyStops(ctx, filter, paginator)
+	if err != nil {
+		api.RespondError(ctx, w, err)
+		return
+	}
+
 	api.RespondDataOK(ctx, w, httpv1.RangeItemsResponse{
 		Items: stops,
 		Total: int64(len(stops)),
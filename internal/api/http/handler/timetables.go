@@ -0,0 +1,99 @@
+package handler
+
+import (
+	"net/http"
+	"time"
+
+	api "github.com/gxravel/bus-routes/internal/api/http"
+	httpv1 "github.com/gxravel/bus-routes/internal/api/http/handler/v1"
+	ierr "github.com/gxravel/bus-routes/internal/errors"
+)
+
+var (
+	errMustProvideTimetable = ierr.NewReason(ierr.ErrMustProvide).WithMessage("timetable")
+	errMustProvideStopID    = ierr.NewReason(ierr.ErrMustProvide).WithMessage("stop_id")
+)
+
+func (s *Server) getTimetables(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	filter, err := api.ParseTimetableFilter(r)
+	if err != nil {
+		api.RespondError(ctx, w, err)
+		return
+	}
+
+	timetables, err := s.busroutes.GetTimetables(ctx, filter)
+	if err != nil {
+		api.RespondError(ctx, w, err)
+		return
+	}
+
+	api.RespondDataOK(ctx, w, httpv1.RangeItemsResponse{
+		Items: timetables,
+		Total: int64(len(timetables)),
+	})
+}
+
+// setTimetable replaces the stop times and the trips of the bus.
+func (s *Server) setTimetable(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	var timetable = &httpv1.Timetable{}
+	if err := s.processRequest(r, timetable); err != nil {
+		api.RespondError(ctx, w, err)
+		return
+	}
+	if timetable.BusID == 0 {
+		api.RespondError(ctx, w, errMustProvideTimetable)
+		return
+	}
+
+	if err := s.busroutes.SetTimetable(ctx, timetable); err != nil {
+		api.RespondError(ctx, w, err)
+		return
+	}
+
+	api.RespondNoContent(w)
+}
+
+// getDepartures returns the next scheduled departures from the stop.
+func (s *Server) getDepartures(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	filter, err := api.ParseDepartureFilter(r)
+	if err != nil {
+		api.RespondError(ctx, w, err)
+		return
+	}
+	if len(filter.StopIDs) == 0 {
+		api.RespondError(ctx, w, errMustProvideStopID)
+		return
+	}
+
+	at, err := api.ParseQueryTime(r, "at")
+	if err != nil {
+		api.RespondError(ctx, w, err)
+		return
+	}
+	if at.IsZero() {
+		at = time.Now()
+	}
+
+	paginator, err := api.ParsePaginator(r)
+	if err != nil {
+		api.RespondError(ctx, w, err)
+		return
+	}
+
+	departures, err := s.busroutes.GetDepartures(ctx, filter, at, paginator)
+	if err != nil {
+		api.RespondError(ctx, w, err)
+		return
+	}
+
+	api.RespondDataOK(ctx, w, httpv1.RangeItemsResponse{
+		Items: departures,
+		Total: int64(len(departures)),
+	})
+}
@@ -0,0 +1,132 @@
+package handler
+
+import (
+	"net/http"
+
+	api "github.com/gxravel/bus-routes/internal/api/http"
+	httpv1 "github.com/gxravel/bus-routes/internal/api/http/handler/v1"
+	"github.com/gxravel/bus-routes/internal/busroutescontext"
+	ierr "github.com/gxravel/bus-routes/internal/errors"
+)
+
+var (
+	errMustProvideCode = ierr.NewReason(ierr.ErrMustProvide).WithMessage("code")
+)
+
+// loginTwoFactor completes the login of the user with 2FA enabled by the challenge and the code.
+func (s *Server) loginTwoFactor(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	var login = &httpv1.TwoFactorLogin{}
+	if err := s.processRequest(r, login); err != nil {
+		api.RespondError(ctx, w, err)
+		return
+	}
+	if login.Token == "" {
+		api.RespondError(ctx, w, errMustProvideToken)
+		return
+	}
+	if login.Code == "" {
+		api.RespondError(ctx, w, errMustProvideCode)
+		return
+	}
+
+	token, err := s.busroutes.LoginTwoFactor(ctx, login, api.ClientIP(r, s.proxies))
+	if err != nil {
+		api.RespondError(ctx, w, err)
+		return
+	}
+
+	api.RespondDataOK(ctx, w, token)
+}
+
+func (s *Server) getMyTwoFactor(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	twoFactor, err := s.busroutes.GetTwoFactor(ctx, busroutescontext.GetUser(ctx).ID)
+	if err != nil {
+		api.RespondError(ctx, w, err)
+		return
+	}
+
+	api.RespondDataOK(ctx, w, twoFactor)
+}
+
+// enrollMyTOTP generates the new TOTP secret, which has to be confirmed by the code to enable 2FA.
+func (s *Server) enrollMyTOTP(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	enrollment, err := s.busroutes.EnrollTOTP(ctx, busroutescontext.GetUser(ctx).ID)
+	if err != nil {
+		api.RespondError(ctx, w, err)
+		return
+	}
+
+	api.RespondDataOK(ctx, w, enrollment)
+}
+
+func (s *Server) confirmMyTOTP(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	var code = &httpv1.TwoFactorCode{}
+	if err := s.processRequest(r, code); err != nil {
+		api.RespondError(ctx, w, err)
+		return
+	}
+	if code.Code == "" {
+		api.RespondError(ctx, w, errMustProvideCode)
+		return
+	}
+
+	confirmation, err := s.busroutes.ConfirmTOTP(ctx, busroutescontext.GetUser(ctx).ID, code.Code, api.ClientIP(r, s.proxies))
+	if err != nil {
+		api.RespondError(ctx, w, err)
+		return
+	}
+
+	api.RespondDataOK(ctx, w, confirmation)
+}
+
+func (s *Server) disableMyTOTP(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	var code = &httpv1.TwoFactorCode{}
+	if err := s.processRequest(r, code); err != nil {
+		api.RespondError(ctx, w, err)
+		return
+	}
+	if code.Code == "" {
+		api.RespondError(ctx, w, errMustProvideCode)
+		return
+	}
+
+	token, err := s.busroutes.DisableTOTP(ctx, busroutescontext.GetUser(ctx).ID, code.Code, api.ClientIP(r, s.proxies))
+	if err != nil {
+		api.RespondError(ctx, w, err)
+		return
+	}
+
+	api.RespondDataOK(ctx, w, token)
+}
+
+// resetUserTOTP disables 2FA of the user, who has lost the authenticator and the recovery codes.
+func (s *Server) resetUserTOTP(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	id, err := api.ParseURLParamInt64(r, "id")
+	if err != nil || id == 0 {
+		api.RespondError(ctx, w, errMustProvideUserID)
+		return
+	}
+	if id == busroutescontext.GetUser(ctx).ID {
+		api.RespondError(ctx, w, errChangeOwnUser)
+		return
+	}
+
+	if err := s.busroutes.ResetTOTP(ctx, id); err != nil {
+		api.RespondError(ctx, w, err)
+		return
+	}
+
+	api.RespondNoContent(w)
+}
@@ -0,0 +1,181 @@
+package handler
+
+import (
+	"net/http"
+	"strings"
+
+	api "github.com/gxravel/bus-routes/internal/api/http"
+	httpv1 "github.com/gxravel/bus-routes/internal/api/http/handler/v1"
+	"github.com/gxravel/bus-routes/internal/busroutescontext"
+	ierr "github.com/gxravel/bus-routes/internal/errors"
+)
+
+var (
+	errMustProvideUserID   = ierr.NewReason(ierr.ErrMustProvide).WithMessage("user id")
+	errMustProvideUserType = ierr.NewReason(ierr.ErrMustProvide).WithMessage("type")
+	errChangeOwnUser       = ierr.NewReason(ierr.ErrValidationFailed).WithMessage("use /me to change your own account")
+	errMustProvideLockout  = ierr.NewReason(ierr.ErrMustProvide).WithMessage("email or ip")
+)
+
+func (s *Server) getUsers(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	filter, err := api.ParseUserFilter(r)
+	if err != nil {
+		api.RespondError(ctx, w, err)
+		return
+	}
+
+	users, err := s.busroutes.GetUsers(ctx, filter)
+	if err != nil {
+		api.RespondError(ctx, w, err)
+		return
+	}
+
+	api.RespondDataOK(ctx, w, httpv1.RangeItemsResponse{
+		Items: users,
+		Total: int64(len(users)),
+	})
+}
+
+func (s *Server) getUser(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	id, err := api.ParseURLParamInt64(r, "id")
+	if err != nil || id == 0 {
+		api.RespondError(ctx, w, errMustProvideUserID)
+		return
+	}
+
+	user, err := s.busroutes.GetUser(ctx, id)
+	if err != nil {
+		api.RespondError(ctx, w, err)
+		return
+	}
+
+	api.RespondDataOK(ctx, w, user)
+}
+
+// changeUserType changes the type of another user, e.g. promotes to admin.
+func (s *Server) changeUserType(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	id, err := api.ParseURLParamInt64(r, "id")
+	if err != nil || id == 0 {
+		api.RespondError(ctx, w, errMustProvideUserID)
+		return
+	}
+	if id == busroutescontext.GetUser(ctx).ID {
+		api.RespondError(ctx, w, errChangeOwnUser)
+		return
+	}
+
+	var change = &httpv1.UserTypeChange{}
+	if err := s.processRequest(r, change); err != nil {
+		api.RespondError(ctx, w, err)
+		return
+	}
+	if change.Type == "" {
+		api.RespondError(ctx, w, errMustProvideUserType)
+		return
+	}
+
+	user, err := s.busroutes.ChangeUserType(ctx, id, change.Type)
+	if err != nil {
+		api.RespondError(ctx, w, err)
+		return
+	}
+
+	api.RespondDataOK(ctx, w, user)
+}
+
+// setUserCities replaces the city scopes of the user, e.g. the cities of the editor.
+func (s *Server) setUserCities(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	id, err := api.ParseURLParamInt64(r, "id")
+	if err != nil || id == 0 {
+		api.RespondError(ctx, w, errMustProvideUserID)
+		return
+	}
+
+	var scopes = &httpv1.UserCities{}
+	if err := s.processRequest(r, scopes); err != nil {
+		api.RespondError(ctx, w, err)
+		return
+	}
+
+	user, err := s.busroutes.SetUserCities(ctx, id, scopes.Cities)
+	if err != nil {
+		api.RespondError(ctx, w, err)
+		return
+	}
+
+	api.RespondDataOK(ctx, w, user)
+}
+
+func (s *Server) deleteUser(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	id, err := api.ParseURLParamInt64(r, "id")
+	if err != nil || id == 0 {
+		api.RespondError(ctx, w, errMustProvideUserID)
+		return
+	}
+	if id == busroutescontext.GetUser(ctx).ID {
+		api.RespondError(ctx, w, errChangeOwnUser)
+		return
+	}
+
+	if err := s.busroutes.DeleteUser(ctx, id); err != nil {
+		api.RespondError(ctx, w, err)
+		return
+	}
+
+	api.RespondNoContent(w)
+}
+
+// getLoginLockouts returns the emails and the client IPs locked out after the failed logins.
+func (s *Server) getLoginLockouts(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	lockouts, err := s.busroutes.GetLoginLockouts(ctx)
+	if err != nil {
+		api.RespondError(ctx, w, err)
+		return
+	}
+
+	api.RespondDataOK(ctx, w, httpv1.RangeItemsResponse{
+		Items: lockouts,
+		Total: int64(len(lockouts)),
+	})
+}
+
+// unlockLogin lifts the lockout of the email or the client IP before it expires.
+func (s *Server) unlockLogin(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	email, err := api.ParseQueryParam(r, "email")
+	if err != nil {
+		api.RespondError(ctx, w, err)
+		return
+	}
+
+	ip, err := api.ParseQueryParam(r, "ip")
+	if err != nil {
+		api.RespondError(ctx, w, err)
+		return
+	}
+
+	if email == "" && ip == "" {
+		api.RespondError(ctx, w, errMustProvideLockout)
+		return
+	}
+
+	if err := s.busroutes.UnlockLogin(ctx, strings.ToLower(email), ip); err != nil {
+		api.RespondError(ctx, w, err)
+		return
+	}
+
+	api.RespondNoContent(w)
+}
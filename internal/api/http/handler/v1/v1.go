@@ -1,6 +1,8 @@
 package v1
 
 import (
+	"time"
+
 	"github.com/gxravel/bus-routes/internal/model"
 )
 
@@ -45,43 +47,350 @@ type City struct {
 
 // Stop describes http model of bus stop for api v1.
 type Stop struct {
-	ID      int64  `json:"id,omitempty"`
-	CityID  int    `json:"city_id,omitempty"`
-	Address string `json:"address"`
+	ID      int64    `json:"id,omitempty"`
+	CityID  int      `json:"city_id,omitempty"`
+	Address string   `json:"address"`
+	Lat     *float64 `json:"lat,omitempty"`
+	Lon     *float64 `json:"lon,omitempty"`
 
 	City string `json:"city,omitempty"`
 }
 
+// NearbyStop describes http model of bus stop with the distance to it in meters for api v1.
+type NearbyStop struct {
+	Stop
+	Distance float64 `json:"distance"`
+}
+
 // Route describes http model of route for api v1.
 type Route struct {
-	BusID  int64 `json:"bus_id"`
-	StopID int64 `json:"stop_id"`
-	Step   int8  `json:"step"`
+	BusID     int64           `json:"bus_id"`
+	Direction model.Direction `json:"direction,omitempty"`
+	Variant   string          `json:"variant,omitempty"`
+	StopID    int64           `json:"stop_id"`
+	Step      int             `json:"step"`
 }
 
 // RoutePoint describes a unit of route for a bus.
 type RoutePoint struct {
-	Step    int8   `json:"step"`
-	Address string `json:"address"`
+	Step    int      `json:"step"`
+	Address string   `json:"address"`
+	Lat     *float64 `json:"lat,omitempty"`
+	Lon     *float64 `json:"lon,omitempty"`
 }
 
 // RouteDetailed describes http model of detailed route for api v1.
 type RouteDetailed struct {
-	City   string       `json:"city"`
-	Bus    string       `json:"bus"`
-	Points []RoutePoint `json:"points"`
+	City      string          `json:"city"`
+	Bus       string          `json:"bus"`
+	Direction model.Direction `json:"direction"`
+	Variant   string          `json:"variant,omitempty"`
+	Points    []RoutePoint    `json:"points"`
+}
+
+// RouteStopInsert describes http model of inserting a stop into a route for api v1.
+type RouteStopInsert struct {
+	Direction model.Direction `json:"direction,omitempty"`
+	Variant   string          `json:"variant,omitempty"`
+	StopID    int64           `json:"stop_id"`
+	Step      int             `json:"step"`
+}
+
+// RouteStopRemove describes http model of removing a stop from a route for api v1.
+type RouteStopRemove struct {
+	Direction model.Direction `json:"direction,omitempty"`
+	Variant   string          `json:"variant,omitempty"`
+	Step      int             `json:"step"`
+}
+
+// RouteStopsReorder describes http model of reordering the stops of a route for api v1.
+// Steps lists all the current steps of the route in the new order.
+type RouteStopsReorder struct {
+	Direction model.Direction `json:"direction,omitempty"`
+	Variant   string          `json:"variant,omitempty"`
+	Steps     []int           `json:"steps"`
+}
+
+// RouteReplace describes http model of replacing the whole route of a bus for api v1.
+type RouteReplace struct {
+	Direction model.Direction `json:"direction,omitempty"`
+	Variant   string          `json:"variant,omitempty"`
+	StopIDs   []int64         `json:"stop_ids"`
+}
+
+// JourneyLeg describes a part of journey made by one bus.
+type JourneyLeg struct {
+	BusID       int64           `json:"bus_id"`
+	Bus         string          `json:"bus"`
+	Direction   model.Direction `json:"direction"`
+	Variant     string          `json:"variant,omitempty"`
+	FromStopID  int64           `json:"from_stop_id"`
+	FromAddress string          `json:"from_address"`
+	ToStopID    int64           `json:"to_stop_id"`
+	ToAddress   string          `json:"to_address"`
+	Stops       int             `json:"stops"`
+}
+
+// Journey describes http model of stop-to-stop itinerary for api v1.
+type Journey struct {
+	Transfers int          `json:"transfers"`
+	Stops     int          `json:"stops"`
+	Legs      []JourneyLeg `json:"legs"`
+}
+
+// StopTime describes http model of arrival and departure at the route step for api v1.
+// Arrival and departure are the numbers of seconds since the trip start.
+type StopTime struct {
+	Step      int `json:"step"`
+	Arrival   int `json:"arrival"`
+	Departure int `json:"departure"`
+}
+
+// Trip describes http model of scheduled trip for api v1.
+type Trip struct {
+	ID        int64    `json:"id,omitempty"`
+	StartTime string   `json:"start_time"`
+	Days      []string `json:"days"`
+}
+
+// Timetable describes http model of bus timetable for api v1.
+type Timetable struct {
+	BusID     int64           `json:"bus_id"`
+	Direction model.Direction `json:"direction,omitempty"`
+	Variant   string          `json:"variant,omitempty"`
+	StopTimes []StopTime      `json:"stop_times"`
+	Trips     []Trip          `json:"trips"`
+}
+
+// Departure describes http model of scheduled departure from a stop for api v1.
+type Departure struct {
+	BusID     int64           `json:"bus_id"`
+	Bus       string          `json:"bus"`
+	Direction model.Direction `json:"direction"`
+	Variant   string          `json:"variant,omitempty"`
+	TripID    int64           `json:"trip_id"`
+	Step      int             `json:"step"`
+	Time      time.Time       `json:"time"`
+}
+
+// GTFSImportCounts describes the numbers of imported entities of one kind.
+type GTFSImportCounts struct {
+	Created int `json:"created"`
+	Updated int `json:"updated"`
+	Skipped int `json:"skipped"`
+}
+
+// GTFSImportReport describes http model of GTFS feed import result for api v1.
+type GTFSImportReport struct {
+	City    string           `json:"city"`
+	Cities  GTFSImportCounts `json:"cities"`
+	Stops   GTFSImportCounts `json:"stops"`
+	Buses   GTFSImportCounts `json:"buses"`
+	Routes  GTFSImportCounts `json:"routes"`
+	Skipped []string         `json:"skipped"`
+	// DroppedTimetables are the route patterns, which timetables are deleted as their stops are changed.
+	DroppedTimetables []string `json:"dropped_timetables"`
+}
+
+// GeoJSON object types.
+const (
+	GeoJSONFeatureCollection = "FeatureCollection"
+	GeoJSONFeature           = "Feature"
+	GeoJSONPoint             = "Point"
+	GeoJSONLineString        = "LineString"
+)
+
+// FeatureCollection describes GeoJSON feature collection for api v1.
+type FeatureCollection struct {
+	Type     string     `json:"type"`
+	Features []*Feature `json:"features"`
+}
+
+// Feature describes GeoJSON feature for api v1. Geometry is null if the location is unknown.
+type Feature struct {
+	Type       string                 `json:"type"`
+	ID         interface{}            `json:"id,omitempty"`
+	Geometry   *Geometry              `json:"geometry"`
+	Properties map[string]interface{} `json:"properties"`
+}
+
+// Geometry describes GeoJSON geometry for api v1.
+// Coordinates are [lon, lat] for Point and the list of them for LineString.
+type Geometry struct {
+	Type        string      `json:"type"`
+	Coordinates interface{} `json:"coordinates"`
 }
 
 // User describes http model of user for api v1.
 type User struct {
-	ID       int64          `json:"id,omitempty"`
-	Email    string         `json:"email"`
-	Password string         `json:"password,omitempty"`
-	Type     model.UserType `json:"type,omitempty"`
+	ID        int64          `json:"id,omitempty"`
+	Email     string         `json:"email"`
+	Password  string         `json:"password,omitempty"`
+	Type      model.UserType `json:"type,omitempty"`
+	Cities    []string       `json:"cities,omitempty"`
+	Verified  bool           `json:"verified"`
+	TwoFactor bool           `json:"two_factor"`
+
+	// Scopes limit the permissions of the request authorized by API key or the token of OAuth 2.0 client.
+	Scopes []model.Permission `json:"scopes,omitempty"`
 }
 
-// Token describes http model of JWT token for api v1.
-type Token struct {
+// EmailVerify describes http model of the request to verify the email of the user for api v1.
+type EmailVerify struct {
+	Token string `json:"token"`
+}
+
+// PasswordForgot describes http model of the request to send the password reset email for api v1.
+type PasswordForgot struct {
+	Email string `json:"email"`
+}
+
+// PasswordReset describes http model of the request to set the new password by the reset token for api v1.
+type PasswordReset struct {
+	Token       string `json:"token"`
+	NewPassword string `json:"new_password"`
+}
+
+// TwoFactor describes http model of the two-factor authentication state of the user for api v1.
+type TwoFactor struct {
+	Enabled           bool `json:"enabled"`
+	RecoveryCodesLeft int  `json:"recovery_codes_left"`
+}
+
+// TOTPEnrollment describes http model of the new TOTP secret of the user for api v1.
+type TOTPEnrollment struct {
+	Secret string `json:"secret"`
+	URI    string `json:"uri"`
+}
+
+// TwoFactorCode describes http model of the request with TOTP or recovery code for api v1.
+type TwoFactorCode struct {
+	Code string `json:"code"`
+}
+
+// TwoFactorConfirmation describes http model of the enabled two-factor authentication for api v1.
+// The recovery codes are returned only once, the former tokens of the user are revoked.
+type TwoFactorConfirmation struct {
+	RecoveryCodes []string `json:"recovery_codes"`
+	Token         *Token   `json:"token"`
+}
+
+// TwoFactorChallenge describes http model of the login waiting for the second factor for api v1.
+type TwoFactorChallenge struct {
 	Token  string `json:"token"`
 	Expiry int64  `json:"expiry"`
 }
+
+// TwoFactorLogin describes http model of the request to complete the login with the second factor for api v1.
+type TwoFactorLogin struct {
+	Token string `json:"token"`
+	Code  string `json:"code"`
+}
+
+// UserTypeChange describes http model of the request to change the user type for api v1.
+type UserTypeChange struct {
+	Type model.UserType `json:"type"`
+}
+
+// UserCities describes http model of the request to set the city scopes of the user for api v1.
+type UserCities struct {
+	Cities []string `json:"cities"`
+}
+
+// APIKey describes http model of API key of the service user for api v1.
+type APIKey struct {
+	ID       int64              `json:"id"`
+	Name     string             `json:"name"`
+	Prefix   string             `json:"prefix"`
+	Scopes   []model.Permission `json:"scopes"`
+	Expiry   *int64             `json:"expiry,omitempty"`
+	Created  int64              `json:"created"`
+	LastUsed *int64             `json:"last_used,omitempty"`
+
+	// Key is returned only once, when the key is created.
+	Key string `json:"key,omitempty"`
+}
+
+// APIKeyCreate describes http model of the request to create API key for api v1.
+type APIKeyCreate struct {
+	Name   string             `json:"name"`
+	Scopes []model.Permission `json:"scopes"`
+	Expiry *int64             `json:"expiry,omitempty"`
+}
+
+// LoginLockout describes http model of the email or the client IP locked out after the failed logins for api v1.
+type LoginLockout struct {
+	Email string `json:"email,omitempty"`
+	IP    string `json:"ip,omitempty"`
+	Until int64  `json:"until"`
+}
+
+// PasswordChange describes http model of the request to change the password for api v1.
+type PasswordChange struct {
+	OldPassword string `json:"old_password"`
+	NewPassword string `json:"new_password"`
+}
+
+// Session describes http model of the session of the user on one device for api v1.
+type Session struct {
+	ID        string `json:"id"`
+	IssuedAt  int64  `json:"issued_at,omitempty"`
+	LastUsed  int64  `json:"last_used,omitempty"`
+	UserAgent string `json:"user_agent,omitempty"`
+	IP        string `json:"ip,omitempty"`
+	// APIKeyID is set for the token of OAuth 2.0 client.
+	APIKeyID int64 `json:"api_key_id,omitempty"`
+	// Current is set for the session of the request.
+	Current bool `json:"current"`
+}
+
+// ClientCredentials describes the request of OAuth 2.0 client credentials grant (RFC 6749, section 4.4).
+// The client is the API key, ClientID is its id and ClientSecret is the key itself.
+type ClientCredentials struct {
+	ClientID     string
+	ClientSecret string
+	Scope        string
+}
+
+// OAuthToken describes the access token response of OAuth 2.0 (RFC 6749, section 5.1).
+type OAuthToken struct {
+	AccessToken string `json:"access_token"`
+	TokenType   string `json:"token_type"`
+	ExpiresIn   int64  `json:"expires_in"`
+	Scope       string `json:"scope"`
+}
+
+// OAuthError describes the error response of OAuth 2.0 (RFC 6749, section 5.2).
+type OAuthError struct {
+	Error       string `json:"error"`
+	Description string `json:"error_description,omitempty"`
+}
+
+// TokenIntrospection describes the token introspection response (RFC 7662, section 2.2).
+// Only Active is set for the invalid, expired or revoked token.
+type TokenIntrospection struct {
+	Active bool   `json:"active"`
+	Scope  string `json:"scope,omitempty"`
+	// Cities are the cities the write permissions of the city-scoped user are limited to.
+	Cities    []string `json:"cities,omitempty"`
+	ClientID  string   `json:"client_id,omitempty"`
+	Username  string   `json:"username,omitempty"`
+	TokenType string   `json:"token_type,omitempty"`
+	Exp       int64    `json:"exp,omitempty"`
+	Iat       int64    `json:"iat,omitempty"`
+	Sub       string   `json:"sub,omitempty"`
+	Jti       string   `json:"jti,omitempty"`
+}
+
+// Token describes http model of JWT token for api v1.
+type Token struct {
+	Token         string `json:"token"`
+	Expiry        int64  `json:"expiry"`
+	RefreshToken  string `json:"refresh_token"`
+	RefreshExpiry int64  `json:"refresh_expiry"`
+}
+
+// TokenRefresh describes http model of the request to refresh the JWT token for api v1.
+type TokenRefresh struct {
+	RefreshToken string `json:"refresh_token"`
+}
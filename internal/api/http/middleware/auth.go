@@ -6,38 +6,59 @@ import (
 	"strings"
 
 	api "github.com/gxravel/bus-routes/internal/api/http"
+	httpv1 "github.com/gxravel/bus-routes/internal/api/http/handler/v1"
 	"github.com/gxravel/bus-routes/internal/busroutes"
 	"github.com/gxravel/bus-routes/internal/busroutescontext"
+	ierr "github.com/gxravel/bus-routes/internal/errors"
 	"github.com/gxravel/bus-routes/internal/model"
 )
 
-// RegisterUserTypes adds to request's context allowed user types.
-func RegisterUserTypes(types ...model.UserType) func(http.Handler) http.Handler {
+// RegisterPermissions adds to request's context the permissions required from the user.
+// Auth lets any authenticated user in without them, except the API keys and the tokens limited to the scopes.
+func RegisterPermissions(permissions ...model.Permission) func(http.Handler) http.Handler {
 	return func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 			ctx := r.Context()
-			ctx = context.WithValue(ctx, busroutescontext.UserTypesKey, model.UserTypes(types))
+			ctx = context.WithValue(ctx, busroutescontext.PermissionsKey, permissions)
 
 			next.ServeHTTP(w, r.WithContext(ctx))
 		})
 	}
 }
 
-// Auth searches user by token and adds his data to context.
+// Auth searches user by API key or token and adds his data to context.
+// The token of the request authorized by API key is empty.
+// The user with unverified email is denied the write requests requiring the permissions.
 func Auth(busroutes *busroutes.BusRoutes) func(http.Handler) http.Handler {
 	return func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 			ctx := r.Context()
 
-			allowedUserTypes := busroutescontext.GetUserTypes(ctx)
-			token := getAuthToken(r)
+			var (
+				permissions = busroutescontext.GetPermissions(ctx)
+				token       string
+				user        *httpv1.User
+				err         error
+			)
 
-			user, err := busroutes.GetUserByToken(ctx, token, allowedUserTypes...)
+			if key := r.Header.Get(APIKeyHeader); key != "" {
+				user, err = busroutes.GetUserByAPIKey(ctx, key, permissions...)
+			} else {
+				token = getAuthToken(r)
+				user, err = busroutes.GetUserByToken(ctx, token, permissions...)
+			}
 			if err != nil {
 				api.RespondError(ctx, w, err)
 				return
 			}
 
+			// the user with unverified email has read-only access,
+			// the API keys and the tokens of OAuth 2.0 clients limited to the scopes are issued by the admins, so they are not.
+			if len(user.Scopes) == 0 && !user.Verified && len(permissions) > 0 && !isReadOnly(r.Method) {
+				api.RespondError(ctx, w, errEmailNotVerified)
+				return
+			}
+
 			ctx = context.WithValue(ctx, busroutescontext.UserKey, user)
 			ctx = context.WithValue(ctx, busroutescontext.TokenKey, token)
 
@@ -50,8 +71,16 @@ func Auth(busroutes *busroutes.BusRoutes) func(http.Handler) http.Handler {
 const (
 	// AuthHeader is a header used to find token of user.
 	AuthHeader = "Authorization"
+	// APIKeyHeader is a header used to find API key of service user.
+	APIKeyHeader = "X-API-Key"
 )
 
+var errEmailNotVerified = ierr.NewReason(ierr.ErrPermissionDenied).WithMessage("email is not verified, the access is read-only")
+
+func isReadOnly(method string) bool {
+	return method == http.MethodGet || method == http.MethodHead
+}
+
 func getAuthToken(r *http.Request) string {
 	tokens, ok := r.Header[AuthHeader]
 	if ok {
@@ -0,0 +1,178 @@
+package middleware
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gxravel/bus-routes/internal/apikey"
+	"github.com/gxravel/bus-routes/internal/busroutes"
+	"github.com/gxravel/bus-routes/internal/config"
+	"github.com/gxravel/bus-routes/internal/dataprovider"
+	"github.com/gxravel/bus-routes/internal/jwt"
+	log "github.com/gxravel/bus-routes/internal/logger"
+	"github.com/gxravel/bus-routes/internal/model"
+)
+
+func TestAuthScopedAPIKey(t *testing.T) {
+	tests := []struct {
+		name string
+		// permissions are the ones the route registers.
+		permissions []model.Permission
+		wantStatus  int
+	}{
+		{
+			name:       "route without permissions like /me",
+			wantStatus: http.StatusForbidden,
+		},
+		{
+			name:        "permission in the scopes",
+			permissions: []model.Permission{model.PermissionRoutesDetailed},
+			wantStatus:  http.StatusOK,
+		},
+		{
+			name:        "permission out of the scopes",
+			permissions: []model.Permission{model.PermissionCitiesWrite},
+			wantStatus:  http.StatusForbidden,
+		},
+	}
+
+	br := busroutes.New(
+		nil, nil, log.Default(),
+		nil, nil, nil, nil,
+		fakeUserStore{user: &model.User{ID: 1, Email: "service@example.com", Type: model.UserService, Verified: true}},
+		nil,
+		fakeAPIKeyStore{key: &model.APIKey{ID: 1, UserID: 1, Prefix: "br_test", Scopes: model.Permissions{model.PermissionRoutesDetailed}}},
+		fakeTOTPStore{},
+		nil, nil,
+		fakeTracker{},
+		nil, nil,
+	)
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			handler := RegisterPermissions(tt.permissions...)(Auth(br)(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+				w.WriteHeader(http.StatusOK)
+			})))
+
+			req := httptest.NewRequest(http.MethodGet, "/api/v1/me", nil)
+			req.Header.Set(APIKeyHeader, "br_test_key")
+
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+
+			if rec.Code != tt.wantStatus {
+				t.Errorf("got status %d, want %d: %s", rec.Code, tt.wantStatus, rec.Body)
+			}
+		})
+	}
+}
+
+func TestAuthScopedToken(t *testing.T) {
+	var (
+		client = &jwt.User{ID: 1, Type: model.UserService, ClientID: "client", Scopes: []model.Permission{model.PermissionRoutesDetailed}}
+		editor = &jwt.User{ID: 2, Type: model.UserEditor, Cities: []string{"Moscow"}, Verified: true}
+	)
+
+	tests := []struct {
+		name string
+		user *jwt.User
+		// permissions are the ones the route registers.
+		permissions []model.Permission
+		wantStatus  int
+	}{
+		{
+			name:       "scoped token on route without permissions like /me",
+			user:       client,
+			wantStatus: http.StatusForbidden,
+		},
+		{
+			name:        "scoped token with permission in the scopes",
+			user:        client,
+			permissions: []model.Permission{model.PermissionRoutesDetailed},
+			wantStatus:  http.StatusOK,
+		},
+		{
+			name:       "user token on route without permissions",
+			user:       editor,
+			wantStatus: http.StatusOK,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			br := busroutes.New(
+				&config.Config{}, nil, log.Default(),
+				nil, nil, nil, nil, nil, nil, nil, nil, nil,
+				fakeTokenManager{user: tt.user},
+				nil, nil, nil,
+			)
+
+			handler := RegisterPermissions(tt.permissions...)(Auth(br)(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+				w.WriteHeader(http.StatusOK)
+			})))
+
+			req := httptest.NewRequest(http.MethodGet, "/api/v1/me", nil)
+			req.Header.Set(AuthHeader, "Bearer token")
+
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+
+			if rec.Code != tt.wantStatus {
+				t.Errorf("got status %d, want %d: %s", rec.Code, tt.wantStatus, rec.Body)
+			}
+		})
+	}
+}
+
+// fakeTokenManager verifies any token as the one of the user.
+type fakeTokenManager struct {
+	jwt.Manager
+	user *jwt.User
+}
+
+func (m fakeTokenManager) Verify(context.Context, string) (*jwt.User, error) {
+	user := *m.user
+	return &user, nil
+}
+
+// fakeAPIKeyStore finds the key by any filter.
+type fakeAPIKeyStore struct {
+	dataprovider.APIKeyStore
+	key *model.APIKey
+}
+
+func (s fakeAPIKeyStore) GetByFilter(context.Context, *dataprovider.APIKeyFilter) (*model.APIKey, error) {
+	return s.key, nil
+}
+
+// fakeUserStore finds the user by any filter, the user has no cities.
+type fakeUserStore struct {
+	dataprovider.UserStore
+	user *model.User
+}
+
+func (s fakeUserStore) GetListByFilter(context.Context, *dataprovider.UserFilter) ([]*model.User, error) {
+	user := *s.user
+	return []*model.User{&user}, nil
+}
+
+func (s fakeUserStore) GetCities(context.Context, ...int64) ([]*model.UserCity, error) {
+	return nil, nil
+}
+
+// fakeTOTPStore finds no 2FA secrets.
+type fakeTOTPStore struct {
+	dataprovider.TOTPStore
+}
+
+func (fakeTOTPStore) GetListByFilter(context.Context, *dataprovider.TOTPFilter) ([]*model.TOTP, error) {
+	return nil, nil
+}
+
+type fakeTracker struct {
+	apikey.Tracker
+}
+
+func (fakeTracker) Touch(context.Context, int64) error { return nil }
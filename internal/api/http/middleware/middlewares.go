@@ -1,9 +1,12 @@
 package middleware
 
 import (
+	"context"
 	"net/http"
 	"runtime/debug"
 
+	api "github.com/gxravel/bus-routes/internal/api/http"
+	"github.com/gxravel/bus-routes/internal/busroutescontext"
 	log "github.com/gxravel/bus-routes/internal/logger"
 )
 
@@ -18,6 +21,20 @@ func Logger(logger log.Logger) func(http.Handler) http.Handler {
 	}
 }
 
+// Client adds to request's context the client the tokens are issued to.
+func Client(proxies api.TrustedProxies) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			ctx := context.WithValue(r.Context(), busroutescontext.ClientKey, &busroutescontext.Client{
+				UserAgent: r.UserAgent(),
+				IP:        api.ClientIP(r, proxies),
+			})
+
+			next.ServeHTTP(w, r.WithContext(ctx))
+		})
+	}
+}
+
 func Recoverer(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		defer func() {
@@ -1,12 +1,18 @@
 package api
 
 import (
+	"fmt"
+	"net"
 	"net/http"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/gxravel/bus-routes/internal/dataprovider"
+	ierr "github.com/gxravel/bus-routes/internal/errors"
+	"github.com/gxravel/bus-routes/internal/model"
 
+	"github.com/go-chi/chi"
 	"github.com/pkg/errors"
 )
 
@@ -36,13 +42,17 @@ func parseQueryInt(r *http.Request, field string) (int, error) {
 	return int(result), nil
 }
 
-func parseQueryInt8(r *http.Request, field string) (int8, error) {
+// parseQueryStep parses query route step for specific field, 0 means the step is not set.
+func parseQueryStep(r *http.Request, field string) (int, error) {
 	result, err := parseQueryInt64(r, field)
 	if err != nil {
 		return 0, err
 	}
+	if result < 0 || result > model.MaxStep {
+		return 0, errInvalidStep(field)
+	}
 
-	return int8(result), nil
+	return int(result), nil
 }
 
 func parseQueryUint64(r *http.Request, field string) (uint64, error) {
@@ -54,6 +64,40 @@ func parseQueryUint64(r *http.Request, field string) (uint64, error) {
 	return uint64(result), nil
 }
 
+func parseQueryFloat64(r *http.Request, field string) (float64, error) {
+	value, err := ParseQueryParam(r, field)
+	if err != nil {
+		return 0, err
+	}
+	if value == "" {
+		return 0, nil
+	}
+
+	fv, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		return 0, errors.Errorf("%v it not a float", value)
+	}
+
+	return fv, nil
+}
+
+// ParseURLParam parses url param for specific field.
+func ParseURLParam(r *http.Request, field string) string {
+	return chi.URLParam(r, field)
+}
+
+// ParseURLParamInt64 parses int64 url param for specific field.
+func ParseURLParamInt64(r *http.Request, field string) (int64, error) {
+	value := chi.URLParam(r, field)
+
+	iv, err := strconv.ParseInt(value, 10, 64)
+	if err != nil {
+		return 0, errors.Errorf("%v it not an int", value)
+	}
+
+	return iv, nil
+}
+
 // ParseQueryParam parses query param for specific field.
 func ParseQueryParam(r *http.Request, field string) (string, error) {
 	q := r.URL.Query()
@@ -142,22 +186,50 @@ func ParseQueryIntSlice(r *http.Request, field string) ([]int, error) {
 	return result, nil
 }
 
-// ParseQueryUint8Slice parses query []uint8 for specific field.
-func ParseQueryUint8Slice(r *http.Request, field string) ([]int8, error) {
+// ParseQueryStepSlice parses query route steps for specific field.
+func ParseQueryStepSlice(r *http.Request, field string) ([]int, error) {
 	vals, err := ParseQueryInt64Slice(r, field)
 
 	if err != nil {
 		return nil, err
 	}
 
-	var result = make([]int8, 0, len(vals))
+	var result = make([]int, 0, len(vals))
 	for _, val := range vals {
-		result = append(result, int8(val))
+		if val < 1 || val > model.MaxStep {
+			return nil, errInvalidStep(field)
+		}
+		result = append(result, int(val))
 	}
 
 	return result, nil
 }
 
+func errInvalidStep(field string) error {
+	return ierr.NewReason(ierr.ErrValidationFailed).
+		WithMessage(fmt.Sprintf("%s must be in [1, %d]", field, model.MaxStep))
+}
+
+// ParseQueryDirections parses query []model.Direction for specific field.
+func ParseQueryDirections(r *http.Request, field string) ([]model.Direction, error) {
+	params, err := ParseQueryParams(r, field)
+	if err != nil {
+		return nil, err
+	}
+
+	var directions = make([]model.Direction, 0, len(params))
+	for _, param := range params {
+		direction := model.Direction(param)
+		if !direction.IsValid() {
+			return nil, errors.Errorf("%v is not a direction", param)
+		}
+
+		directions = append(directions, direction)
+	}
+
+	return directions, nil
+}
+
 // ParseBusFilter parses query 'ids', 'cities', 'nums', and returns the filter.
 func ParseBusFilter(r *http.Request) (*dataprovider.BusFilter, error) {
 	ids, err := ParseQueryInt64Slice(r, "ids")
@@ -244,6 +316,42 @@ func ParseStopFilter(r *http.Request) (*dataprovider.StopFilter, error) {
 		ByAddresses(addresses...), nil
 }
 
+// ParseNearbyStopFilter parses query 'lat', 'lon', 'radius', 'cities', and returns the filter.
+// The location is not set if 'lat' or 'lon' is missing; the radius is set to the default one if missing.
+func ParseNearbyStopFilter(r *http.Request, defaultRadius float64) (*dataprovider.StopFilter, error) {
+	cities, err := ParseQueryParams(r, "cities")
+	if err != nil {
+		return nil, err
+	}
+
+	filter := dataprovider.NewStopFilter().ByCities(cities...)
+
+	q := r.URL.Query()
+	if q.Get("lat") == "" || q.Get("lon") == "" {
+		return filter, nil
+	}
+
+	lat, err := parseQueryFloat64(r, "lat")
+	if err != nil {
+		return nil, err
+	}
+
+	lon, err := parseQueryFloat64(r, "lon")
+	if err != nil {
+		return nil, err
+	}
+
+	radius, err := parseQueryFloat64(r, "radius")
+	if err != nil {
+		return nil, err
+	}
+	if radius == 0 {
+		radius = defaultRadius
+	}
+
+	return filter.Near(lat, lon, radius), nil
+}
+
 // ParseDeleteStopFilter parses query 'id', 'address', and returns the filter.
 func ParseDeleteStopFilter(r *http.Request) (*dataprovider.StopFilter, error) {
 	id, err := parseQueryInt64(r, "id")
@@ -267,7 +375,7 @@ func ParseDeleteStopFilter(r *http.Request) (*dataprovider.StopFilter, error) {
 	return filter, nil
 }
 
-// ParseRouteFilter parses query 'bus_ids', 'stop_ids', 'steps', and returns the filter.
+// ParseRouteFilter parses query 'bus_ids', 'stop_ids', 'steps', 'directions', 'variants', and returns the filter.
 func ParseRouteFilter(r *http.Request) (*dataprovider.RouteFilter, error) {
 	busIDs, err := ParseQueryInt64Slice(r, "bus_ids")
 	if err != nil {
@@ -279,42 +387,77 @@ func ParseRouteFilter(r *http.Request) (*dataprovider.RouteFilter, error) {
 		return nil, err
 	}
 
-	steps, err := ParseQueryUint8Slice(r, "steps")
+	steps, err := ParseQueryStepSlice(r, "steps")
+	if err != nil {
+		return nil, err
+	}
+
+	directions, err := ParseQueryDirections(r, "directions")
+	if err != nil {
+		return nil, err
+	}
+
+	variants, err := ParseQueryParams(r, "variants")
 	if err != nil {
 		return nil, err
 	}
 
 	return dataprovider.NewRouteFilter().
 		ByBusIDs(busIDs...).
+		ByDirections(directions...).
+		ByVariants(variants...).
 		ByStopIDs(stopIDs...).
 		BySteps(steps...), nil
 }
 
-// ParseRouteDetailedFilter parses query 'bus_ids', and returns the filter.
+// ParseRouteDetailedFilter parses query 'bus_ids', 'directions', 'variants', and returns the filter.
 func ParseRouteDetailedFilter(r *http.Request) (*dataprovider.RouteFilter, error) {
 	busIDs, err := ParseQueryInt64Slice(r, "bus_ids")
 	if err != nil {
 		return nil, err
 	}
 
+	directions, err := ParseQueryDirections(r, "directions")
+	if err != nil {
+		return nil, err
+	}
+
+	variants, err := ParseQueryParams(r, "variants")
+	if err != nil {
+		return nil, err
+	}
+
 	return dataprovider.NewRouteFilter().
 		ByBusIDs(busIDs...).
+		ByDirections(directions...).
+		ByVariants(variants...).
 		ViewDetailed(), nil
 }
 
-// ParseDeleteRouteFilter parses query 'bus_id', 'stop_id', 'step', and returns the filter.
+// ParseDeleteRouteFilter parses query 'bus_id', 'direction', 'variant', 'stop_id', 'step', and returns the filter.
+// The step is counted in the route pattern, so the default direction and the main variant are used if missing.
 func ParseDeleteRouteFilter(r *http.Request) (*dataprovider.RouteFilter, error) {
 	busID, err := parseQueryInt64(r, "bus_id")
 	if err != nil {
 		return nil, err
 	}
 
+	directions, err := ParseQueryDirections(r, "direction")
+	if err != nil {
+		return nil, err
+	}
+
+	variants, err := ParseQueryParams(r, "variant")
+	if err != nil {
+		return nil, err
+	}
+
 	stopID, err := parseQueryInt64(r, "stop_id")
 	if err != nil {
 		return nil, err
 	}
 
-	step, err := parseQueryInt8(r, "step")
+	step, err := parseQueryStep(r, "step")
 	if err != nil {
 		return nil, err
 	}
@@ -328,7 +471,237 @@ func ParseDeleteRouteFilter(r *http.Request) (*dataprovider.RouteFilter, error)
 	}
 	if step != 0 {
 		filter = filter.BySteps(step)
+
+		if len(directions) == 0 {
+			directions = []model.Direction{model.DefaultDirection}
+		}
+		if len(variants) == 0 {
+			variants = []string{""}
+		}
+	}
+	if len(directions) > 0 {
+		filter = filter.ByDirections(directions[0])
+	}
+	if len(variants) > 0 {
+		filter = filter.ByVariants(variants[0])
 	}
 
 	return filter, nil
 }
+
+// ParseJourneyStops parses query 'from_stop', 'to_stop', and returns the stops ids.
+func ParseJourneyStops(r *http.Request) (int64, int64, error) {
+	fromStopID, err := parseQueryInt64(r, "from_stop")
+	if err != nil {
+		return 0, 0, err
+	}
+
+	toStopID, err := parseQueryInt64(r, "to_stop")
+	if err != nil {
+		return 0, 0, err
+	}
+
+	return fromStopID, toStopID, nil
+}
+
+// ParseQueryTime parses query time in RFC3339 format for specific field.
+func ParseQueryTime(r *http.Request, field string) (time.Time, error) {
+	value, err := ParseQueryParam(r, field)
+	if err != nil {
+		return time.Time{}, err
+	}
+	if value == "" {
+		return time.Time{}, nil
+	}
+
+	t, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return time.Time{}, errors.Errorf("%v is not a RFC3339 time", value)
+	}
+
+	return t, nil
+}
+
+// ParseTimetableFilter parses query 'bus_ids', 'stop_ids', and returns the filter.
+func ParseTimetableFilter(r *http.Request) (*dataprovider.TimetableFilter, error) {
+	busIDs, err := ParseQueryInt64Slice(r, "bus_ids")
+	if err != nil {
+		return nil, err
+	}
+
+	stopIDs, err := ParseQueryInt64Slice(r, "stop_ids")
+	if err != nil {
+		return nil, err
+	}
+
+	return dataprovider.NewTimetableFilter().
+		ByBusIDs(busIDs...).
+		ByStopIDs(stopIDs...), nil
+}
+
+// ParseDepartureFilter parses query 'stop_id', and returns the filter.
+func ParseDepartureFilter(r *http.Request) (*dataprovider.TimetableFilter, error) {
+	stopID, err := parseQueryInt64(r, "stop_id")
+	if err != nil {
+		return nil, err
+	}
+
+	filter := dataprovider.NewTimetableFilter()
+	if stopID != 0 {
+		filter = filter.ByStopIDs(stopID)
+	}
+
+	return filter, nil
+}
+
+// ParseGTFSExportFilter parses query 'cities', and returns the filter.
+func ParseGTFSExportFilter(r *http.Request) (*dataprovider.CityFilter, error) {
+	cities, err := ParseQueryParams(r, "cities")
+	if err != nil {
+		return nil, err
+	}
+
+	return dataprovider.NewCityFilter().ByNames(cities...), nil
+}
+
+// ParseResponseFormat parses query 'format' and header 'Accept', and returns the MIME of the response.
+// The query takes precedence: 'json' or 'geojson' are allowed.
+func ParseResponseFormat(r *http.Request) (MIME, error) {
+	format, err := ParseQueryParam(r, "format")
+	if err != nil {
+		return "", err
+	}
+
+	switch format {
+	case "json":
+		return MIMEApplicationJSON, nil
+	case "geojson":
+		return MIMEApplicationGeoJSON, nil
+	case "":
+	default:
+		return "", errors.Errorf("%v is not a supported format", format)
+	}
+
+	for _, accept := range strings.Split(r.Header.Get(HeaderAccept), ",") {
+		mime := strings.TrimSpace(strings.Split(accept, ";")[0])
+		if mime == MIMEApplicationGeoJSON.String() {
+			return MIMEApplicationGeoJSON, nil
+		}
+	}
+
+	return MIMEApplicationJSON, nil
+}
+
+// TrustedProxies are the networks of the proxies in front of the server.
+type TrustedProxies []*net.IPNet
+
+// ParseTrustedProxies parses the CIDRs or the IPs of the trusted proxies.
+func ParseTrustedProxies(proxies []string) (TrustedProxies, error) {
+	var nets = make(TrustedProxies, 0, len(proxies))
+
+	for _, proxy := range proxies {
+		if !strings.Contains(proxy, "/") {
+			if ip := net.ParseIP(proxy); ip != nil && ip.To4() != nil {
+				proxy += "/32"
+			} else {
+				proxy += "/128"
+			}
+		}
+
+		_, ipNet, err := net.ParseCIDR(proxy)
+		if err != nil {
+			return nil, errors.Wrapf(err, "trusted proxy %s", proxy)
+		}
+
+		nets = append(nets, ipNet)
+	}
+
+	return nets, nil
+}
+
+// trusts returns true if the IP is of the trusted proxy.
+func (p TrustedProxies) trusts(ip string) bool {
+	parsed := net.ParseIP(ip)
+	if parsed == nil {
+		return false
+	}
+
+	for _, ipNet := range p {
+		if ipNet.Contains(parsed) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// ClientIP returns IP of the client connected to the server. If it is the trusted proxy, the IP is
+// the rightmost one of X-Forwarded-For which is not of the trusted proxies, or X-Real-IP without it.
+// The headers of the untrusted connections are ignored, else the client could set any IP.
+func ClientIP(r *http.Request, proxies TrustedProxies) string {
+	ip, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		ip = r.RemoteAddr
+	}
+
+	if !proxies.trusts(ip) {
+		return ip
+	}
+
+	if forwarded := r.Header.Values(HeaderXForwardedFor); len(forwarded) > 0 {
+		hops := strings.Split(strings.Join(forwarded, ","), ",")
+		for i := len(hops) - 1; i >= 0; i-- {
+			hop := strings.TrimSpace(hops[i])
+			if net.ParseIP(hop) == nil {
+				break
+			}
+
+			ip = hop
+			if !proxies.trusts(hop) {
+				break
+			}
+		}
+
+		return ip
+	}
+
+	if realIP := strings.TrimSpace(r.Header.Get(HeaderXRealIP)); net.ParseIP(realIP) != nil {
+		return realIP
+	}
+
+	return ip
+}
+
+// ParseUserFilter parses query 'ids', 'emails', 'types' and pagination, and returns the filter.
+func ParseUserFilter(r *http.Request) (*dataprovider.UserFilter, error) {
+	ids, err := ParseQueryIntSlice(r, "ids")
+	if err != nil {
+		return nil, err
+	}
+
+	emails, err := ParseQueryParams(r, "emails")
+	if err != nil {
+		return nil, err
+	}
+
+	types, err := ParseQueryParams(r, "types")
+	if err != nil {
+		return nil, err
+	}
+
+	var userTypes = make([]model.UserType, 0, len(types))
+	for _, t := range types {
+		userTypes = append(userTypes, model.UserType(t))
+	}
+
+	paginator, err := ParsePaginator(r)
+	if err != nil {
+		return nil, err
+	}
+
+	return dataprovider.NewUserFilter().
+		ByIDs(ids...).
+		ByEmails(emails...).
+		ByTypes(userTypes...).
+		WithPaginator(paginator), nil
+}
@@ -0,0 +1,146 @@
+package api
+
+import (
+	"net/http/httptest"
+	"testing"
+)
+
+func TestParseTrustedProxies(t *testing.T) {
+	tests := []struct {
+		name    string
+		proxies []string
+		trusted []string
+		wantErr bool
+	}{
+		{
+			name:    "ipv4",
+			proxies: []string{"10.0.0.1"},
+			trusted: []string{"10.0.0.1"},
+		},
+		{
+			name:    "ipv6",
+			proxies: []string{"2001:db8::1"},
+			trusted: []string{"2001:db8::1"},
+		},
+		{
+			name:    "cidr",
+			proxies: []string{"10.0.0.0/8", "2001:db8::/32"},
+			trusted: []string{"10.1.2.3", "2001:db8::5"},
+		},
+		{
+			name:    "invalid",
+			proxies: []string{"proxy.local"},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			proxies, err := ParseTrustedProxies(tt.proxies)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseTrustedProxies() error = %v, want error %v", err, tt.wantErr)
+			}
+
+			for _, ip := range tt.trusted {
+				if !proxies.trusts(ip) {
+					t.Errorf("%s is not trusted", ip)
+				}
+			}
+			if proxies.trusts("192.0.2.1") {
+				t.Error("192.0.2.1 is trusted")
+			}
+		})
+	}
+}
+
+func TestClientIP(t *testing.T) {
+	proxies, err := ParseTrustedProxies([]string{"10.0.0.0/8"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name       string
+		remoteAddr string
+		forwarded  []string
+		realIP     string
+		want       string
+	}{
+		{
+			name:       "direct",
+			remoteAddr: "203.0.113.7:5555",
+			want:       "203.0.113.7",
+		},
+		{
+			name:       "untrusted headers ignored",
+			remoteAddr: "203.0.113.7:5555",
+			forwarded:  []string{"198.51.100.1"},
+			realIP:     "198.51.100.2",
+			want:       "203.0.113.7",
+		},
+		{
+			name:       "forwarded by trusted proxy",
+			remoteAddr: "10.0.0.1:5555",
+			forwarded:  []string{"198.51.100.1"},
+			want:       "198.51.100.1",
+		},
+		{
+			name:       "rightmost untrusted hop",
+			remoteAddr: "10.0.0.1:5555",
+			forwarded:  []string{"192.0.2.66, 198.51.100.1, 10.0.0.2"},
+			want:       "198.51.100.1",
+		},
+		{
+			name:       "hops in several headers",
+			remoteAddr: "10.0.0.1:5555",
+			forwarded:  []string{"192.0.2.66", "198.51.100.1"},
+			want:       "198.51.100.1",
+		},
+		{
+			name:       "invalid hop stops the walk",
+			remoteAddr: "10.0.0.1:5555",
+			forwarded:  []string{"198.51.100.1, unknown, 10.0.0.2"},
+			want:       "10.0.0.2",
+		},
+		{
+			name:       "only trusted hops",
+			remoteAddr: "10.0.0.1:5555",
+			forwarded:  []string{"10.0.0.3, 10.0.0.2"},
+			want:       "10.0.0.3",
+		},
+		{
+			name:       "real ip by trusted proxy",
+			remoteAddr: "10.0.0.1:5555",
+			realIP:     "198.51.100.2",
+			want:       "198.51.100.2",
+		},
+		{
+			name:       "invalid real ip",
+			remoteAddr: "10.0.0.1:5555",
+			realIP:     "unknown",
+			want:       "10.0.0.1",
+		},
+		{
+			name:       "remote address without port",
+			remoteAddr: "203.0.113.7",
+			want:       "203.0.113.7",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest("GET", "/", nil)
+			r.RemoteAddr = tt.remoteAddr
+			for _, value := range tt.forwarded {
+				r.Header.Add(HeaderXForwardedFor, value)
+			}
+			if tt.realIP != "" {
+				r.Header.Set(HeaderXRealIP, tt.realIP)
+			}
+
+			if got := ClientIP(r, proxies); got != tt.want {
+				t.Errorf("ClientIP() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
@@ -3,7 +3,9 @@ package api
 import (
 	"context"
 	"encoding/json"
+	"math"
 	"net/http"
+	"strconv"
 
 	httpv1 "github.com/gxravel/bus-routes/internal/api/http/handler/v1"
 	ierr "github.com/gxravel/bus-routes/internal/errors"
@@ -15,20 +17,37 @@ type MIME string
 func (m MIME) String() string { return string(m) }
 
 const (
-	MIMEApplicationJSON MIME = "application/json"
+	MIMEApplicationJSON    MIME = "application/json"
+	MIMEApplicationGeoJSON MIME = "application/geo+json"
+	MIMEApplicationZip     MIME = "application/zip"
 )
 
 const (
-	HeaderContentType = "Content-Type"
+	HeaderAccept             = "Accept"
+	HeaderContentType        = "Content-Type"
+	HeaderContentDisposition = "Content-Disposition"
+	HeaderCacheControl       = "Cache-Control"
+	HeaderWWWAuthenticate    = "WWW-Authenticate"
+	HeaderXForwardedFor      = "X-Forwarded-For"
+	HeaderXRealIP            = "X-Real-IP"
 )
 
 func RespondJSON(ctx context.Context, w http.ResponseWriter, code int, data interface{}) {
+	respond(ctx, w, code, MIMEApplicationJSON, data)
+}
+
+// RespondGeoJSON responds with 200 status code and GeoJSON object as is.
+func RespondGeoJSON(ctx context.Context, w http.ResponseWriter, data interface{}) {
+	respond(ctx, w, http.StatusOK, MIMEApplicationGeoJSON, data)
+}
+
+func respond(ctx context.Context, w http.ResponseWriter, code int, mime MIME, data interface{}) {
 	if data == nil {
 		w.WriteHeader(code)
 		return
 	}
 
-	w.Header().Set(HeaderContentType, MIMEApplicationJSON.String())
+	w.Header().Set(HeaderContentType, mime.String())
 
 	w.WriteHeader(code)
 
@@ -74,6 +93,12 @@ func RespondError(ctx context.Context, w http.ResponseWriter, err error) {
 	reason := ierr.ConvertToReason(err)
 	code := ierr.ResolveStatusCode(reason.Err)
 
+	if val, ok := reason.Err.(ierr.RetryAfterer); ok {
+		// Retry-After is in seconds, rounded up not to retry too early.
+		seconds := int64(math.Ceil(val.RetryAfter().Seconds()))
+		w.Header().Set("Retry-After", strconv.FormatInt(seconds, 10))
+	}
+
 	RespondJSON(ctx, w, code, &httpv1.Response{
 		Error: &httpv1.APIError{
 			Reason: &httpv1.APIReason{
@@ -84,3 +109,45 @@ func RespondError(ctx context.Context, w http.ResponseWriter, err error) {
 		},
 	})
 }
+
+// RespondOAuthError responds with the error in the format of OAuth 2.0 (RFC 6749, section 5.2).
+func RespondOAuthError(ctx context.Context, w http.ResponseWriter, err error) {
+	reason := ierr.ConvertToReason(err)
+
+	var (
+		code       = http.StatusBadRequest
+		oauthError string
+	)
+	switch {
+	case reason.Err == ierr.ErrUnsupportedGrantType:
+		oauthError = "unsupported_grant_type"
+
+	default:
+		switch reason.Err.(type) {
+		case ierr.AuthorizationError:
+			code = http.StatusUnauthorized
+			oauthError = "invalid_client"
+			w.Header().Set(HeaderWWWAuthenticate, `Basic realm="bus-routes"`)
+
+		case ierr.ForbiddenError:
+			oauthError = "invalid_scope"
+
+		case ierr.BadRequestError, ierr.ValidationError:
+			oauthError = "invalid_request"
+
+		default:
+			code = ierr.ResolveStatusCode(reason.Err)
+			oauthError = "server_error"
+		}
+	}
+
+	description := reason.Error()
+	if reason.Message != "" {
+		description += ": " + reason.Message
+	}
+
+	RespondJSON(ctx, w, code, &httpv1.OAuthError{
+		Error:       oauthError,
+		Description: description,
+	})
+}
@@ -0,0 +1,124 @@
+package apikey
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"strconv"
+	"time"
+
+	"github.com/gxravel/bus-routes/internal/storage"
+
+	"github.com/go-redis/redis/v8"
+)
+
+const (
+	// keyPrefix tells the API keys apart from the other secrets, e.g. in the leaked logs.
+	keyPrefix = "brk_"
+	// prefixLength is the length of the beginning of the key stored to tell the keys apart.
+	prefixLength = len(keyPrefix) + 8
+
+	// lastUsedKey is the storage hash of the last time the API keys are used by id.
+	lastUsedKey = "api_keys:last_used"
+)
+
+// Key defines the new API key, only its hash is stored.
+type Key struct {
+	String string
+	Prefix string
+	Hash   []byte
+}
+
+// Generate generates the new random API key.
+func Generate() (*Key, error) {
+	var secret = make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		return nil, err
+	}
+
+	key := keyPrefix + base64.RawURLEncoding.EncodeToString(secret)
+
+	return &Key{
+		String: key,
+		Prefix: key[:prefixLength],
+		Hash:   Hash(key),
+	}, nil
+}
+
+// Hash returns SHA-256 of the key, which is enough for the random keys unlike the passwords.
+func Hash(key string) []byte {
+	hash := sha256.Sum256([]byte(key))
+	return hash[:]
+}
+
+// Tracker keeps the last time the API keys are used.
+type Tracker interface {
+	Touch(ctx context.Context, id int64) error
+	LastUsed(ctx context.Context, ids ...int64) (map[int64]int64, error)
+	Forget(ctx context.Context, ids ...int64) error
+}
+
+// RedisTracker keeps the last time the API keys are used in the storage.
+type RedisTracker struct {
+	client *storage.Client
+}
+
+// NewTracker creates new instance of RedisTracker.
+func NewTracker(client *storage.Client) *RedisTracker {
+	return &RedisTracker{
+		client: client,
+	}
+}
+
+// Touch sets the last time the key is used to now.
+func (t *RedisTracker) Touch(ctx context.Context, id int64) error {
+	return t.client.HSet(ctx, lastUsedKey, strconv.FormatInt(id, 10), time.Now().Unix()).Err()
+}
+
+// LastUsed returns the last time the keys are used as unix time, the keys never used are missing.
+func (t *RedisTracker) LastUsed(ctx context.Context, ids ...int64) (map[int64]int64, error) {
+	var result = make(map[int64]int64, len(ids))
+	if len(ids) == 0 {
+		return result, nil
+	}
+
+	values, err := t.client.HMGet(ctx, lastUsedKey, fields(ids)...).Result()
+	if err != nil && err != redis.Nil {
+		return nil, err
+	}
+
+	for k, value := range values {
+		s, ok := value.(string)
+		if !ok {
+			continue
+		}
+
+		used, err := strconv.ParseInt(s, 10, 64)
+		if err != nil {
+			return nil, err
+		}
+
+		result[ids[k]] = used
+	}
+
+	return result, nil
+}
+
+// Forget deletes the last time the keys are used.
+func (t *RedisTracker) Forget(ctx context.Context, ids ...int64) error {
+	if len(ids) == 0 {
+		return nil
+	}
+
+	return t.client.HDel(ctx, lastUsedKey, fields(ids)...).Err()
+}
+
+func fields(ids []int64) []string {
+	var result = make([]string, 0, len(ids))
+	for _, id := range ids {
+		result = append(result, strconv.FormatInt(id, 10))
+	}
+
+	return result
+}
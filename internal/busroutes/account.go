@@ -0,0 +1,171 @@
+package busroutes
+
+import (
+	"context"
+	"fmt"
+
+	httpv1 "github.com/gxravel/bus-routes/internal/api/http/handler/v1"
+	"github.com/gxravel/bus-routes/internal/dataprovider"
+	ierr "github.com/gxravel/bus-routes/internal/errors"
+	log "github.com/gxravel/bus-routes/internal/logger"
+	"github.com/gxravel/bus-routes/internal/mail"
+)
+
+// The purposes of the one-time tokens sent by email.
+const (
+	purposeVerifyEmail   = "verify_email"
+	purposeResetPassword = "reset_password"
+)
+
+// The prefixes of the sent emails keys of the email and the client IP.
+const (
+	sendEmailPrefix = "send:email:"
+	sendIPPrefix    = "send:ip:"
+)
+
+// CheckSendLimit counts the email with the link requested to the email address from the client IP,
+// and returns the error if too many ones are requested in the window, so the endpoints can not flood the mailbox.
+// The unknown emails are counted too, else the limit would tell which emails are registered.
+func (r *BusRoutes) CheckSendLimit(ctx context.Context, email, ip string) error {
+	var limits = map[string]int64{
+		sendEmailPrefix + email: r.config.Account.SendPerEmail,
+	}
+	if ip != "" {
+		limits[sendIPPrefix+ip] = r.config.Account.SendPerIP
+	}
+
+	for key, limit := range limits {
+		count, ttl, err := r.storage.Hit(ctx, key, r.config.Account.SendWindow)
+		if err != nil {
+			return err
+		}
+		if count > limit {
+			return ierr.NewReason(ierr.NewRetryAfter(ierr.ErrTooManyEmails, ttl))
+		}
+	}
+
+	return nil
+}
+
+// SendVerification sends the email verification link to the user, unless its email is verified already.
+func (r *BusRoutes) SendVerification(ctx context.Context, id int64) error {
+	user, err := r.GetUser(ctx, id)
+	if err != nil {
+		return err
+	}
+	if user.Verified {
+		return ierr.NewReason(ierr.ErrConflict).WithMessage("email is verified already")
+	}
+
+	token, err := r.storage.NewOneTimeToken(ctx, purposeVerifyEmail, user.ID, r.config.Account.VerifyExpiry)
+	if err != nil {
+		return err
+	}
+
+	return r.mailer.Send(ctx, &mail.Message{
+		To:      user.Email,
+		Subject: "Подтверждение email",
+		Body: fmt.Sprintf(
+			"Чтобы подтвердить email, перейдите по ссылке:\r\n%s%s\r\n\r\nСсылка действительна %s.",
+			r.config.Account.VerifyURL, token, r.config.Account.VerifyExpiry,
+		),
+	})
+}
+
+// VerifyEmail spends the verification token and marks the email of its user as verified.
+// The tokens of the user are revoked, so the new ones are issued with the write access.
+func (r *BusRoutes) VerifyEmail(ctx context.Context, token string) error {
+	id, err := r.spendToken(ctx, purposeVerifyEmail, token)
+	if err != nil {
+		return err
+	}
+
+	if err := r.setVerified(ctx, id); err != nil {
+		return err
+	}
+
+	return r.tokenManager.RevokeAll(ctx, id)
+}
+
+// ForgotPassword sends the password reset link to the email.
+// The unknown email is not an error, so the response does not tell which emails are registered.
+func (r *BusRoutes) ForgotPassword(ctx context.Context, email string) error {
+	dbUser, err := r.userStore.GetByFilter(ctx, dataprovider.NewUserFilter().ByEmails(email))
+	if err != nil {
+		return err
+	}
+	if dbUser == nil {
+		log.FromContext(ctx).WithStr("email", email).Info("password reset of unknown email")
+		return nil
+	}
+
+	token, err := r.storage.NewOneTimeToken(ctx, purposeResetPassword, dbUser.ID, r.config.Account.ResetExpiry)
+	if err != nil {
+		return err
+	}
+
+	return r.mailer.Send(ctx, &mail.Message{
+		To:      dbUser.Email,
+		Subject: "Сброс пароля",
+		Body: fmt.Sprintf(
+			"Чтобы задать новый пароль, перейдите по ссылке:\r\n%s%s\r\n\r\nСсылка действительна %s. "+
+				"Если вы не запрашивали сброс пароля, проигнорируйте это письмо.",
+			r.config.Account.ResetURL, token, r.config.Account.ResetExpiry,
+		),
+	})
+}
+
+// ResetPassword spends the reset token and sets the new password of its user.
+// The user received the token by email, so its email is verified as well.
+// The tokens of the user are revoked, the other reset tokens too.
+func (r *BusRoutes) ResetPassword(ctx context.Context, reset *httpv1.PasswordReset) error {
+	id, err := r.spendToken(ctx, purposeResetPassword, reset.Token)
+	if err != nil {
+		return err
+	}
+
+	hashedPassword, err := hashPassword(reset.NewPassword)
+	if err != nil {
+		return err
+	}
+
+	if err := r.setVerified(ctx, id); err != nil {
+		return err
+	}
+
+	if err := r.UpdateUserPassword(ctx, hashedPassword, dataprovider.NewUserFilter().ByIDs(int(id))); err != nil {
+		return err
+	}
+
+	if err := r.storage.RevokeOneTimeTokens(ctx, purposeResetPassword, id); err != nil {
+		return err
+	}
+
+	return r.tokenManager.RevokeAll(ctx, id)
+}
+
+// setVerified marks the email of the user as verified, unless it is verified already.
+func (r *BusRoutes) setVerified(ctx context.Context, id int64) error {
+	user, err := r.GetUser(ctx, id)
+	if err != nil {
+		return err
+	}
+	if user.Verified {
+		return nil
+	}
+
+	return r.userStore.UpdateVerified(ctx, true, dataprovider.NewUserFilter().ByIDs(int(id)))
+}
+
+// spendToken returns the id of the user of the one-time token, or the error if the token is spent or expired.
+func (r *BusRoutes) spendToken(ctx context.Context, purpose, token string) (int64, error) {
+	id, err := r.storage.SpendOneTimeToken(ctx, purpose, token)
+	if err != nil {
+		return 0, err
+	}
+	if id == 0 {
+		return 0, ierr.NewReason(ierr.ErrInvalidToken).WithMessage("the token is spent or expired")
+	}
+
+	return id, nil
+}
@@ -0,0 +1,231 @@
+package busroutes
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	httpv1 "github.com/gxravel/bus-routes/internal/api/http/handler/v1"
+	"github.com/gxravel/bus-routes/internal/apikey"
+	"github.com/gxravel/bus-routes/internal/dataprovider"
+	ierr "github.com/gxravel/bus-routes/internal/errors"
+	log "github.com/gxravel/bus-routes/internal/logger"
+	"github.com/gxravel/bus-routes/internal/model"
+)
+
+const maxAPIKeyNameLength = 255
+
+// maxAPIKeyExpiry is the latest time of TIMESTAMP column, a later expiry would be stored as no expiry.
+const maxAPIKeyExpiry = 1<<31 - 1
+
+// GetAPIKeys returns the API keys of the user with the last time they are used.
+func (r *BusRoutes) GetAPIKeys(ctx context.Context, userID int64) ([]*httpv1.APIKey, error) {
+	if _, err := r.GetUser(ctx, userID); err != nil {
+		return nil, err
+	}
+
+	dbKeys, err := r.apiKeyStore.GetListByFilter(ctx, dataprovider.NewAPIKeyFilter().ByUserIDs(userID))
+	if err != nil {
+		return nil, err
+	}
+
+	var ids = make([]int64, 0, len(dbKeys))
+	for _, key := range dbKeys {
+		ids = append(ids, key.ID)
+	}
+
+	lastUsed, err := r.apiKeyTracker.LastUsed(ctx, ids...)
+	if err != nil {
+		return nil, err
+	}
+
+	keys := toV1APIKeys(dbKeys...)
+	for _, key := range keys {
+		if used, ok := lastUsed[key.ID]; ok {
+			key.LastUsed = &used
+		}
+	}
+
+	return keys, nil
+}
+
+// AddAPIKey issues the API key to the service user, the key itself is returned only here.
+// The scopes can not include users:admin, so the keys can not issue other keys.
+func (r *BusRoutes) AddAPIKey(ctx context.Context, userID int64, create *httpv1.APIKeyCreate) (*httpv1.APIKey, error) {
+	if create.Name == "" || len(create.Name) > maxAPIKeyNameLength {
+		return nil, ierr.NewReason(ierr.ErrValidationFailed).
+			WithMessage(fmt.Sprintf("name must be from 1 to %d bytes long", maxAPIKeyNameLength))
+	}
+	if len(create.Scopes) == 0 {
+		return nil, ierr.NewReason(ierr.ErrValidationFailed).WithMessage("scopes must not be empty")
+	}
+	for _, scope := range create.Scopes {
+		if !scope.IsValid() || scope == model.PermissionUsersAdmin {
+			return nil, ierr.NewReason(ierr.ErrValidationFailed).
+				WithMessage(fmt.Sprintf("invalid scope %q", scope))
+		}
+	}
+	if create.Expiry != nil && *create.Expiry <= time.Now().Unix() {
+		return nil, ierr.NewReason(ierr.ErrValidationFailed).WithMessage("expiry must be in the future")
+	}
+	if create.Expiry != nil && *create.Expiry > maxAPIKeyExpiry {
+		return nil, ierr.NewReason(ierr.ErrValidationFailed).
+			WithMessage(fmt.Sprintf("expiry must not be later than %d", maxAPIKeyExpiry))
+	}
+
+	user, err := r.GetUser(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if user.Type != model.UserService {
+		return nil, ierr.NewReason(ierr.ErrValidationFailed).
+			WithMessage(fmt.Sprintf("api keys are issued only to %s users", model.UserService))
+	}
+
+	key, err := apikey.Generate()
+	if err != nil {
+		return nil, err
+	}
+
+	dbKey := &model.APIKey{
+		UserID:    userID,
+		Name:      create.Name,
+		Prefix:    key.Prefix,
+		HashedKey: key.Hash,
+		Scopes:    create.Scopes,
+		ExpiresAt: create.Expiry,
+	}
+
+	id, err := r.apiKeyStore.Add(ctx, dbKey)
+	if err != nil {
+		if err := ierr.CheckDuplicate(err, "name"); err != nil {
+			return nil, err
+		}
+		return nil, err
+	}
+
+	dbKey, err = r.apiKeyStore.GetByFilter(ctx, dataprovider.NewAPIKeyFilter().ByIDs(id))
+	if err != nil {
+		return nil, err
+	}
+
+	result := toV1APIKeys(dbKey)[0]
+	result.Key = key.String
+
+	return result, nil
+}
+
+// RevokeAPIKey deletes the API key of the user, the tokens issued to the key as OAuth 2.0 client are revoked.
+func (r *BusRoutes) RevokeAPIKey(ctx context.Context, userID, id int64) error {
+	filter := dataprovider.NewAPIKeyFilter().
+		ByUserIDs(userID).
+		ByIDs(id)
+
+	dbKey, err := r.apiKeyStore.GetByFilter(ctx, filter)
+	if err != nil {
+		return err
+	}
+	if dbKey == nil {
+		return ierr.NewReason(ierr.ErrNotFound).WithMessage(fmt.Sprintf("api key %d", id))
+	}
+
+	if err := r.apiKeyStore.Delete(ctx, filter); err != nil {
+		return err
+	}
+
+	if err := r.apiKeyTracker.Forget(ctx, id); err != nil {
+		return err
+	}
+
+	return r.revokeClientSessions(ctx, userID, id)
+}
+
+// GetUserByAPIKey returns the service user of the API key, unless the key lacks any of the permissions in its scopes.
+// The key is refused if no permission is required, its scopes do not limit such a request.
+func (r *BusRoutes) GetUserByAPIKey(ctx context.Context, key string, permissions ...model.Permission) (*httpv1.User, error) {
+	dbKey, user, err := r.authenticateAPIKey(ctx, key)
+	if err != nil {
+		return nil, err
+	}
+
+	logger := log.FromContext(ctx).WithStr("api_key", dbKey.Prefix)
+
+	if len(permissions) == 0 || !dbKey.Scopes.Has(permissions...) {
+		err := scopesDeniedError(permissions)
+
+		logger.
+			WithField("user", user).
+			Warn(err.Error())
+
+		return nil, err
+	}
+
+	r.touchAPIKey(ctx, dbKey)
+
+	return user, nil
+}
+
+// scopesDeniedError returns the error of the scoped credentials lacking the permissions.
+func scopesDeniedError(permissions []model.Permission) error {
+	if len(permissions) == 0 {
+		return ierr.NewReason(ierr.ErrPermissionDenied).
+			WithMessage("the scoped credentials are not accepted without the required permissions")
+	}
+
+	return ierr.NewReason(ierr.ErrPermissionDenied).
+		WithMessage(fmt.Sprintf("requires %v", permissions))
+}
+
+// authenticateAPIKey returns the API key and its service user with the scopes of the key, unless the key is unknown or expired.
+func (r *BusRoutes) authenticateAPIKey(ctx context.Context, key string) (*model.APIKey, *httpv1.User, error) {
+	dbKey, err := r.apiKeyStore.GetByFilter(ctx, dataprovider.NewAPIKeyFilter().ByHashedKeys(apikey.Hash(key)))
+	if err != nil {
+		return nil, nil, err
+	}
+	if dbKey == nil {
+		return nil, nil, ierr.NewReason(ierr.ErrInvalidAPIKey)
+	}
+
+	if dbKey.ExpiresAt != nil && *dbKey.ExpiresAt <= time.Now().Unix() {
+		return nil, nil, ierr.NewReason(ierr.ErrTokenExpired).WithMessage("api key is expired")
+	}
+
+	users, err := r.GetUsers(ctx, dataprovider.NewUserFilter().ByIDs(int(dbKey.UserID)))
+	if err != nil {
+		return nil, nil, err
+	}
+	if len(users) == 0 || users[0].Type != model.UserService {
+		return nil, nil, ierr.NewReason(ierr.ErrInvalidAPIKey).WithMessage("the user of the api key is not a service one")
+	}
+
+	user := users[0]
+	user.Scopes = dbKey.Scopes
+
+	return dbKey, user, nil
+}
+
+// touchAPIKey tracks the API key usage, the key is authenticated already, so failing to track it does not fail the request.
+func (r *BusRoutes) touchAPIKey(ctx context.Context, dbKey *model.APIKey) {
+	if err := r.apiKeyTracker.Touch(ctx, dbKey.ID); err != nil {
+		log.FromContext(ctx).
+			WithStr("api_key", dbKey.Prefix).
+			WithErr(err).
+			Error("failed to track the api key usage")
+	}
+}
+
+func toV1APIKeys(dbKeys ...*model.APIKey) []*httpv1.APIKey {
+	var keys = make([]*httpv1.APIKey, 0, len(dbKeys))
+	for _, key := range dbKeys {
+		keys = append(keys, &httpv1.APIKey{
+			ID:      key.ID,
+			Name:    key.Name,
+			Prefix:  key.Prefix,
+			Scopes:  key.Scopes,
+			Expiry:  key.ExpiresAt,
+			Created: key.CreatedAt,
+		})
+	}
+
+	return keys
+}
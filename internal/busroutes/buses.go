@@ -18,13 +18,43 @@ func (r *BusRoutes) GetBuses(ctx context.Context, filter *dataprovider.BusFilter
 }
 
 func (r *BusRoutes) AddBuses(ctx context.Context, buses ...*httpv1.Bus) error {
+	var cities = make([]string, 0, len(buses))
+	for _, bus := range buses {
+		cities = append(cities, bus.City)
+	}
+
+	if err := checkCities(ctx, cities...); err != nil {
+		return err
+	}
+
 	return r.busStore.Add(ctx, toDBBuses(buses...)...)
 }
 
+// UpdateBus updates the bus, the city scoped user can not move it out of its cities or into them.
+func (r *BusRoutes) UpdateBus(ctx context.Context, bus *httpv1.Bus) error {
+	if err := checkCities(ctx, bus.City); err != nil {
+		return err
+	}
+	if err := r.checkBusIDsCities(ctx, bus.ID); err != nil {
+		return err
+	}
+
+	return r.busStore.Update(ctx, toDBBuses(bus)[0])
+}
+
+func (r *BusRoutes) DeleteBus(ctx context.Context, filter *dataprovider.BusFilter) error {
+	if err := r.checkBusesCities(ctx, filter); err != nil {
+		return err
+	}
+
+	return r.busStore.Delete(ctx, filter)
+}
+
 func toDBBuses(buses ...*httpv1.Bus) []*model.Bus {
 	var dbBuses = make([]*model.Bus, 0, len(buses))
 	for _, bus := range buses {
 		dbBuses = append(dbBuses, &model.Bus{
+			ID:     bus.ID,
 			City:   bus.City,
 			Number: bus.Num,
 		})
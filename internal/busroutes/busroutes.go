@@ -1,24 +1,33 @@
 package busroutes
 
 import (
+	"github.com/gxravel/bus-routes/internal/apikey"
 	"github.com/gxravel/bus-routes/internal/config"
 	"github.com/gxravel/bus-routes/internal/database"
 	"github.com/gxravel/bus-routes/internal/dataprovider"
 	"github.com/gxravel/bus-routes/internal/jwt"
 	log "github.com/gxravel/bus-routes/internal/logger"
+	"github.com/gxravel/bus-routes/internal/mail"
+	"github.com/gxravel/bus-routes/internal/storage"
 )
 
 type BusRoutes struct {
-	config       *config.Config
-	db           *database.Client
-	logger       log.Logger
-	busStore     dataprovider.BusStore
-	cityStore    dataprovider.CityStore
-	stopStore    dataprovider.StopStore
-	routeStore   dataprovider.RouteStore
-	userStore    dataprovider.UserStore
-	txer         dataprovider.Txer
-	tokenManager jwt.Manager
+	config         *config.Config
+	db             *database.Client
+	logger         log.Logger
+	busStore       dataprovider.BusStore
+	cityStore      dataprovider.CityStore
+	stopStore      dataprovider.StopStore
+	routeStore     dataprovider.RouteStore
+	userStore      dataprovider.UserStore
+	timetableStore dataprovider.TimetableStore
+	apiKeyStore    dataprovider.APIKeyStore
+	totpStore      dataprovider.TOTPStore
+	txer           dataprovider.Txer
+	tokenManager   jwt.Manager
+	apiKeyTracker  apikey.Tracker
+	storage        *storage.Client
+	mailer         mail.Mailer
 }
 
 func New(
@@ -30,19 +39,31 @@ func New(
 	stopStore dataprovider.StopStore,
 	routeStore dataprovider.RouteStore,
 	userStore dataprovider.UserStore,
+	timetableStore dataprovider.TimetableStore,
+	apiKeyStore dataprovider.APIKeyStore,
+	totpStore dataprovider.TOTPStore,
 	txer dataprovider.Txer,
 	jwtManager jwt.Manager,
+	apiKeyTracker apikey.Tracker,
+	storage *storage.Client,
+	mailer mail.Mailer,
 ) *BusRoutes {
 	return &BusRoutes{
-		config:       config,
-		db:           db,
-		logger:       logger,
-		busStore:     busStore,
-		cityStore:    cityStore,
-		stopStore:    stopStore,
-		routeStore:   routeStore,
-		userStore:    userStore,
-		txer:         txer,
-		tokenManager: jwtManager,
+		config:         config,
+		db:             db,
+		logger:         logger,
+		busStore:       busStore,
+		cityStore:      cityStore,
+		stopStore:      stopStore,
+		routeStore:     routeStore,
+		userStore:      userStore,
+		timetableStore: timetableStore,
+		apiKeyStore:    apiKeyStore,
+		totpStore:      totpStore,
+		txer:           txer,
+		tokenManager:   jwtManager,
+		apiKeyTracker:  apiKeyTracker,
+		storage:        storage,
+		mailer:         mailer,
 	}
 }
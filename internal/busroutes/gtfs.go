@@ -0,0 +1,827 @@
+package busroutes
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"time"
+
+	httpv1 "github.com/gxravel/bus-routes/internal/api/http/handler/v1"
+	"github.com/gxravel/bus-routes/internal/dataprovider"
+	ierr "github.com/gxravel/bus-routes/internal/errors"
+	"github.com/gxravel/bus-routes/internal/gtfs"
+	"github.com/gxravel/bus-routes/internal/model"
+)
+
+// ImportGTFS imports the stops, buses and routes of the feed into the city in one transaction.
+// The city is created if it does not exist. If the city is empty, the name of the only agency of the feed is used.
+func (r *BusRoutes) ImportGTFS(ctx context.Context, feed *gtfs.Feed, city string) (*httpv1.GTFSImportReport, error) {
+	if city == "" {
+		if len(feed.Agencies) != 1 {
+			return nil, ierr.NewReason(ierr.ErrMustProvide).WithMessage("city")
+		}
+		city = feed.Agencies[0].Name
+	}
+
+	importer := &gtfsImporter{
+		feed: feed,
+		city: city,
+		report: &httpv1.GTFSImportReport{
+			City:              city,
+			Skipped:           make([]string, 0),
+			DroppedTimetables: make([]string, 0),
+		},
+	}
+
+	f := func(tx *dataprovider.Tx) error {
+		importer.cityStore = r.cityStore.WithTx(tx)
+		importer.stopStore = r.stopStore.WithTx(tx)
+		importer.busStore = r.busStore.WithTx(tx)
+		importer.routeStore = r.routeStore.WithTx(tx)
+		importer.timetableStore = r.timetableStore.WithTx(tx)
+
+		return importer.run(ctx)
+	}
+
+	if err := dataprovider.BeginAutoCommitedTx(ctx, r.txer, f); err != nil {
+		return nil, err
+	}
+
+	return importer.report, nil
+}
+
+// gtfsImporter maps GTFS stops to stops, routes to buses,
+// and the stop sequence of the longest trip of a route in every direction to the bus route pattern.
+type gtfsImporter struct {
+	feed   *gtfs.Feed
+	city   string
+	report *httpv1.GTFSImportReport
+
+	cityStore      dataprovider.CityStore
+	stopStore      dataprovider.StopStore
+	busStore       dataprovider.BusStore
+	routeStore     dataprovider.RouteStore
+	timetableStore dataprovider.TimetableStore
+}
+
+func (i *gtfsImporter) skip(format string, args ...interface{}) {
+	i.report.Skipped = append(i.report.Skipped, fmt.Sprintf(format, args...))
+}
+
+func (i *gtfsImporter) run(ctx context.Context) error {
+	if err := i.importCity(ctx); err != nil {
+		return err
+	}
+
+	stopIDs, err := i.importStops(ctx)
+	if err != nil {
+		return err
+	}
+
+	busIDs, err := i.importBuses(ctx)
+	if err != nil {
+		return err
+	}
+
+	return i.importRoutes(ctx, stopIDs, busIDs)
+}
+
+func (i *gtfsImporter) importCity(ctx context.Context) error {
+	city, err := i.cityStore.GetByFilter(ctx, dataprovider.NewCityFilter().ByNames(i.city))
+	if err != nil {
+		return err
+	}
+	if city != nil {
+		i.report.Cities.Skipped++
+		return nil
+	}
+
+	if err := i.cityStore.Add(ctx, &model.City{Name: i.city}); err != nil {
+		return err
+	}
+	i.report.Cities.Created++
+
+	return nil
+}
+
+// importStops returns the ids of stops by GTFS stop_id. GTFS stops with the same name become one stop.
+func (i *gtfsImporter) importStops(ctx context.Context) (map[string]int64, error) {
+	stops, err := i.stopsByAddress(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var (
+		newStops = make([]*model.Stop, 0)
+		pending  = make(map[string]struct{})
+	)
+
+	for _, stop := range i.feed.Stops {
+		if stop.LocationType != gtfs.LocationStop {
+			i.report.Stops.Skipped++
+			continue
+		}
+		if stop.Name == "" {
+			i.report.Stops.Skipped++
+			i.skip("stop %s: no stop_name", stop.ID)
+			continue
+		}
+
+		lat, lon := gtfsLocation(stop)
+
+		if existing, ok := stops[stop.Name]; ok {
+			if lat == nil || sameLocation(existing, lat, lon) {
+				i.report.Stops.Skipped++
+				continue
+			}
+
+			existing.Lat, existing.Lon = lat, lon
+			if err := i.stopStore.Update(ctx, existing); err != nil {
+				return nil, err
+			}
+			i.report.Stops.Updated++
+			continue
+		}
+
+		if _, ok := pending[stop.Name]; ok {
+			i.report.Stops.Skipped++
+			continue
+		}
+
+		pending[stop.Name] = struct{}{}
+		newStops = append(newStops, &model.Stop{
+			City:    i.city,
+			Address: stop.Name,
+			Lat:     lat,
+			Lon:     lon,
+		})
+	}
+
+	if len(newStops) > 0 {
+		if err := i.stopStore.Add(ctx, newStops...); err != nil {
+			return nil, err
+		}
+		i.report.Stops.Created += len(newStops)
+
+		if stops, err = i.stopsByAddress(ctx); err != nil {
+			return nil, err
+		}
+	}
+
+	var result = make(map[string]int64, len(i.feed.Stops))
+	for _, stop := range i.feed.Stops {
+		if existing, ok := stops[stop.Name]; ok {
+			result[stop.ID] = existing.ID
+		}
+	}
+
+	return result, nil
+}
+
+// gtfsLocation returns the stop coordinates, or nils if the feed does not provide them.
+func gtfsLocation(stop gtfs.Stop) (lat, lon *float64) {
+	if stop.Lat == 0 && stop.Lon == 0 {
+		return nil, nil
+	}
+
+	return &stop.Lat, &stop.Lon
+}
+
+func sameLocation(stop *model.Stop, lat, lon *float64) bool {
+	return stop.Lat != nil && stop.Lon != nil && *stop.Lat == *lat && *stop.Lon == *lon
+}
+
+func (i *gtfsImporter) stopsByAddress(ctx context.Context) (map[string]*model.Stop, error) {
+	stops, err := i.stopStore.GetListByFilter(ctx, dataprovider.NewStopFilter().ByCities(i.city))
+	if err != nil {
+		return nil, err
+	}
+
+	var result = make(map[string]*model.Stop, len(stops))
+	for _, stop := range stops {
+		result[stop.Address] = stop
+	}
+
+	return result, nil
+}
+
+// importBuses returns the ids of buses by GTFS route_id.
+func (i *gtfsImporter) importBuses(ctx context.Context) (map[string]int64, error) {
+	ids, err := i.busIDsByNum(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var (
+		newBuses = make([]*model.Bus, 0)
+		pending  = make(map[string]struct{})
+	)
+
+	for _, route := range i.feed.Routes {
+		if !isBusRoute(route.Type) {
+			i.report.Buses.Skipped++
+			i.skip("route %s: route_type %d is not a bus", route.ID, route.Type)
+			continue
+		}
+
+		num := busNum(route)
+		_, exists := ids[num]
+		_, isPending := pending[num]
+		if exists || isPending {
+			i.report.Buses.Skipped++
+			continue
+		}
+
+		pending[num] = struct{}{}
+		newBuses = append(newBuses, &model.Bus{
+			City:   i.city,
+			Number: num,
+		})
+	}
+
+	if len(newBuses) > 0 {
+		if err := i.busStore.Add(ctx, newBuses...); err != nil {
+			return nil, err
+		}
+		i.report.Buses.Created += len(newBuses)
+
+		if ids, err = i.busIDsByNum(ctx); err != nil {
+			return nil, err
+		}
+	}
+
+	var result = make(map[string]int64, len(i.feed.Routes))
+	for _, route := range i.feed.Routes {
+		if !isBusRoute(route.Type) {
+			continue
+		}
+		if id, ok := ids[busNum(route)]; ok {
+			result[route.ID] = id
+		}
+	}
+
+	return result, nil
+}
+
+func (i *gtfsImporter) busIDsByNum(ctx context.Context) (map[string]int64, error) {
+	buses, err := i.busStore.GetListByFilter(ctx, dataprovider.NewBusFilter().ByCities(i.city))
+	if err != nil {
+		return nil, err
+	}
+
+	var ids = make(map[string]int64, len(buses))
+	for _, bus := range buses {
+		ids[bus.Number] = bus.ID
+	}
+
+	return ids, nil
+}
+
+// importRoutes replaces the bus routes which differ from the stop sequences of the representative trips.
+// Every direction of a GTFS route becomes the main variant of the route pattern in that direction.
+func (i *gtfsImporter) importRoutes(ctx context.Context, stopIDs, busIDs map[string]int64) error {
+	var stopTimes = make(map[string][]gtfs.StopTime)
+	for _, stopTime := range i.feed.StopTimes {
+		stopTimes[stopTime.TripID] = append(stopTimes[stopTime.TripID], stopTime)
+	}
+
+	type routeDirection struct {
+		routeID     string
+		directionID int
+	}
+
+	// the trip with the most stops represents the route in the direction.
+	var trips = make(map[routeDirection]string)
+	for _, trip := range i.feed.Trips {
+		key := routeDirection{trip.RouteID, trip.DirectionID}
+		current, ok := trips[key]
+		if !ok || len(stopTimes[trip.ID]) > len(stopTimes[current]) {
+			trips[key] = trip.ID
+		}
+	}
+
+	for _, route := range i.feed.Routes {
+		busID, ok := busIDs[route.ID]
+		if !ok {
+			continue
+		}
+
+		var imported bool
+		for directionID, direction := range gtfsDirections {
+			tripID, ok := trips[routeDirection{route.ID, directionID}]
+			if !ok || len(stopTimes[tripID]) == 0 {
+				continue
+			}
+			imported = true
+
+			pattern := model.RoutePattern{
+				BusID:     busID,
+				Direction: direction,
+			}
+
+			if err := i.importRoute(ctx, route, pattern, tripID, stopTimes[tripID], stopIDs); err != nil {
+				return err
+			}
+		}
+
+		if !imported {
+			i.report.Routes.Skipped++
+			i.skip("route %s: no trips with stop times", route.ID)
+		}
+	}
+
+	return nil
+}
+
+func (i *gtfsImporter) importRoute(
+	ctx context.Context,
+	route gtfs.Route,
+	pattern model.RoutePattern,
+	tripID string,
+	sequence []gtfs.StopTime,
+	stopIDs map[string]int64,
+) error {
+	sort.SliceStable(sequence, func(a, b int) bool {
+		return sequence[a].StopSequence < sequence[b].StopSequence
+	})
+
+	if len(sequence) > model.MaxStep {
+		i.report.Routes.Skipped++
+		i.skip("route %s: trip %s has more than %d stops", route.ID, tripID, model.MaxStep)
+		return nil
+	}
+
+	var routes = make([]*model.Route, 0, len(sequence))
+	for k, stopTime := range sequence {
+		stopID, ok := stopIDs[stopTime.StopID]
+		if !ok {
+			i.report.Routes.Skipped++
+			i.skip("route %s: trip %s has unknown stop %s", route.ID, tripID, stopTime.StopID)
+			return nil
+		}
+
+		routes = append(routes, &model.Route{
+			RoutePattern: pattern,
+			StopID:       stopID,
+			Step:         k + 1,
+		})
+	}
+
+	return i.replaceRoute(ctx, pattern, routes)
+}
+
+func (i *gtfsImporter) replaceRoute(ctx context.Context, pattern model.RoutePattern, routes []*model.Route) error {
+	current, err := i.routeStore.GetListByFilter(ctx, dataprovider.NewRouteFilter().ByPattern(pattern))
+	if err != nil {
+		return err
+	}
+
+	if sameRoute(current, routes) {
+		i.report.Routes.Skipped++
+		return nil
+	}
+
+	if len(current) > 0 {
+		i.report.Routes.Updated++
+
+		if err := i.dropTimetable(ctx, pattern); err != nil {
+			return err
+		}
+	} else {
+		i.report.Routes.Created++
+	}
+
+	return i.routeStore.Replace(ctx, pattern, routes)
+}
+
+// dropTimetable deletes the timetable of the changed route pattern, as its stop times do not match the new stops.
+// The dropped timetables are reported, so they can be set again.
+func (i *gtfsImporter) dropTimetable(ctx context.Context, pattern model.RoutePattern) error {
+	filter := dataprovider.NewTimetableFilter().ByBusIDs(pattern.BusID)
+
+	trips, err := i.timetableStore.GetTrips(ctx, filter)
+	if err != nil {
+		return err
+	}
+
+	stopTimes, err := i.timetableStore.GetStopTimes(ctx, filter)
+	if err != nil {
+		return err
+	}
+
+	var exists bool
+	for _, trip := range trips {
+		exists = exists || trip.RoutePattern == pattern
+	}
+	for _, stopTime := range stopTimes {
+		exists = exists || stopTime.RoutePattern == pattern
+	}
+	if !exists {
+		return nil
+	}
+
+	if err := i.timetableStore.Set(ctx, pattern, nil, nil); err != nil {
+		return err
+	}
+
+	name := fmt.Sprintf("bus %d %s", pattern.BusID, pattern.Direction)
+	if pattern.Variant != "" {
+		name += " " + pattern.Variant
+	}
+	i.report.DroppedTimetables = append(i.report.DroppedTimetables, name)
+
+	return nil
+}
+
+func sameRoute(a, b []*model.Route) bool {
+	if len(a) != len(b) {
+		return false
+	}
+
+	for k := range a {
+		if a[k].StopID != b[k].StopID || a[k].Step != b[k].Step {
+			return false
+		}
+	}
+
+	return true
+}
+
+// gtfsDirections maps direction_id of trips.txt to the route directions.
+var gtfsDirections = [...]model.Direction{
+	gtfs.DirectionOutbound: model.DirectionOutbound,
+	gtfs.DirectionInbound:  model.DirectionInbound,
+}
+
+// isBusRoute returns true for the basic and the extended bus route types.
+func isBusRoute(routeType int) bool {
+	return routeType == gtfs.RouteTypeBus || routeType >= 700 && routeType < 800
+}
+
+func busNum(route gtfs.Route) string {
+	switch {
+	case route.ShortName != "":
+		return route.ShortName
+	case route.LongName != "":
+		return route.LongName
+	default:
+		return route.ID
+	}
+}
+
+// Defaults of the GTFS export for the data the schema lacks.
+const (
+	// gtfsDefaultStartTime is the start time of the only daily trip of a bus without trips, 06:00.
+	gtfsDefaultStartTime = 6 * 60 * 60
+	// gtfsDefaultStepTime is the time between stops of a bus without complete stop times, 2 minutes.
+	gtfsDefaultStepTime = 2 * 60
+	// gtfsServiceYears is the validity period of calendar.txt since the export date.
+	gtfsServiceYears = 1
+)
+
+// gtfsEveryDay is the service days of the default trip.
+var gtfsEveryDay = model.NewWeekdays(
+	time.Sunday, time.Monday, time.Tuesday, time.Wednesday, time.Thursday, time.Friday, time.Saturday,
+)
+
+// gtfsBus describes the route patterns of a bus prepared for the export.
+type gtfsBus struct {
+	id       int64
+	num      string
+	patterns []*gtfsPattern
+}
+
+// gtfsPattern describes the route and the trips of a bus route pattern prepared for the export.
+type gtfsPattern struct {
+	model.RoutePattern
+	routes    []*model.Route
+	trips     []*model.Trip
+	stopTimes map[int]*model.StopTime
+}
+
+// ExportGTFS writes the GTFS feed of the cities to w. Every city becomes an agency, every bus with a route - a route.
+// The buses of every city are loaded once and shared by the files of routes, trips, stop times and calendar,
+// the stops are loaded city by city.
+func (r *BusRoutes) ExportGTFS(ctx context.Context, filter *dataprovider.CityFilter, w io.Writer) error {
+	cities, err := r.cityStore.GetListByFilter(ctx, filter)
+	if err != nil {
+		return err
+	}
+	if len(cities) == 0 {
+		return ierr.NewReason(ierr.ErrNotFound).WithMessage("city")
+	}
+
+	var buses = make(map[int][]*gtfsBus, len(cities))
+	for _, city := range cities {
+		if buses[city.ID], err = r.gtfsBuses(ctx, city); err != nil {
+			return err
+		}
+	}
+
+	gw := gtfs.NewWriter(w)
+
+	steps := []struct {
+		file  string
+		write func(city *model.City) error
+	}{
+		{gtfs.FileAgency, func(city *model.City) error {
+			return gw.WriteAgency(gtfs.Agency{
+				ID:       strconv.Itoa(city.ID),
+				Name:     city.Name,
+				URL:      r.config.GTFS.AgencyURL,
+				Timezone: r.config.GTFS.AgencyTimezone,
+			})
+		}},
+		{gtfs.FileStops, func(city *model.City) error {
+			return r.exportGTFSStops(ctx, gw, city)
+		}},
+		{gtfs.FileRoutes, func(city *model.City) error {
+			return exportGTFSRoutes(gw, city, buses[city.ID])
+		}},
+		{gtfs.FileTrips, func(city *model.City) error {
+			return exportGTFSTrips(gw, buses[city.ID])
+		}},
+		{gtfs.FileStopTimes, func(city *model.City) error {
+			return exportGTFSStopTimes(gw, buses[city.ID])
+		}},
+	}
+
+	for _, step := range steps {
+		if err := gw.Start(step.file); err != nil {
+			return err
+		}
+
+		for _, city := range cities {
+			if err := step.write(city); err != nil {
+				return err
+			}
+		}
+	}
+
+	if err := exportGTFSCalendar(gw, buses); err != nil {
+		return err
+	}
+
+	return gw.Close()
+}
+
+func (r *BusRoutes) exportGTFSStops(ctx context.Context, gw *gtfs.Writer, city *model.City) error {
+	stops, err := r.stopStore.GetListByFilter(ctx, dataprovider.NewStopFilter().ByCities(city.Name))
+	if err != nil {
+		return err
+	}
+
+	for _, stop := range stops {
+		gtfsStop := gtfs.Stop{
+			ID:           strconv.FormatInt(stop.ID, 10),
+			Name:         stop.Address,
+			LocationType: gtfs.LocationStop,
+		}
+		if stop.Lat != nil && stop.Lon != nil {
+			gtfsStop.Lat, gtfsStop.Lon = *stop.Lat, *stop.Lon
+		}
+
+		if err := gw.WriteStop(gtfsStop); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func exportGTFSRoutes(gw *gtfs.Writer, city *model.City, buses []*gtfsBus) error {
+	for _, bus := range buses {
+		if err := gw.WriteRoute(gtfs.Route{
+			ID:        strconv.FormatInt(bus.id, 10),
+			AgencyID:  strconv.Itoa(city.ID),
+			ShortName: bus.num,
+			Type:      gtfs.RouteTypeBus,
+		}); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func exportGTFSTrips(gw *gtfs.Writer, buses []*gtfsBus) error {
+	for _, bus := range buses {
+		for _, pattern := range bus.patterns {
+			for _, trip := range pattern.trips {
+				if err := gw.WriteTrip(gtfs.Trip{
+					ID:          gtfsTripID(pattern, trip),
+					RouteID:     strconv.FormatInt(bus.id, 10),
+					ServiceID:   gtfsServiceID(trip.Days),
+					DirectionID: gtfsDirectionID(pattern.Direction),
+				}); err != nil {
+					return err
+				}
+			}
+		}
+	}
+
+	return nil
+}
+
+func exportGTFSStopTimes(gw *gtfs.Writer, buses []*gtfsBus) error {
+	for _, bus := range buses {
+		for _, pattern := range bus.patterns {
+			if err := exportGTFSPatternStopTimes(gw, pattern); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+func exportGTFSPatternStopTimes(gw *gtfs.Writer, pattern *gtfsPattern) error {
+	for _, trip := range pattern.trips {
+		for k, route := range pattern.routes {
+			arrival, departure := k*gtfsDefaultStepTime, k*gtfsDefaultStepTime
+			if pattern.stopTimes != nil {
+				stopTime := pattern.stopTimes[route.Step]
+				arrival, departure = stopTime.Arrival, stopTime.Departure
+			}
+
+			if err := gw.WriteStopTime(gtfs.StopTime{
+				TripID:        gtfsTripID(pattern, trip),
+				ArrivalTime:   trip.StartTime + arrival,
+				DepartureTime: trip.StartTime + departure,
+				StopID:        strconv.FormatInt(route.StopID, 10),
+				StopSequence:  int(route.Step),
+			}); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// exportGTFSCalendar writes a service for every combination of days used by the trips.
+func exportGTFSCalendar(gw *gtfs.Writer, buses map[int][]*gtfsBus) error {
+	var services = make(map[model.Weekdays]struct{})
+	for _, cityBuses := range buses {
+		for _, bus := range cityBuses {
+			for _, pattern := range bus.patterns {
+				for _, trip := range pattern.trips {
+					services[trip.Days] = struct{}{}
+				}
+			}
+		}
+	}
+
+	var days = make([]model.Weekdays, 0, len(services))
+	for d := range services {
+		days = append(days, d)
+	}
+	sort.Slice(days, func(i, j int) bool { return days[i] < days[j] })
+
+	if err := gw.Start(gtfs.FileCalendar); err != nil {
+		return err
+	}
+
+	var (
+		now       = time.Now()
+		startDate = now.Format("20060102")
+		endDate   = now.AddDate(gtfsServiceYears, 0, 0).Format("20060102")
+	)
+
+	for _, d := range days {
+		calendar := gtfs.Calendar{
+			ServiceID: gtfsServiceID(d),
+			StartDate: startDate,
+			EndDate:   endDate,
+		}
+		for _, day := range d.Days() {
+			calendar.Days[day] = true
+		}
+
+		if err := gw.WriteCalendar(calendar); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// gtfsBuses returns the buses of the city which have routes, with the trips and stop times of their route patterns.
+// A pattern without trips gets the default daily trip, a pattern with incomplete stop times gets none.
+func (r *BusRoutes) gtfsBuses(ctx context.Context, city *model.City) ([]*gtfsBus, error) {
+	routeFilter := dataprovider.NewRouteFilter().
+		ByCities(city.Name).
+		ViewDetailed()
+
+	dbRoutes, err := r.routeStore.GetListByFilter(ctx, routeFilter)
+	if err != nil {
+		return nil, err
+	}
+
+	var (
+		buses    = make([]*gtfsBus, 0)
+		byID     = make(map[int64]*gtfsBus)
+		patterns = make(map[model.RoutePattern]*gtfsPattern)
+		busIDs   = make([]int64, 0)
+	)
+
+	for _, route := range dbRoutes {
+		bus, ok := byID[route.BusID]
+		if !ok {
+			bus = &gtfsBus{
+				id:  route.BusID,
+				num: route.Number,
+			}
+			byID[route.BusID] = bus
+			buses = append(buses, bus)
+			busIDs = append(busIDs, route.BusID)
+		}
+
+		pattern, ok := patterns[route.RoutePattern]
+		if !ok {
+			pattern = &gtfsPattern{RoutePattern: route.RoutePattern}
+			patterns[route.RoutePattern] = pattern
+			bus.patterns = append(bus.patterns, pattern)
+		}
+
+		pattern.routes = append(pattern.routes, route)
+	}
+
+	if len(buses) == 0 {
+		return buses, nil
+	}
+
+	timetableFilter := dataprovider.NewTimetableFilter().ByBusIDs(busIDs...)
+
+	trips, err := r.timetableStore.GetTrips(ctx, timetableFilter)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, trip := range trips {
+		// the trips of a pattern without route are left out as the pattern has no stops.
+		if pattern, ok := patterns[trip.RoutePattern]; ok {
+			pattern.trips = append(pattern.trips, trip)
+		}
+	}
+
+	stopTimes, err := r.timetableStore.GetStopTimes(ctx, timetableFilter)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, stopTime := range stopTimes {
+		pattern := patterns[stopTime.RoutePattern]
+		if pattern.stopTimes == nil {
+			pattern.stopTimes = make(map[int]*model.StopTime)
+		}
+		pattern.stopTimes[stopTime.Step] = stopTime
+	}
+
+	for _, pattern := range patterns {
+		if len(pattern.trips) == 0 {
+			pattern.trips = []*model.Trip{{
+				RoutePattern: pattern.RoutePattern,
+				StartTime:    gtfsDefaultStartTime,
+				Days:         gtfsEveryDay,
+			}}
+		}
+
+		for _, route := range pattern.routes {
+			if _, ok := pattern.stopTimes[route.Step]; !ok {
+				pattern.stopTimes = nil
+				break
+			}
+		}
+	}
+
+	return buses, nil
+}
+
+// gtfsTripID returns the trip id, or the route pattern based id for the default trip.
+func gtfsTripID(pattern *gtfsPattern, trip *model.Trip) string {
+	if trip.ID != 0 {
+		return strconv.FormatInt(trip.ID, 10)
+	}
+
+	id := "bus" + strconv.FormatInt(pattern.BusID, 10) + "_" + pattern.Direction.String()
+	if pattern.Variant != "" {
+		id += "_" + pattern.Variant
+	}
+
+	return id
+}
+
+func gtfsDirectionID(direction model.Direction) int {
+	if direction == model.DirectionInbound {
+		return gtfs.DirectionInbound
+	}
+
+	return gtfs.DirectionOutbound
+}
+
+func gtfsServiceID(days model.Weekdays) string {
+	return "days" + strconv.Itoa(int(days))
+}
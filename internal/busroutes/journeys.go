@@ -0,0 +1,251 @@
+package busroutes
+
+import (
+	"context"
+	"sort"
+
+	httpv1 "github.com/gxravel/bus-routes/internal/api/http/handler/v1"
+	"github.com/gxravel/bus-routes/internal/dataprovider"
+	ierr "github.com/gxravel/bus-routes/internal/errors"
+	"github.com/gxravel/bus-routes/internal/model"
+)
+
+const (
+	maxJourneyTransfers = 2
+	maxJourneys         = 5
+)
+
+// GetJourneys returns itineraries between two stops ranked by the number of transfers, then stops.
+func (r *BusRoutes) GetJourneys(ctx context.Context, fromStopID, toStopID int64) ([]*httpv1.Journey, error) {
+	if fromStopID == toStopID {
+		return nil, ierr.NewReason(ierr.ErrValidationFailed).WithMessage("stops must be different")
+	}
+
+	stops, err := r.stopStore.GetListByFilter(ctx, dataprovider.NewStopFilter().ByIDs(fromStopID, toStopID))
+	if err != nil {
+		return nil, err
+	}
+	if len(stops) != 2 {
+		return nil, ierr.NewReason(ierr.ErrNotFound).WithMessage("stop")
+	}
+	if stops[0].City != stops[1].City {
+		return []*httpv1.Journey{}, nil
+	}
+
+	filter := dataprovider.NewRouteFilter().
+		ByCities(stops[0].City).
+		ViewDetailed()
+
+	dbRoutes, err := r.routeStore.GetListByFilter(ctx, filter)
+	if err != nil {
+		return nil, err
+	}
+
+	return newJourneyGraph(dbRoutes...).journeys(fromStopID, toStopID), nil
+}
+
+// busLine is the ordered list of stops visited by a bus in one route pattern.
+type busLine struct {
+	pattern model.RoutePattern
+	num     string
+	stops   []int64
+}
+
+// lineVisit describes the position of a stop in a bus line.
+type lineVisit struct {
+	line  *busLine
+	index int
+}
+
+// journeyLabel describes the way a stop is reached: the last leg and the label it was continued from.
+type journeyLabel struct {
+	stop  int64
+	stops int
+	line  *busLine
+	from  int
+	to    int
+	prev  *journeyLabel
+}
+
+func (l *journeyLabel) transfers() int {
+	var legs int
+	for cur := l; cur.prev != nil; cur = cur.prev {
+		legs++
+	}
+
+	return legs - 1
+}
+
+// journeyGraph is the directed graph of stops built from the routes.
+type journeyGraph struct {
+	visits    map[int64][]lineVisit
+	addresses map[int64]string
+}
+
+// newJourneyGraph builds the graph. It expects dbRoutes to be ordered by bus_id, direction, variant, step.
+func newJourneyGraph(dbRoutes ...*model.Route) *journeyGraph {
+	g := &journeyGraph{
+		visits:    make(map[int64][]lineVisit),
+		addresses: make(map[int64]string),
+	}
+
+	var line *busLine
+	for _, route := range dbRoutes {
+		if line == nil || line.pattern != route.RoutePattern {
+			line = &busLine{
+				pattern: route.RoutePattern,
+				num:     route.Number,
+			}
+		}
+
+		g.visits[route.StopID] = append(g.visits[route.StopID], lineVisit{
+			line:  line,
+			index: len(line.stops),
+		})
+		g.addresses[route.StopID] = route.Address
+		line.stops = append(line.stops, route.StopID)
+	}
+
+	return g
+}
+
+// journeys searches itineraries round by round, every round adds one more leg.
+// A stop is expanded in the next round only if it was reached with fewer stops than before.
+func (g *journeyGraph) journeys(from, to int64) []*httpv1.Journey {
+	var (
+		found  []*journeyLabel
+		best   = map[int64]int{from: 0}
+		marked = map[int64]*journeyLabel{from: {stop: from}}
+	)
+
+	for round := 0; round <= maxJourneyTransfers && len(marked) > 0; round++ {
+		next := make(map[int64]*journeyLabel)
+
+		for _, stop := range sortedStops(marked) {
+			label := marked[stop]
+
+			for _, visit := range g.visits[stop] {
+				if visit.line == label.line {
+					continue
+				}
+
+				for i := visit.index + 1; i < len(visit.line.stops); i++ {
+					reached := &journeyLabel{
+						stop:  visit.line.stops[i],
+						stops: label.stops + i - visit.index,
+						line:  visit.line,
+						from:  visit.index,
+						to:    i,
+						prev:  label,
+					}
+
+					if reached.stop == to {
+						found = append(found, reached)
+						break
+					}
+					if stops, ok := best[reached.stop]; ok && stops <= reached.stops {
+						continue
+					}
+					if l, ok := next[reached.stop]; ok && l.stops <= reached.stops {
+						continue
+					}
+
+					next[reached.stop] = reached
+				}
+			}
+		}
+
+		for stop, label := range next {
+			best[stop] = label.stops
+		}
+		marked = next
+	}
+
+	return g.toV1Journeys(rankJourneys(found))
+}
+
+// rankJourneys sorts labels by transfers and stops,
+// and drops those with more transfers which do not have fewer stops.
+func rankJourneys(found []*journeyLabel) []*journeyLabel {
+	sort.SliceStable(found, func(i, j int) bool {
+		ti, tj := found[i].transfers(), found[j].transfers()
+		if ti != tj {
+			return ti < tj
+		}
+		return found[i].stops < found[j].stops
+	})
+
+	var (
+		result    = make([]*journeyLabel, 0, maxJourneys)
+		transfers = -1
+		minStops  = -1
+		roundMin  = -1
+	)
+
+	for _, label := range found {
+		if len(result) == maxJourneys {
+			break
+		}
+
+		if t := label.transfers(); t != transfers {
+			transfers = t
+			if roundMin >= 0 && (minStops < 0 || roundMin < minStops) {
+				minStops = roundMin
+			}
+			roundMin = -1
+		}
+		if minStops >= 0 && label.stops >= minStops {
+			continue
+		}
+		if roundMin < 0 {
+			roundMin = label.stops
+		}
+
+		result = append(result, label)
+	}
+
+	return result
+}
+
+func (g *journeyGraph) toV1Journeys(labels []*journeyLabel) []*httpv1.Journey {
+	var journeys = make([]*httpv1.Journey, 0, len(labels))
+	for _, label := range labels {
+		journey := &httpv1.Journey{
+			Transfers: label.transfers(),
+			Stops:     label.stops,
+			Legs:      make([]httpv1.JourneyLeg, label.transfers()+1),
+		}
+
+		i := len(journey.Legs) - 1
+		for cur := label; cur.prev != nil; cur = cur.prev {
+			fromStopID := cur.line.stops[cur.from]
+			journey.Legs[i] = httpv1.JourneyLeg{
+				BusID:       cur.line.pattern.BusID,
+				Bus:         cur.line.num,
+				Direction:   cur.line.pattern.Direction,
+				Variant:     cur.line.pattern.Variant,
+				FromStopID:  fromStopID,
+				FromAddress: g.addresses[fromStopID],
+				ToStopID:    cur.stop,
+				ToAddress:   g.addresses[cur.stop],
+				Stops:       cur.to - cur.from,
+			}
+			i--
+		}
+
+		journeys = append(journeys, journey)
+	}
+
+	return journeys
+}
+
+func sortedStops(labels map[int64]*journeyLabel) []int64 {
+	var stops = make([]int64, 0, len(labels))
+	for stop := range labels {
+		stops = append(stops, stop)
+	}
+
+	sort.Slice(stops, func(i, j int) bool { return stops[i] < stops[j] })
+
+	return stops
+}
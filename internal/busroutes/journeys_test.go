@@ -0,0 +1,186 @@
+package busroutes
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	httpv1 "github.com/gxravel/bus-routes/internal/api/http/handler/v1"
+	"github.com/gxravel/bus-routes/internal/model"
+)
+
+func TestJourneys(t *testing.T) {
+	tests := []struct {
+		name  string
+		lines [][]*model.Route
+		from  int64
+		to    int64
+		// want are the journeys described by the legs "bus from>to:stops", separated by comma.
+		want []string
+	}{
+		{
+			name:  "direct",
+			lines: [][]*model.Route{line(1, "A", 1, 2, 3)},
+			from:  1,
+			to:    3,
+			want:  []string{"A 1>3:2"},
+		},
+		{
+			name:  "opposite direction",
+			lines: [][]*model.Route{line(1, "A", 1, 2, 3)},
+			from:  3,
+			to:    1,
+			want:  []string{},
+		},
+		{
+			name:  "one transfer",
+			lines: [][]*model.Route{line(1, "A", 1, 2, 3), line(2, "B", 3, 4, 5)},
+			from:  1,
+			to:    5,
+			want:  []string{"A 1>3:2, B 3>5:2"},
+		},
+		{
+			name:  "direct ranked by stops",
+			lines: [][]*model.Route{line(1, "A", 1, 2, 3), line(2, "B", 1, 3)},
+			from:  1,
+			to:    3,
+			want:  []string{"B 1>3:1", "A 1>3:2"},
+		},
+		{
+			name: "transfer with fewer stops is kept after direct",
+			lines: [][]*model.Route{
+				line(1, "A", 1, 2, 3, 4, 5),
+				line(2, "B", 1, 6),
+				line(3, "C", 6, 5),
+			},
+			from: 1,
+			to:   5,
+			want: []string{"A 1>5:4", "B 1>6:1, C 6>5:1"},
+		},
+		{
+			name: "transfer without fewer stops is dropped",
+			lines: [][]*model.Route{
+				line(1, "A", 1, 2, 3),
+				line(2, "B", 1, 4),
+				line(3, "C", 4, 5, 3),
+			},
+			from: 1,
+			to:   3,
+			want: []string{"A 1>3:2"},
+		},
+		{
+			name: "max transfers",
+			lines: [][]*model.Route{
+				line(1, "A", 1, 2),
+				line(2, "B", 2, 3),
+				line(3, "C", 3, 4),
+				line(4, "D", 4, 5),
+			},
+			from: 1,
+			to:   4,
+			want: []string{"A 1>2:1, B 2>3:1, C 3>4:1"},
+		},
+		{
+			name: "too many transfers",
+			lines: [][]*model.Route{
+				line(1, "A", 1, 2),
+				line(2, "B", 2, 3),
+				line(3, "C", 3, 4),
+				line(4, "D", 4, 5),
+			},
+			from: 1,
+			to:   5,
+			want: []string{},
+		},
+		{
+			name: "patterns of the same bus",
+			lines: [][]*model.Route{
+				line(1, "A", 1, 2, 3),
+				pattern(line(1, "A", 3, 2, 1), model.DirectionInbound, ""),
+			},
+			from: 3,
+			to:   1,
+			want: []string{"A 3>1:2"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var routes []*model.Route
+			for _, l := range tt.lines {
+				routes = append(routes, l...)
+			}
+
+			journeys := newJourneyGraph(routes...).journeys(tt.from, tt.to)
+
+			var got = make([]string, 0, len(journeys))
+			for _, journey := range journeys {
+				got = append(got, describeJourney(t, journey))
+			}
+
+			if strings.Join(got, "; ") != strings.Join(tt.want, "; ") {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+// line returns the steps of the outbound route of the bus visiting the stops in order.
+func line(busID int64, num string, stops ...int64) []*model.Route {
+	var routes = make([]*model.Route, 0, len(stops))
+	for step, stop := range stops {
+		routes = append(routes, &model.Route{
+			RoutePattern: model.RoutePattern{
+				BusID:     busID,
+				Direction: model.DefaultDirection,
+			},
+			StopID:  stop,
+			Step:    step + 1,
+			Address: fmt.Sprintf("stop %d", stop),
+			Number:  num,
+		})
+	}
+
+	return routes
+}
+
+// pattern sets the direction and the variant of the route.
+func pattern(routes []*model.Route, direction model.Direction, variant string) []*model.Route {
+	for _, route := range routes {
+		route.Direction = direction
+		route.Variant = variant
+	}
+
+	return routes
+}
+
+// describeJourney describes the legs of the journey and checks they are consistent with its totals.
+func describeJourney(t *testing.T, journey *httpv1.Journey) string {
+	t.Helper()
+
+	var (
+		legs  = make([]string, 0, len(journey.Legs))
+		stops int
+	)
+
+	for k, leg := range journey.Legs {
+		if k > 0 && journey.Legs[k-1].ToStopID != leg.FromStopID {
+			t.Errorf("leg %d starts at %d, previous ends at %d", k, leg.FromStopID, journey.Legs[k-1].ToStopID)
+		}
+		if want := fmt.Sprintf("stop %d", leg.ToStopID); leg.ToAddress != want {
+			t.Errorf("leg %d to address %q, want %q", k, leg.ToAddress, want)
+		}
+
+		stops += leg.Stops
+		legs = append(legs, fmt.Sprintf("%s %d>%d:%d", leg.Bus, leg.FromStopID, leg.ToStopID, leg.Stops))
+	}
+
+	if journey.Transfers != len(journey.Legs)-1 {
+		t.Errorf("transfers %d, want %d", journey.Transfers, len(journey.Legs)-1)
+	}
+	if journey.Stops != stops {
+		t.Errorf("stops %d, want %d", journey.Stops, stops)
+	}
+
+	return strings.Join(legs, ", ")
+}
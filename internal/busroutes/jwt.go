@@ -2,32 +2,84 @@ package busroutes
 
 import (
 	"context"
+	"fmt"
 
 	httpv1 "github.com/gxravel/bus-routes/internal/api/http/handler/v1"
+	"github.com/gxravel/bus-routes/internal/busroutescontext"
+	"github.com/gxravel/bus-routes/internal/dataprovider"
 	ierr "github.com/gxravel/bus-routes/internal/errors"
 	"github.com/gxravel/bus-routes/internal/jwt"
 	log "github.com/gxravel/bus-routes/internal/logger"
 	"github.com/gxravel/bus-routes/internal/model"
 )
 
+// maxUserAgentLength limits the user agent kept by the session.
+const maxUserAgentLength = 512
+
+// NewJWT returns the pair of tokens of the new session of the user, the session keeps the client of the request.
 func (r *BusRoutes) NewJWT(ctx context.Context, user *httpv1.User) (*httpv1.Token, error) {
-	jwtUser := &jwt.User{
-		ID:    user.ID,
-		Email: user.Email,
-		Type:  user.Type,
+	pair, err := r.tokenManager.SetNew(ctx, toJWTUser(user), requestClient(ctx))
+	if err != nil {
+		return nil, err
 	}
-	details, err := r.tokenManager.SetNew(ctx, jwtUser)
+	return toV1Token(pair), nil
+}
+
+// RefreshJWT exchanges the refresh token for the new pair of tokens with the current data of the user.
+func (r *BusRoutes) RefreshJWT(ctx context.Context, refreshToken string) (*httpv1.Token, error) {
+	claims, err := r.tokenManager.Rotate(ctx, refreshToken)
 	if err != nil {
 		return nil, err
 	}
-	return &httpv1.Token{
-		Token:  details.String,
-		Expiry: details.Expiry,
-	}, nil
+
+	users, err := r.GetUsers(ctx, dataprovider.NewUserFilter().ByIDs(int(claims.User.ID)))
+	if err != nil {
+		return nil, err
+	}
+	if len(users) == 0 {
+		if err := r.tokenManager.Revoke(ctx, claims.Family); err != nil {
+			return nil, err
+		}
+		return nil, ierr.NewReason(ierr.ErrUnauthorized).WithMessage("user does not exist")
+	}
+
+	pair, err := r.tokenManager.SetRotated(ctx, toJWTUser(users[0]), claims.Family)
+	if err != nil {
+		return nil, err
+	}
+
+	return toV1Token(pair), nil
+}
+
+// Logout revokes the access token and the refresh token issued with it.
+func (r *BusRoutes) Logout(ctx context.Context, token string) error {
+	claims, err := r.tokenManager.Parse(token)
+	if err != nil {
+		return err
+	}
+
+	if err := r.tokenManager.Delete(ctx, claims.Id); err != nil {
+		return err
+	}
+
+	if claims.Family == "" {
+		return nil
+	}
+
+	return r.tokenManager.Revoke(ctx, claims.Family)
 }
 
-// GetUserByToken returns user withdrawn from the JWT token claims, unless it is of not allowed type.
-func (r *BusRoutes) GetUserByToken(ctx context.Context, token string, allowedTypes ...model.UserType) (*httpv1.User, error) {
+// LogoutEverywhere revokes all the tokens of the user.
+func (r *BusRoutes) LogoutEverywhere(ctx context.Context, userID int64) error {
+	return r.tokenManager.RevokeAll(ctx, userID)
+}
+
+// GetUserByToken returns user withdrawn from the JWT token claims, unless its type lacks any of the permissions.
+// The token limited to the scopes is refused if no permission is required.
+// The token of OAuth 2.0 client is limited to its scopes instead.
+// The city scoped user without cities has none of the permissions, as it can not change anything.
+// The admin without 2FA has none of them either, if 2FA is enforced for admins.
+func (r *BusRoutes) GetUserByToken(ctx context.Context, token string, permissions ...model.Permission) (*httpv1.User, error) {
 	logger := log.FromContext(ctx).WithStr("token", token)
 
 	if token == "" {
@@ -40,15 +92,11 @@ func (r *BusRoutes) GetUserByToken(ctx context.Context, token string, allowedTyp
 		return nil, err
 	}
 
-	user := &httpv1.User{
-		ID:    jwtUser.ID,
-		Email: jwtUser.Email,
-		Type:  jwtUser.Type,
-	}
+	user := toTokenUser(jwtUser)
 
-	types := model.UserTypes(allowedTypes)
-	if !types.Exists(user.Type) {
-		err := ierr.NewReason(ierr.ErrPermissionDenied)
+	// the token limited to the scopes is refused where no permission is required, as the API key is.
+	if len(user.Scopes) > 0 && len(permissions) == 0 {
+		err := scopesDeniedError(permissions)
 
 		logger.
 			WithField("user", user).
@@ -57,5 +105,93 @@ func (r *BusRoutes) GetUserByToken(ctx context.Context, token string, allowedTyp
 		return nil, err
 	}
 
+	if len(permissions) > 0 {
+		granted, err := r.grantedPermissions(user)
+		if err == nil && !granted.Has(permissions...) {
+			err = ierr.NewReason(ierr.ErrPermissionDenied).
+				WithMessage(fmt.Sprintf("requires %v", permissions))
+		}
+		if err != nil {
+			logger.
+				WithField("user", user).
+				Warn(err.Error())
+
+			return nil, err
+		}
+	}
+
 	return user, nil
 }
+
+// grantedPermissions returns the permissions of the token user: of its type, or the scopes the token is limited to.
+// The user is granted none if its type is limited to the cities and it has no cities,
+// or it is the admin without 2FA while 2FA is enforced for the admins.
+func (r *BusRoutes) grantedPermissions(user *httpv1.User) (model.Permissions, error) {
+	switch {
+	case user.Type.IsCityScoped() && len(user.Cities) == 0:
+		return nil, ierr.NewReason(ierr.ErrPermissionDenied).
+			WithMessage("no cities are assigned to the user")
+
+	case r.config.TOTP.EnforceAdmin && user.Type == model.UserAdmin && !user.TwoFactor:
+		return nil, ierr.NewReason(ierr.ErrPermissionDenied).
+			WithMessage("two-factor authentication is required for admins")
+	}
+
+	if len(user.Scopes) > 0 {
+		return user.Scopes, nil
+	}
+
+	return user.Type.Permissions(), nil
+}
+
+// toTokenUser returns the user of the access token.
+func toTokenUser(jwtUser *jwt.User) *httpv1.User {
+	return &httpv1.User{
+		ID:        jwtUser.ID,
+		Email:     jwtUser.Email,
+		Type:      jwtUser.Type,
+		Cities:    jwtUser.Cities,
+		Verified:  jwtUser.Verified,
+		TwoFactor: jwtUser.TwoFactor,
+		Scopes:    jwtUser.Scopes,
+	}
+}
+
+// JWKS returns the public keys verifying the access tokens.
+func (r *BusRoutes) JWKS() *jwt.JWKS {
+	return r.tokenManager.JWKS()
+}
+
+// requestClient returns the client of the request kept by the session.
+func requestClient(ctx context.Context) *jwt.Client {
+	var client = &jwt.Client{}
+	if c := busroutescontext.GetClient(ctx); c != nil {
+		client.UserAgent = c.UserAgent
+		client.IP = c.IP
+	}
+	if len(client.UserAgent) > maxUserAgentLength {
+		client.UserAgent = client.UserAgent[:maxUserAgentLength]
+	}
+
+	return client
+}
+
+func toJWTUser(user *httpv1.User) *jwt.User {
+	return &jwt.User{
+		ID:        user.ID,
+		Email:     user.Email,
+		Type:      user.Type,
+		Cities:    user.Cities,
+		Verified:  user.Verified,
+		TwoFactor: user.TwoFactor,
+	}
+}
+
+func toV1Token(pair *jwt.Pair) *httpv1.Token {
+	return &httpv1.Token{
+		Token:         pair.Access.String,
+		Expiry:        pair.Access.Expiry,
+		RefreshToken:  pair.Refresh.String,
+		RefreshExpiry: pair.Refresh.Expiry,
+	}
+}
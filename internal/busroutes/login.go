@@ -0,0 +1,205 @@
+package busroutes
+
+import (
+	"context"
+	"sort"
+	"strings"
+	"time"
+
+	httpv1 "github.com/gxravel/bus-routes/internal/api/http/handler/v1"
+	ierr "github.com/gxravel/bus-routes/internal/errors"
+	log "github.com/gxravel/bus-routes/internal/logger"
+)
+
+// The prefixes of the login attempts keys of the email and the client IP.
+const (
+	loginEmailPrefix = "login:email:"
+	loginIPPrefix    = "login:ip:"
+)
+
+// loginLimit is the limit of the failed login attempts of the key.
+type loginLimit struct {
+	key          string
+	delayAfter   int64
+	lockoutAfter int64
+}
+
+func (r *BusRoutes) loginLimits(email, ip string) []loginLimit {
+	var limits = []loginLimit{
+		{
+			key:          loginEmailPrefix + email,
+			delayAfter:   r.config.Login.Email.DelayAfter,
+			lockoutAfter: r.config.Login.Email.LockoutAfter,
+		},
+	}
+
+	if ip != "" {
+		limits = append(limits, loginLimit{
+			key:          loginIPPrefix + ip,
+			delayAfter:   r.config.Login.IP.DelayAfter,
+			lockoutAfter: r.config.Login.IP.LockoutAfter,
+		})
+	}
+
+	return limits
+}
+
+// LoginAttempt is the login attempt counted for the email and the client IP before the credentials are checked.
+type LoginAttempt struct {
+	email  string
+	ip     string
+	limits []loginLimit
+	// counts are the numbers of the attempt in the windows of the limits, members are the members of the attempt.
+	counts  map[string]int64
+	members map[string]string
+}
+
+// CheckLoginAttempts counts the login attempt of the email and the client IP, and returns the error
+// if they are locked out, or the delay after the previous attempt has not passed yet.
+// The attempt is counted atomically before the credentials are checked, so the concurrent attempts
+// can not pass the limits together; the attempt stays counted as the failed one until ResetLoginAttempts.
+func (r *BusRoutes) CheckLoginAttempts(ctx context.Context, email, ip string) (*LoginAttempt, error) {
+	var attempt = &LoginAttempt{
+		email:   email,
+		ip:      ip,
+		limits:  r.loginLimits(email, ip),
+		counts:  make(map[string]int64, 2),
+		members: make(map[string]string, 2),
+	}
+
+	for _, limit := range attempt.limits {
+		ttl, err := r.storage.LockTTL(ctx, limit.key)
+		if err != nil {
+			return nil, err
+		}
+		if ttl > 0 {
+			return nil, r.dropLoginAttempt(ctx, attempt, ierr.NewReason(ierr.NewRetryAfter(ierr.ErrLockedOut, ttl)))
+		}
+
+		count, prev, member, err := r.storage.AddAttempt(ctx, limit.key, r.config.Login.Window)
+		if err != nil {
+			return nil, r.dropLoginAttempt(ctx, attempt, err)
+		}
+		attempt.counts[limit.key] = count
+		attempt.members[limit.key] = member
+
+		// the previous attempts are the failed ones or the ones in progress.
+		if failed := count - 1; failed >= limit.delayAfter {
+			if wait := time.Until(prev.Add(r.loginDelay(failed - limit.delayAfter))); wait > 0 {
+				return nil, r.dropLoginAttempt(ctx, attempt, ierr.NewReason(ierr.NewRetryAfter(ierr.ErrTooManyAttempts, wait)))
+			}
+		}
+	}
+
+	return attempt, nil
+}
+
+// dropLoginAttempt removes the rejected attempt, so the attempts which do not check the credentials are not counted.
+func (r *BusRoutes) dropLoginAttempt(ctx context.Context, attempt *LoginAttempt, err error) error {
+	for key, member := range attempt.members {
+		if rerr := r.storage.RemoveAttempt(ctx, key, member); rerr != nil {
+			log.FromContext(ctx).WithErr(rerr).Error("remove login attempt")
+		}
+	}
+
+	return err
+}
+
+// loginDelay returns the delay after the n-th delayed attempt, it doubles every attempt up to the max delay.
+func (r *BusRoutes) loginDelay(n int64) time.Duration {
+	delay := r.config.Login.BaseDelay
+	for i := int64(0); i < n && delay < r.config.Login.MaxDelay; i++ {
+		delay *= 2
+	}
+
+	if delay > r.config.Login.MaxDelay {
+		delay = r.config.Login.MaxDelay
+	}
+
+	return delay
+}
+
+// FailLogin locks out the email and the client IP if the failed attempt is too many one.
+func (r *BusRoutes) FailLogin(ctx context.Context, attempt *LoginAttempt) error {
+	logger := log.FromContext(ctx)
+
+	for _, limit := range attempt.limits {
+		count := attempt.counts[limit.key]
+		if count < limit.lockoutAfter {
+			continue
+		}
+
+		if err := r.storage.Lock(ctx, limit.key, r.config.Login.LockoutDuration); err != nil {
+			return err
+		}
+
+		logger.
+			WithStr("email", attempt.email).
+			WithStr("ip", attempt.ip).
+			WithStr("key", limit.key).
+			Warnf("login is locked out for %s after %d failed attempts", r.config.Login.LockoutDuration, count)
+	}
+
+	return nil
+}
+
+// ResetLoginAttempts resets the failed login attempts of the email after the successful login.
+// Only the successful attempt of the client IP is removed, else the valid account would let to guess the others.
+func (r *BusRoutes) ResetLoginAttempts(ctx context.Context, attempt *LoginAttempt) error {
+	if err := r.storage.ResetAttempts(ctx, loginEmailPrefix+attempt.email); err != nil {
+		return err
+	}
+
+	if member, ok := attempt.members[loginIPPrefix+attempt.ip]; ok {
+		return r.storage.RemoveAttempt(ctx, loginIPPrefix+attempt.ip, member)
+	}
+
+	return nil
+}
+
+// GetLoginLockouts returns the emails and the client IPs locked out, ordered by the time of unlocking.
+func (r *BusRoutes) GetLoginLockouts(ctx context.Context) ([]*httpv1.LoginLockout, error) {
+	locks, err := r.storage.Locks(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var lockouts = make([]*httpv1.LoginLockout, 0, len(locks))
+	for key, until := range locks {
+		var lockout = &httpv1.LoginLockout{Until: until.Unix()}
+
+		switch {
+		case strings.HasPrefix(key, loginEmailPrefix):
+			lockout.Email = strings.TrimPrefix(key, loginEmailPrefix)
+		case strings.HasPrefix(key, loginIPPrefix):
+			lockout.IP = strings.TrimPrefix(key, loginIPPrefix)
+		default:
+			continue
+		}
+
+		lockouts = append(lockouts, lockout)
+	}
+
+	sort.Slice(lockouts, func(i, j int) bool {
+		return lockouts[i].Until < lockouts[j].Until
+	})
+
+	return lockouts, nil
+}
+
+// UnlockLogin lifts the lockout of the email or the client IP, and resets their failed attempts.
+func (r *BusRoutes) UnlockLogin(ctx context.Context, email, ip string) error {
+	if email != "" {
+		if err := r.storage.Unlock(ctx, loginEmailPrefix+email); err != nil {
+			return err
+		}
+	}
+
+	if ip != "" {
+		if err := r.storage.Unlock(ctx, loginIPPrefix+ip); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,74 @@
+package busroutes
+
+import (
+	"testing"
+	"time"
+
+	"github.com/gxravel/bus-routes/internal/config"
+)
+
+func TestLoginDelay(t *testing.T) {
+	r := &BusRoutes{config: &config.Config{}}
+	r.config.Login.BaseDelay = time.Second
+	r.config.Login.MaxDelay = time.Second * 10
+
+	tests := []struct {
+		n    int64
+		want time.Duration
+	}{
+		{0, time.Second},
+		{1, time.Second * 2},
+		{3, time.Second * 8},
+		{4, time.Second * 10},
+		{64, time.Second * 10},
+	}
+
+	for _, tt := range tests {
+		if got := r.loginDelay(tt.n); got != tt.want {
+			t.Errorf("loginDelay(%d) = %s, want %s", tt.n, got, tt.want)
+		}
+	}
+}
+
+func TestLoginLimits(t *testing.T) {
+	r := &BusRoutes{config: &config.Config{}}
+	r.config.Login.Email.DelayAfter = 3
+	r.config.Login.Email.LockoutAfter = 10
+	r.config.Login.IP.DelayAfter = 20
+	r.config.Login.IP.LockoutAfter = 100
+
+	tests := []struct {
+		name string
+		ip   string
+		want []loginLimit
+	}{
+		{
+			name: "email and ip",
+			ip:   "203.0.113.7",
+			want: []loginLimit{
+				{key: "login:email:user@example.com", delayAfter: 3, lockoutAfter: 10},
+				{key: "login:ip:203.0.113.7", delayAfter: 20, lockoutAfter: 100},
+			},
+		},
+		{
+			name: "unknown ip",
+			want: []loginLimit{
+				{key: "login:email:user@example.com", delayAfter: 3, lockoutAfter: 10},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := r.loginLimits("user@example.com", tt.ip)
+			if len(got) != len(tt.want) {
+				t.Fatalf("got %+v, want %+v", got, tt.want)
+			}
+			for k := range got {
+				if got[k] != tt.want[k] {
+					t.Errorf("limit %d is %+v, want %+v", k, got[k], tt.want[k])
+				}
+			}
+		})
+	}
+}
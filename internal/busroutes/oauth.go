@@ -0,0 +1,123 @@
+package busroutes
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	httpv1 "github.com/gxravel/bus-routes/internal/api/http/handler/v1"
+	ierr "github.com/gxravel/bus-routes/internal/errors"
+	"github.com/gxravel/bus-routes/internal/model"
+)
+
+// oauthTokenType is the type of the tokens of OAuth 2.0 (RFC 6750).
+const oauthTokenType = "Bearer"
+
+// IssueClientToken issues the access token to OAuth 2.0 client by its credentials, the client is the API key.
+// The token is limited to the requested scope, which can not exceed the scopes of the key, or to the scopes of the key.
+func (r *BusRoutes) IssueClientToken(ctx context.Context, credentials *httpv1.ClientCredentials) (*httpv1.OAuthToken, error) {
+	dbKey, user, err := r.authenticateAPIKey(ctx, credentials.ClientSecret)
+	if err != nil {
+		return nil, err
+	}
+	if strconv.FormatInt(dbKey.ID, 10) != credentials.ClientID {
+		return nil, ierr.NewReason(ierr.ErrInvalidAPIKey).WithMessage("client_id does not match client_secret")
+	}
+
+	var scopes = dbKey.Scopes
+	if credentials.Scope != "" {
+		scopes = make(model.Permissions, 0)
+		for _, scope := range strings.Fields(credentials.Scope) {
+			if !dbKey.Scopes.Has(model.Permission(scope)) {
+				return nil, ierr.NewReason(ierr.ErrPermissionDenied).
+					WithMessage(fmt.Sprintf("scope %q is not granted to the client", scope))
+			}
+			scopes = append(scopes, model.Permission(scope))
+		}
+	}
+
+	client := requestClient(ctx)
+	client.APIKeyID = dbKey.ID
+
+	jwtUser := toJWTUser(user)
+	jwtUser.ClientID = credentials.ClientID
+	jwtUser.Scopes = scopes
+
+	token, err := r.tokenManager.SetAccess(ctx, jwtUser, client)
+	if err != nil {
+		return nil, err
+	}
+
+	r.touchAPIKey(ctx, dbKey)
+
+	return &httpv1.OAuthToken{
+		AccessToken: token.String,
+		TokenType:   oauthTokenType,
+		ExpiresIn:   token.Expiry - time.Now().Unix(),
+		Scope:       scopes.String(),
+	}, nil
+}
+
+// IntrospectToken returns the state of the access token (RFC 7662).
+// The scope is resolved as for the requests with the token, the token whose requests would be denied is not active.
+func (r *BusRoutes) IntrospectToken(ctx context.Context, token string) (*httpv1.TokenIntrospection, error) {
+	claims, err := r.tokenManager.Parse(token)
+	if err != nil || claims.User == nil {
+		return &httpv1.TokenIntrospection{}, nil
+	}
+
+	if err := r.tokenManager.CheckIfExists(ctx, claims.Id); err != nil {
+		return &httpv1.TokenIntrospection{}, nil
+	}
+
+	// the scope is what the token is granted by the API itself.
+	user := toTokenUser(claims.User)
+
+	scopes, err := r.grantedPermissions(user)
+	if err != nil {
+		return &httpv1.TokenIntrospection{}, nil
+	}
+	if len(user.Scopes) == 0 && !user.Verified {
+		scopes = scopes.ReadOnly()
+	}
+
+	var cities []string
+	if user.Type.IsCityScoped() {
+		cities = user.Cities
+	}
+
+	return &httpv1.TokenIntrospection{
+		Active:    true,
+		Scope:     scopes.String(),
+		Cities:    cities,
+		ClientID:  claims.User.ClientID,
+		Username:  claims.User.Email,
+		TokenType: oauthTokenType,
+		Exp:       claims.ExpiresAt,
+		Iat:       claims.IssuedAt,
+		Sub:       strconv.FormatInt(claims.User.ID, 10),
+		Jti:       claims.Id,
+	}, nil
+}
+
+// revokeClientSessions revokes the tokens issued to the API key as OAuth 2.0 client.
+func (r *BusRoutes) revokeClientSessions(ctx context.Context, userID, apiKeyID int64) error {
+	sessions, err := r.tokenManager.Sessions(ctx, userID)
+	if err != nil {
+		return err
+	}
+
+	for _, session := range sessions {
+		if session.APIKeyID != apiKeyID {
+			continue
+		}
+
+		if err := r.tokenManager.Revoke(ctx, session.ID); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -2,12 +2,16 @@ package busroutes
 
 import (
 	"context"
+	"fmt"
 
 	htppv1 "github.com/gxravel/bus-routes/internal/api/http/handler/v1"
 	"github.com/gxravel/bus-routes/internal/dataprovider"
+	ierr "github.com/gxravel/bus-routes/internal/errors"
 	"github.com/gxravel/bus-routes/internal/model"
 )
 
+const maxVariantLength = 64
+
 func (r *BusRoutes) GetRoutes(ctx context.Context, filter *dataprovider.RouteFilter) ([]*htppv1.Route, error) {
 	dbRoutes, err := r.routeStore.GetListByFilter(ctx, filter)
 	if err != nil {
@@ -27,59 +31,157 @@ func (r *BusRoutes) GetDetailedRoutes(ctx context.Context, filter *dataprovider.
 }
 
 func (r *BusRoutes) AddRoutes(ctx context.Context, routes ...*htppv1.Route) error {
-	return r.routeStore.Add(ctx, toDBRoutes(routes...)...)
+	dbRoutes, err := toDBRoutes(routes...)
+	if err != nil {
+		return err
+	}
+
+	if err := r.checkRoutesStops(ctx, dbRoutes...); err != nil {
+		return err
+	}
+
+	return r.routeStore.Add(ctx, dbRoutes...)
 }
 
 func (r *BusRoutes) UpdateRoute(ctx context.Context, route *htppv1.Route) error {
-	return r.routeStore.Update(ctx, toDBRoutes(route)[0])
+	dbRoutes, err := toDBRoutes(route)
+	if err != nil {
+		return err
+	}
+
+	if err := r.checkRoutesStops(ctx, dbRoutes...); err != nil {
+		return err
+	}
+
+	return r.routeStore.Update(ctx, dbRoutes[0])
 }
 
+// checkRoutesStops checks the stops and the city scope of the routes bus by bus.
+func (r *BusRoutes) checkRoutesStops(ctx context.Context, routes ...*model.Route) error {
+	var (
+		busIDs  = make([]int64, 0)
+		stopIDs = make(map[int64][]int64)
+	)
+
+	for _, route := range routes {
+		if _, ok := stopIDs[route.BusID]; !ok {
+			busIDs = append(busIDs, route.BusID)
+		}
+		stopIDs[route.BusID] = append(stopIDs[route.BusID], route.StopID)
+	}
+
+	if err := r.checkBusIDsCities(ctx, busIDs...); err != nil {
+		return err
+	}
+
+	for _, busID := range busIDs {
+		if err := r.checkRouteStops(ctx, busID, stopIDs[busID]...); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// DeleteRoute deletes the steps of the routes, the steps with the stop times of the timetable can not be deleted.
 func (r *BusRoutes) DeleteRoute(ctx context.Context, filter *dataprovider.RouteFilter) error {
-	return r.routeStore.Delete(ctx, filter)
+	if err := r.checkRoutesCities(ctx, filter); err != nil {
+		return err
+	}
+
+	if err := r.routeStore.Delete(ctx, filter); err != nil {
+		return ierr.CheckRestricted(err, timetableStepsMessage)
+	}
+
+	return nil
 }
 
-func toDBRoutes(routes ...*htppv1.Route) []*model.Route {
+// toDBPattern validates the direction and the variant, the empty direction is the default one.
+func toDBPattern(busID int64, direction model.Direction, variant string) (model.RoutePattern, error) {
+	if direction == "" {
+		direction = model.DefaultDirection
+	}
+	if !direction.IsValid() {
+		return model.RoutePattern{}, ierr.NewReason(ierr.ErrValidationFailed).
+			WithMessage(fmt.Sprintf("invalid direction %q", direction))
+	}
+	if len(variant) > maxVariantLength {
+		return model.RoutePattern{}, ierr.NewReason(ierr.ErrValidationFailed).
+			WithMessage(fmt.Sprintf("variant is longer than %d bytes", maxVariantLength))
+	}
+
+	return model.RoutePattern{
+		BusID:     busID,
+		Direction: direction,
+		Variant:   variant,
+	}, nil
+}
+
+// checkStep returns the error if the step is out of [1, model.MaxStep].
+func checkStep(step int) error {
+	if step < 1 || step > model.MaxStep {
+		return ierr.NewReason(ierr.ErrValidationFailed).
+			WithMessage(fmt.Sprintf("step must be in [1, %d]", model.MaxStep))
+	}
+
+	return nil
+}
+
+func toDBRoutes(routes ...*htppv1.Route) ([]*model.Route, error) {
 	var dbRoutes = make([]*model.Route, 0, len(routes))
 	for _, route := range routes {
+		pattern, err := toDBPattern(route.BusID, route.Direction, route.Variant)
+		if err != nil {
+			return nil, err
+		}
+
+		if err := checkStep(route.Step); err != nil {
+			return nil, err
+		}
+
 		dbRoutes = append(dbRoutes, &model.Route{
-			BusID:  route.BusID,
-			StopID: route.StopID,
-			Step:   route.Step,
+			RoutePattern: pattern,
+			StopID:       route.StopID,
+			Step:         route.Step,
 		})
 	}
 
-	return dbRoutes
+	return dbRoutes, nil
 }
 
 func toV1Routes(dbRoutes ...*model.Route) []*htppv1.Route {
 	var routes = make([]*htppv1.Route, 0, len(dbRoutes))
 	for _, route := range dbRoutes {
 		routes = append(routes, &htppv1.Route{
-			BusID:  route.BusID,
-			StopID: route.StopID,
-			Step:   route.Step,
+			BusID:     route.BusID,
+			Direction: route.Direction,
+			Variant:   route.Variant,
+			StopID:    route.StopID,
+			Step:      route.Step,
 		})
 	}
 
 	return routes
 }
 
-// toV1RoutesDetailed converts dbRoutes to v1.RouteDetailed.
-// It expects dbRoutes to be ordered by bus_id.
+// toV1RoutesDetailed converts dbRoutes to v1.RouteDetailed, one per route pattern.
+// It expects dbRoutes to be ordered by bus_id, direction, variant.
 func toV1RoutesDetailed(dbRoutes ...*model.Route) []*htppv1.RouteDetailed {
 	var (
 		routes    = make([]*htppv1.RouteDetailed, 0)
-		busID     int64
+		pattern   model.RoutePattern
 		lastRoute int = -1
 	)
 
 	for _, route := range dbRoutes {
-		if busID != route.BusID {
-			busID = route.BusID
+		if pattern != route.RoutePattern {
+			pattern = route.RoutePattern
 			routes = append(routes, &htppv1.RouteDetailed{
-				City:   route.City,
-				Bus:    route.Number,
-				Points: make([]htppv1.RoutePoint, 0),
+				City:      route.City,
+				Bus:       route.Number,
+				Direction: route.Direction,
+				Variant:   route.Variant,
+				Points:    make([]htppv1.RoutePoint, 0),
 			})
 
 			lastRoute++
@@ -88,6 +190,8 @@ func toV1RoutesDetailed(dbRoutes ...*model.Route) []*htppv1.RouteDetailed {
 		routes[lastRoute].Points = append(routes[lastRoute].Points, htppv1.RoutePoint{
 			Step:    route.Step,
 			Address: route.Address,
+			Lat:     route.Lat,
+			Lon:     route.Lon,
 		})
 	}
 
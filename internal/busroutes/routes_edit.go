@@ -0,0 +1,291 @@
+package busroutes
+
+import (
+	"context"
+	"fmt"
+
+	httpv1 "github.com/gxravel/bus-routes/internal/api/http/handler/v1"
+	"github.com/gxravel/bus-routes/internal/dataprovider"
+	ierr "github.com/gxravel/bus-routes/internal/errors"
+	"github.com/gxravel/bus-routes/internal/model"
+)
+
+// timetableStepsMessage is the message of the error deleting or changing the stops of the steps of the route with the stop times.
+const timetableStepsMessage = "the stop times of the timetable refer to the deleted or changed steps, set the timetable without them first"
+
+// routeEdit changes the route pattern in the transaction, current is the locked route ordered by step.
+type routeEdit func(ctx context.Context, store dataprovider.RouteStore, current []*model.Route) error
+
+// InsertRouteStop inserts the stop at the step of the route pattern, the later steps are shifted forward.
+// The stop can be on the route already, e.g. the loop one, but not at the adjacent step.
+func (r *BusRoutes) InsertRouteStop(
+	ctx context.Context,
+	busID int64,
+	insert *httpv1.RouteStopInsert,
+) (*httpv1.RouteDetailed, error) {
+	pattern, err := toDBPattern(busID, insert.Direction, insert.Variant)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := r.checkRouteStops(ctx, busID, insert.StopID); err != nil {
+		return nil, err
+	}
+
+	edit := func(ctx context.Context, store dataprovider.RouteStore, current []*model.Route) error {
+		if err := checkRouteSteps(current); err != nil {
+			return err
+		}
+		if len(current) >= model.MaxStep {
+			return ierr.NewReason(ierr.ErrValidationFailed).
+				WithMessage(fmt.Sprintf("route can not have more than %d stops", model.MaxStep))
+		}
+		if insert.Step < 1 || int(insert.Step) > len(current)+1 {
+			return ierr.NewReason(ierr.ErrValidationFailed).
+				WithMessage(fmt.Sprintf("step must be in [1, %d]", len(current)+1))
+		}
+		// the loop route visits the stop again later, but not at the next step.
+		for _, route := range current {
+			if route.StopID == insert.StopID && (route.Step == insert.Step || route.Step == insert.Step-1) {
+				return ierr.NewReason(ierr.ErrValidationFailed).
+					WithMessage(fmt.Sprintf("stop %d is already at the adjacent step %d", route.StopID, route.Step))
+			}
+		}
+
+		if err := store.ShiftSteps(ctx, pattern, insert.Step, 1); err != nil {
+			return err
+		}
+
+		return store.Add(ctx, &model.Route{
+			RoutePattern: pattern,
+			StopID:       insert.StopID,
+			Step:         insert.Step,
+		})
+	}
+
+	return r.editRoute(ctx, pattern, edit)
+}
+
+// RemoveRouteStop removes the stop at the step of the route pattern, the later steps are shifted back.
+func (r *BusRoutes) RemoveRouteStop(
+	ctx context.Context,
+	busID int64,
+	remove *httpv1.RouteStopRemove,
+) (*httpv1.RouteDetailed, error) {
+	pattern, err := toDBPattern(busID, remove.Direction, remove.Variant)
+	if err != nil {
+		return nil, err
+	}
+
+	edit := func(ctx context.Context, store dataprovider.RouteStore, current []*model.Route) error {
+		if err := checkRouteSteps(current); err != nil {
+			return err
+		}
+		if remove.Step < 1 || int(remove.Step) > len(current) {
+			return ierr.NewReason(ierr.ErrValidationFailed).
+				WithMessage(fmt.Sprintf("step must be in [1, %d]", len(current)))
+		}
+
+		filter := dataprovider.NewRouteFilter().
+			ByPattern(pattern).
+			BySteps(remove.Step)
+
+		if err := store.Delete(ctx, filter); err != nil {
+			return err
+		}
+
+		return store.ShiftSteps(ctx, pattern, remove.Step+1, -1)
+	}
+
+	return r.editRoute(ctx, pattern, edit)
+}
+
+// ReorderRouteStops renumbers the steps of the route pattern in the given order.
+// The order must list every current step once, the gaps in the current steps are closed.
+func (r *BusRoutes) ReorderRouteStops(
+	ctx context.Context,
+	busID int64,
+	reorder *httpv1.RouteStopsReorder,
+) (*httpv1.RouteDetailed, error) {
+	pattern, err := toDBPattern(busID, reorder.Direction, reorder.Variant)
+	if err != nil {
+		return nil, err
+	}
+
+	edit := func(ctx context.Context, store dataprovider.RouteStore, current []*model.Route) error {
+		if len(reorder.Steps) != len(current) {
+			return ierr.NewReason(ierr.ErrValidationFailed).
+				WithMessage(fmt.Sprintf("steps must list all %d steps of the route", len(current)))
+		}
+
+		var existing = make(map[int]bool, len(current))
+		for _, route := range current {
+			existing[route.Step] = true
+		}
+
+		var steps = make(map[int]int, len(reorder.Steps))
+		for k, step := range reorder.Steps {
+			if !existing[step] {
+				return ierr.NewReason(ierr.ErrValidationFailed).
+					WithMessage(fmt.Sprintf("route has no step %d", step))
+			}
+			if _, ok := steps[step]; ok {
+				return ierr.NewReason(ierr.ErrValidationFailed).
+					WithMessage(fmt.Sprintf("duplicate step %d", step))
+			}
+
+			steps[step] = k + 1
+		}
+
+		return store.Renumber(ctx, pattern, steps)
+	}
+
+	return r.editRoute(ctx, pattern, edit)
+}
+
+// ReplaceRoute replaces the stops of the route pattern with the given ones in order.
+// The stop can be repeated, e.g. by the loop route, but not at the adjacent steps.
+// The steps with the stop times of the timetable keep their stops, or the timetable must be set without them first.
+func (r *BusRoutes) ReplaceRoute(
+	ctx context.Context,
+	busID int64,
+	replace *httpv1.RouteReplace,
+) (*httpv1.RouteDetailed, error) {
+	pattern, err := toDBPattern(busID, replace.Direction, replace.Variant)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(replace.StopIDs) > model.MaxStep {
+		return nil, ierr.NewReason(ierr.ErrValidationFailed).
+			WithMessage(fmt.Sprintf("route can not have more than %d stops", model.MaxStep))
+	}
+
+	for k := 1; k < len(replace.StopIDs); k++ {
+		if replace.StopIDs[k] == replace.StopIDs[k-1] {
+			return nil, ierr.NewReason(ierr.ErrValidationFailed).
+				WithMessage(fmt.Sprintf("stop %d is repeated at adjacent steps %d and %d", replace.StopIDs[k], k, k+1))
+		}
+	}
+
+	if err := r.checkRouteStops(ctx, busID, replace.StopIDs...); err != nil {
+		return nil, err
+	}
+
+	var routes = make([]*model.Route, 0, len(replace.StopIDs))
+	for k, stopID := range replace.StopIDs {
+		routes = append(routes, &model.Route{
+			RoutePattern: pattern,
+			StopID:       stopID,
+			Step:         k + 1,
+		})
+	}
+
+	edit := func(ctx context.Context, store dataprovider.RouteStore, current []*model.Route) error {
+		return store.Replace(ctx, pattern, routes)
+	}
+
+	return r.editRoute(ctx, pattern, edit)
+}
+
+// checkRouteStops returns the error if the bus or any of the stops do not exist,
+// or the stops are not in the city of the bus.
+func (r *BusRoutes) checkRouteStops(ctx context.Context, busID int64, stopIDs ...int64) error {
+	bus, err := r.busStore.GetByFilter(ctx, dataprovider.NewBusFilter().ByIDs(busID))
+	if err != nil {
+		return err
+	}
+	if bus == nil {
+		return ierr.NewReason(ierr.ErrNotFound).WithMessage(fmt.Sprintf("bus %d", busID))
+	}
+
+	if len(stopIDs) == 0 {
+		return nil
+	}
+
+	stops, err := r.stopStore.GetListByFilter(ctx, dataprovider.NewStopFilter().ByIDs(stopIDs...))
+	if err != nil {
+		return err
+	}
+
+	var cities = make(map[int64]string, len(stops))
+	for _, stop := range stops {
+		cities[stop.ID] = stop.City
+	}
+
+	for _, stopID := range stopIDs {
+		city, ok := cities[stopID]
+		if !ok {
+			return ierr.NewReason(ierr.ErrNotFound).WithMessage(fmt.Sprintf("stop %d", stopID))
+		}
+		if city != bus.City {
+			return ierr.NewReason(ierr.ErrValidationFailed).
+				WithMessage(fmt.Sprintf("stop %d is in %s, not in the bus city %s", stopID, city, bus.City))
+		}
+	}
+
+	return nil
+}
+
+// editRoute locks the route pattern, applies the edit in one transaction and returns the resulting route.
+// The edit deleting the steps with the stop times of the timetable or changing their stops fails with the conflict.
+// The city scoped user edits only the routes of the buses of its cities.
+func (r *BusRoutes) editRoute(
+	ctx context.Context,
+	pattern model.RoutePattern,
+	edit routeEdit,
+) (*httpv1.RouteDetailed, error) {
+	if err := r.checkBusIDsCities(ctx, pattern.BusID); err != nil {
+		return nil, err
+	}
+
+	f := func(tx *dataprovider.Tx) error {
+		store := r.routeStore.WithTx(tx)
+
+		filter := dataprovider.NewRouteFilter().
+			ByPattern(pattern).
+			LockForUpdate()
+
+		current, err := store.GetListByFilter(ctx, filter)
+		if err != nil {
+			return err
+		}
+
+		return edit(ctx, store, current)
+	}
+
+	if err := dataprovider.BeginAutoCommitedTx(ctx, r.txer, f); err != nil {
+		return nil, ierr.CheckRestricted(err, timetableStepsMessage)
+	}
+
+	return r.getRoutePattern(ctx, pattern)
+}
+
+// getRoutePattern returns the detailed route of the pattern, the route without stops has no points.
+func (r *BusRoutes) getRoutePattern(ctx context.Context, pattern model.RoutePattern) (*httpv1.RouteDetailed, error) {
+	routes, err := r.GetDetailedRoutes(ctx, dataprovider.NewRouteFilter().ByPattern(pattern).ViewDetailed())
+	if err != nil {
+		return nil, err
+	}
+	if len(routes) > 0 {
+		return routes[0], nil
+	}
+
+	return &httpv1.RouteDetailed{
+		Direction: pattern.Direction,
+		Variant:   pattern.Variant,
+		Points:    make([]httpv1.RoutePoint, 0),
+	}, nil
+}
+
+// checkRouteSteps returns the error if the steps of the route ordered by step are not 1, 2, ..., n.
+func checkRouteSteps(routes []*model.Route) error {
+	for k, route := range routes {
+		if int(route.Step) != k+1 {
+			return ierr.NewReason(ierr.ErrValidationFailed).
+				WithMessage(fmt.Sprintf("route has a gap before step %d, reorder it first", route.Step))
+		}
+	}
+
+	return nil
+}
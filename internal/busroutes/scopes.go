@@ -0,0 +1,128 @@
+package busroutes
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/gxravel/bus-routes/internal/busroutescontext"
+	"github.com/gxravel/bus-routes/internal/dataprovider"
+	ierr "github.com/gxravel/bus-routes/internal/errors"
+)
+
+var errNoUser = ierr.NewReason(ierr.ErrUnauthorized).WithMessage("the request has no user")
+
+// cityScope returns the cities of the user of the request, if its type is city scoped.
+// The request without the user is refused, unless it is made by the trusted internal caller, which is not scoped.
+func cityScope(ctx context.Context) (map[string]bool, bool, error) {
+	if busroutescontext.IsTrusted(ctx) {
+		return nil, false, nil
+	}
+
+	user := busroutescontext.GetUser(ctx)
+	if user == nil {
+		return nil, false, errNoUser
+	}
+	if !user.Type.IsCityScoped() {
+		return nil, false, nil
+	}
+
+	var cities = make(map[string]bool, len(user.Cities))
+	for _, city := range user.Cities {
+		cities[city] = true
+	}
+
+	return cities, true, nil
+}
+
+// checkCities returns the error if the user of the request is city scoped, and any of the cities is out of its scope.
+func checkCities(ctx context.Context, cities ...string) error {
+	scope, ok, err := cityScope(ctx)
+	if err != nil || !ok {
+		return err
+	}
+
+	for _, city := range cities {
+		if !scope[city] {
+			return ierr.NewReason(ierr.ErrPermissionDenied).
+				WithMessage(fmt.Sprintf("city %s is out of the user scope", city))
+		}
+	}
+
+	return nil
+}
+
+// checkBusesCities checks the cities of the buses.
+func (r *BusRoutes) checkBusesCities(ctx context.Context, filter *dataprovider.BusFilter) error {
+	if _, ok, err := cityScope(ctx); err != nil || !ok {
+		return err
+	}
+
+	buses, err := r.busStore.GetListByFilter(ctx, filter)
+	if err != nil {
+		return err
+	}
+
+	var cities = make([]string, 0, len(buses))
+	for _, bus := range buses {
+		cities = append(cities, bus.City)
+	}
+
+	return checkCities(ctx, cities...)
+}
+
+// checkBusIDsCities checks the cities of the buses by ids.
+func (r *BusRoutes) checkBusIDsCities(ctx context.Context, busIDs ...int64) error {
+	if len(busIDs) == 0 {
+		return nil
+	}
+
+	return r.checkBusesCities(ctx, dataprovider.NewBusFilter().ByIDs(busIDs...))
+}
+
+// checkStopsCities checks the cities of the stops.
+func (r *BusRoutes) checkStopsCities(ctx context.Context, filter *dataprovider.StopFilter) error {
+	if _, ok, err := cityScope(ctx); err != nil || !ok {
+		return err
+	}
+
+	stops, err := r.stopStore.GetListByFilter(ctx, filter)
+	if err != nil {
+		return err
+	}
+
+	var cities = make([]string, 0, len(stops))
+	for _, stop := range stops {
+		cities = append(cities, stop.City)
+	}
+
+	return checkCities(ctx, cities...)
+}
+
+// checkRoutesCities checks the cities of the buses of the routes.
+func (r *BusRoutes) checkRoutesCities(ctx context.Context, filter *dataprovider.RouteFilter) error {
+	if _, ok, err := cityScope(ctx); err != nil || !ok {
+		return err
+	}
+
+	if len(filter.BusIDs) > 0 {
+		return r.checkBusIDsCities(ctx, filter.BusIDs...)
+	}
+
+	routes, err := r.routeStore.GetListByFilter(ctx, filter)
+	if err != nil {
+		return err
+	}
+
+	var (
+		busIDs = make([]int64, 0)
+		added  = make(map[int64]bool)
+	)
+	for _, route := range routes {
+		if !added[route.BusID] {
+			busIDs = append(busIDs, route.BusID)
+			added[route.BusID] = true
+		}
+	}
+
+	return r.checkBusIDsCities(ctx, busIDs...)
+}
@@ -0,0 +1,76 @@
+package busroutes
+
+import (
+	"context"
+	"fmt"
+
+	httpv1 "github.com/gxravel/bus-routes/internal/api/http/handler/v1"
+	"github.com/gxravel/bus-routes/internal/busroutescontext"
+	ierr "github.com/gxravel/bus-routes/internal/errors"
+	"github.com/gxravel/bus-routes/internal/jwt"
+)
+
+// GetSessions returns the sessions of the user, the session of the request is marked as current.
+func (r *BusRoutes) GetSessions(ctx context.Context, userID int64) ([]*httpv1.Session, error) {
+	if _, err := r.GetUser(ctx, userID); err != nil {
+		return nil, err
+	}
+
+	jwtSessions, err := r.tokenManager.Sessions(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	sessions := toV1Sessions(jwtSessions...)
+
+	current := r.currentSession(ctx)
+	for _, session := range sessions {
+		session.Current = session.ID == current
+	}
+
+	return sessions, nil
+}
+
+// RevokeSession revokes the tokens of the session of the user.
+func (r *BusRoutes) RevokeSession(ctx context.Context, userID int64, id string) error {
+	session, err := r.tokenManager.Session(ctx, id)
+	if err != nil {
+		return err
+	}
+	if session == nil || session.UserID != userID {
+		return ierr.NewReason(ierr.ErrNotFound).WithMessage(fmt.Sprintf("session %s", id))
+	}
+
+	return r.tokenManager.Revoke(ctx, id)
+}
+
+// currentSession returns the id of the session of the request, it is empty if the request is not authorized by token.
+func (r *BusRoutes) currentSession(ctx context.Context) string {
+	token := busroutescontext.GetToken(ctx)
+	if token == "" {
+		return ""
+	}
+
+	claims, err := r.tokenManager.Parse(token)
+	if err != nil {
+		return ""
+	}
+
+	return claims.Family
+}
+
+func toV1Sessions(jwtSessions ...*jwt.Session) []*httpv1.Session {
+	var sessions = make([]*httpv1.Session, 0, len(jwtSessions))
+	for _, session := range jwtSessions {
+		sessions = append(sessions, &httpv1.Session{
+			ID:        session.ID,
+			IssuedAt:  session.IssuedAt,
+			LastUsed:  session.LastUsed,
+			UserAgent: session.UserAgent,
+			IP:        session.IP,
+			APIKeyID:  session.APIKeyID,
+		})
+	}
+
+	return sessions
+}
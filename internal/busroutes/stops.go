@@ -2,12 +2,23 @@ package busroutes
 
 import (
 	"context"
+	"math"
+	"sort"
 
 	httpv1 "github.com/gxravel/bus-routes/internal/api/http/handler/v1"
 	"github.com/gxravel/bus-routes/internal/dataprovider"
+	ierr "github.com/gxravel/bus-routes/internal/errors"
 	"github.com/gxravel/bus-routes/internal/model"
 )
 
+const routeStopsMessage = "the routes refer to the deleted stops, remove the stops from the routes first"
+
+var (
+	errNothingToUpdate    = ierr.NewReason(ierr.ErrMustProvide).WithMessage("city, address or location")
+	errIncompleteLocation = ierr.NewReason(ierr.ErrValidationFailed).WithMessage("lat and lon must be set together")
+	errInvalidLocation    = ierr.NewReason(ierr.ErrValidationFailed).WithMessage("lat must be in [-90, 90], lon in [-180, 180]")
+)
+
 func (r *BusRoutes) GetStops(ctx context.Context, filter *dataprovider.StopFilter) ([]*httpv1.Stop, error) {
 	dbStops, err := r.stopStore.GetListByFilter(ctx, filter)
 	if err != nil {
@@ -17,16 +28,112 @@ func (r *BusRoutes) GetStops(ctx context.Context, filter *dataprovider.StopFilte
 	return toV1Stops(dbStops...), nil
 }
 
+// GetNearbyStops returns the stops within the radius around the filter location ordered by the distance.
+func (r *BusRoutes) GetNearbyStops(
+	ctx context.Context,
+	filter *dataprovider.StopFilter,
+	paginator *dataprovider.Paginator,
+) ([]*httpv1.NearbyStop, error) {
+	dbStops, err := r.stopStore.GetListByFilter(ctx, filter)
+	if err != nil {
+		return nil, err
+	}
+
+	var stops = make([]*httpv1.NearbyStop, 0, len(dbStops))
+	for _, stop := range dbStops {
+		if stop.Lat == nil || stop.Lon == nil {
+			continue
+		}
+
+		// the store filters by the bounding box, so the corners are left out here.
+		meters := distance(filter.Location.Lat, filter.Location.Lon, *stop.Lat, *stop.Lon)
+		if meters > filter.Location.Radius {
+			continue
+		}
+
+		stops = append(stops, &httpv1.NearbyStop{
+			Stop:     *toV1Stops(stop)[0],
+			Distance: math.Round(meters),
+		})
+	}
+
+	sort.SliceStable(stops, func(i, j int) bool {
+		return stops[i].Distance < stops[j].Distance
+	})
+
+	offset := int(paginator.Offset())
+	if offset > len(stops) {
+		offset = len(stops)
+	}
+	stops = stops[offset:]
+
+	if limit := int(paginator.Limit()); limit < len(stops) {
+		stops = stops[:limit]
+	}
+
+	return stops, nil
+}
+
 func (r *BusRoutes) AddStops(ctx context.Context, stops ...*httpv1.Stop) error {
+	var cities = make([]string, 0, len(stops))
+	for _, stop := range stops {
+		if err := validateLocation(stop); err != nil {
+			return err
+		}
+
+		cities = append(cities, stop.City)
+	}
+
+	if err := checkCities(ctx, cities...); err != nil {
+		return err
+	}
+
 	return r.stopStore.Add(ctx, toDBStops(stops...)...)
 }
 
+// UpdateStops updates the fields of the stop which are set, the city scoped user can not move it out of its cities or into them.
 func (r *BusRoutes) UpdateStops(ctx context.Context, stop *httpv1.Stop) error {
+	if stop.City == "" && stop.Address == "" && stop.Lat == nil {
+		return errNothingToUpdate
+	}
+	if err := validateLocation(stop); err != nil {
+		return err
+	}
+
+	if stop.City != "" {
+		if err := checkCities(ctx, stop.City); err != nil {
+			return err
+		}
+	}
+	if err := r.checkStopsCities(ctx, dataprovider.NewStopFilter().ByIDs(stop.ID)); err != nil {
+		return err
+	}
+
 	return r.stopStore.Update(ctx, toDBStops(stop)[0])
 }
 
 func (r *BusRoutes) DeleteStop(ctx context.Context, filter *dataprovider.StopFilter) error {
-	return r.stopStore.Delete(ctx, filter)
+	if err := r.checkStopsCities(ctx, filter); err != nil {
+		return err
+	}
+
+	if err := r.stopStore.Delete(ctx, filter); err != nil {
+		return ierr.CheckRestricted(err, routeStopsMessage)
+	}
+
+	return nil
+}
+
+// validateLocation returns the error if only one of the coordinates of the stop is set, or they are out of range.
+func validateLocation(stop *httpv1.Stop) error {
+	if (stop.Lat == nil) != (stop.Lon == nil) {
+		return errIncompleteLocation
+	}
+	if stop.Lat != nil && (math.Abs(*stop.Lat) > 90 || math.Abs(*stop.Lon) > 180) {
+		return errInvalidLocation
+	}
+
+	return nil
 }
 
 func toDBStops(stops ...*httpv1.Stop) []*model.Stop {
@@ -36,6 +143,8 @@ func toDBStops(stops ...*httpv1.Stop) []*model.Stop {
 			ID:      stop.ID,
 			City:    stop.City,
 			Address: stop.Address,
+			Lat:     stop.Lat,
+			Lon:     stop.Lon,
 		})
 	}
 
@@ -49,8 +158,25 @@ func toV1Stops(dbStops ...*model.Stop) []*httpv1.Stop {
 			ID:      stop.ID,
 			City:    stop.City,
 			Address: stop.Address,
+			Lat:     stop.Lat,
+			Lon:     stop.Lon,
 		})
 	}
 
 	return stops
 }
+
+const earthRadius = 6371000
+
+// distance returns the great-circle distance between two points in meters.
+func distance(lat1, lon1, lat2, lon2 float64) float64 {
+	const rad = math.Pi / 180
+
+	dLat := (lat2 - lat1) * rad
+	dLon := (lon2 - lon1) * rad
+
+	a := math.Sin(dLat/2)*math.Sin(dLat/2) +
+		math.Cos(lat1*rad)*math.Cos(lat2*rad)*math.Sin(dLon/2)*math.Sin(dLon/2)
+
+	return 2 * earthRadius * math.Asin(math.Sqrt(a))
+}
@@ -0,0 +1,338 @@
+package busroutes
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+
+	httpv1 "github.com/gxravel/bus-routes/internal/api/http/handler/v1"
+	"github.com/gxravel/bus-routes/internal/dataprovider"
+	ierr "github.com/gxravel/bus-routes/internal/errors"
+	"github.com/gxravel/bus-routes/internal/model"
+)
+
+const secondsInDay = 24 * 60 * 60
+
+var weekdayNames = [...]string{"sun", "mon", "tue", "wed", "thu", "fri", "sat"}
+
+func (r *BusRoutes) GetTimetables(ctx context.Context, filter *dataprovider.TimetableFilter) ([]*httpv1.Timetable, error) {
+	dbStopTimes, err := r.timetableStore.GetStopTimes(ctx, filter)
+	if err != nil {
+		return nil, err
+	}
+
+	dbTrips, err := r.timetableStore.GetTrips(ctx, filter)
+	if err != nil {
+		return nil, err
+	}
+
+	return toV1Timetables(dbStopTimes, dbTrips), nil
+}
+
+// timetableRouteMessage is the message of the error setting the timetable of the missing bus or steps.
+const timetableRouteMessage = "the timetable refers to the missing bus or steps of the route"
+
+// SetTimetable validates the timetable and replaces the stop times and trips of the bus route pattern with it.
+// The stop times must refer to the steps of the route pattern.
+func (r *BusRoutes) SetTimetable(ctx context.Context, timetable *httpv1.Timetable) error {
+	pattern, err := toDBPattern(timetable.BusID, timetable.Direction, timetable.Variant)
+	if err != nil {
+		return err
+	}
+
+	dbStopTimes, err := toDBStopTimes(timetable.StopTimes...)
+	if err != nil {
+		return err
+	}
+
+	dbTrips, err := toDBTrips(timetable.Trips...)
+	if err != nil {
+		return err
+	}
+
+	if err := r.checkTimetableSteps(ctx, pattern, dbStopTimes); err != nil {
+		return err
+	}
+
+	// the bus or the steps may be deleted after they are checked.
+	if err := r.timetableStore.Set(ctx, pattern, dbStopTimes, dbTrips); err != nil {
+		return ierr.CheckReferenced(err, timetableRouteMessage)
+	}
+
+	return nil
+}
+
+// checkTimetableSteps returns the error if the bus does not exist,
+// or any of the stop times refers to the step missing in the route pattern.
+func (r *BusRoutes) checkTimetableSteps(ctx context.Context, pattern model.RoutePattern, dbStopTimes []*model.StopTime) error {
+	bus, err := r.busStore.GetByFilter(ctx, dataprovider.NewBusFilter().ByIDs(pattern.BusID))
+	if err != nil {
+		return err
+	}
+	if bus == nil {
+		return ierr.NewReason(ierr.ErrNotFound).WithMessage(fmt.Sprintf("bus %d", pattern.BusID))
+	}
+
+	if len(dbStopTimes) == 0 {
+		return nil
+	}
+
+	routes, err := r.routeStore.GetListByFilter(ctx, dataprovider.NewRouteFilter().ByPattern(pattern))
+	if err != nil {
+		return err
+	}
+
+	var steps = make(map[int]struct{}, len(routes))
+	for _, route := range routes {
+		steps[route.Step] = struct{}{}
+	}
+
+	for _, stopTime := range dbStopTimes {
+		if _, ok := steps[stopTime.Step]; !ok {
+			return ierr.NewReason(ierr.ErrValidationFailed).
+				WithMessage(fmt.Sprintf("step %d is not in the route of the bus %d", stopTime.Step, pattern.BusID))
+		}
+	}
+
+	return nil
+}
+
+// GetDepartures returns the scheduled departures from the stops starting at the given time.
+func (r *BusRoutes) GetDepartures(
+	ctx context.Context,
+	filter *dataprovider.TimetableFilter,
+	at time.Time,
+	paginator *dataprovider.Paginator,
+) ([]*httpv1.Departure, error) {
+	dbStopTimes, err := r.timetableStore.GetStopTimes(ctx, filter.ViewDetailed())
+	if err != nil {
+		return nil, err
+	}
+
+	dbTrips, err := r.timetableStore.GetTrips(ctx, filter)
+	if err != nil {
+		return nil, err
+	}
+
+	departures := scheduleDepartures(dbStopTimes, dbTrips, at)
+
+	offset := int(paginator.Offset())
+	if offset > len(departures) {
+		offset = len(departures)
+	}
+	departures = departures[offset:]
+
+	if limit := int(paginator.Limit()); limit < len(departures) {
+		departures = departures[:limit]
+	}
+
+	return departures, nil
+}
+
+// scheduleDepartures returns the departures of the trips from their stops at or after the given time ordered by time.
+// The departures are scheduled for the day before, the day and the day after in the location of at.
+func scheduleDepartures(dbStopTimes []*model.StopTime, dbTrips []*model.Trip, at time.Time) []*httpv1.Departure {
+	var stopTimes = make(map[model.RoutePattern][]*model.StopTime)
+	for _, stopTime := range dbStopTimes {
+		stopTimes[stopTime.RoutePattern] = append(stopTimes[stopTime.RoutePattern], stopTime)
+	}
+
+	var (
+		departures = make([]*httpv1.Departure, 0)
+		midnight   = time.Date(at.Year(), at.Month(), at.Day(), 0, 0, 0, 0, at.Location())
+	)
+
+	// trips started yesterday may still be running.
+	for days := -1; days <= 1; days++ {
+		day := midnight.AddDate(0, 0, days)
+
+		for _, trip := range dbTrips {
+			if !trip.Days.Has(day.Weekday()) {
+				continue
+			}
+
+			for _, stopTime := range stopTimes[trip.RoutePattern] {
+				departure := day.Add(time.Duration(trip.StartTime+stopTime.Departure) * time.Second)
+				if departure.Before(at) {
+					continue
+				}
+
+				departures = append(departures, &httpv1.Departure{
+					BusID:     trip.BusID,
+					Bus:       stopTime.Number,
+					Direction: trip.Direction,
+					Variant:   trip.Variant,
+					TripID:    trip.ID,
+					Step:      stopTime.Step,
+					Time:      departure,
+				})
+			}
+		}
+	}
+
+	sort.SliceStable(departures, func(i, j int) bool {
+		return departures[i].Time.Before(departures[j].Time)
+	})
+
+	return departures
+}
+
+func toDBStopTimes(stopTimes ...httpv1.StopTime) ([]*model.StopTime, error) {
+	var dbStopTimes = make([]*model.StopTime, 0, len(stopTimes))
+	for _, stopTime := range stopTimes {
+		if err := checkStep(stopTime.Step); err != nil {
+			return nil, err
+		}
+		if stopTime.Arrival < 0 || stopTime.Departure < stopTime.Arrival {
+			return nil, ierr.NewReason(ierr.ErrValidationFailed).
+				WithMessage(fmt.Sprintf("invalid arrival and departure at step %d", stopTime.Step))
+		}
+
+		dbStopTimes = append(dbStopTimes, &model.StopTime{
+			Step:      stopTime.Step,
+			Arrival:   stopTime.Arrival,
+			Departure: stopTime.Departure,
+		})
+	}
+
+	sort.Slice(dbStopTimes, func(i, j int) bool { return dbStopTimes[i].Step < dbStopTimes[j].Step })
+
+	for i := 1; i < len(dbStopTimes); i++ {
+		prev, cur := dbStopTimes[i-1], dbStopTimes[i]
+		if prev.Step == cur.Step {
+			return nil, ierr.NewReason(ierr.ErrValidationFailed).
+				WithMessage(fmt.Sprintf("duplicate step %d", cur.Step))
+		}
+		if cur.Arrival < prev.Departure {
+			return nil, ierr.NewReason(ierr.ErrValidationFailed).
+				WithMessage(fmt.Sprintf("arrival at step %d is earlier than the previous departure", cur.Step))
+		}
+	}
+
+	return dbStopTimes, nil
+}
+
+func toDBTrips(trips ...httpv1.Trip) ([]*model.Trip, error) {
+	var dbTrips = make([]*model.Trip, 0, len(trips))
+	for _, trip := range trips {
+		startTime, err := parseClock(trip.StartTime)
+		if err != nil {
+			return nil, err
+		}
+
+		days, err := parseWeekdays(trip.Days...)
+		if err != nil {
+			return nil, err
+		}
+
+		dbTrips = append(dbTrips, &model.Trip{
+			StartTime: startTime,
+			Days:      days,
+		})
+	}
+
+	return dbTrips, nil
+}
+
+// toV1Timetables groups stop times and trips by route pattern.
+// It expects both to be ordered by bus_id, direction, variant.
+func toV1Timetables(dbStopTimes []*model.StopTime, dbTrips []*model.Trip) []*httpv1.Timetable {
+	var (
+		timetables = make([]*httpv1.Timetable, 0)
+		byPattern  = make(map[model.RoutePattern]*httpv1.Timetable)
+	)
+
+	get := func(pattern model.RoutePattern) *httpv1.Timetable {
+		timetable, ok := byPattern[pattern]
+		if !ok {
+			timetable = &httpv1.Timetable{
+				BusID:     pattern.BusID,
+				Direction: pattern.Direction,
+				Variant:   pattern.Variant,
+				StopTimes: make([]httpv1.StopTime, 0),
+				Trips:     make([]httpv1.Trip, 0),
+			}
+			byPattern[pattern] = timetable
+			timetables = append(timetables, timetable)
+		}
+
+		return timetable
+	}
+
+	for _, stopTime := range dbStopTimes {
+		timetable := get(stopTime.RoutePattern)
+		timetable.StopTimes = append(timetable.StopTimes, httpv1.StopTime{
+			Step:      stopTime.Step,
+			Arrival:   stopTime.Arrival,
+			Departure: stopTime.Departure,
+		})
+	}
+
+	for _, trip := range dbTrips {
+		timetable := get(trip.RoutePattern)
+		timetable.Trips = append(timetable.Trips, httpv1.Trip{
+			ID:        trip.ID,
+			StartTime: formatClock(trip.StartTime),
+			Days:      formatWeekdays(trip.Days),
+		})
+	}
+
+	return timetables
+}
+
+// parseClock parses time of day in format 15:04 or 15:04:05 to the number of seconds since midnight.
+func parseClock(value string) (int, error) {
+	for _, layout := range []string{"15:04:05", "15:04"} {
+		if t, err := time.Parse(layout, value); err == nil {
+			return t.Hour()*60*60 + t.Minute()*60 + t.Second(), nil
+		}
+	}
+
+	return 0, ierr.NewReason(ierr.ErrValidationFailed).
+		WithMessage(fmt.Sprintf("invalid time %q, expected 15:04 or 15:04:05", value))
+}
+
+// formatClock formats the number of seconds since midnight as 15:04:05.
+func formatClock(seconds int) string {
+	seconds %= secondsInDay
+	return fmt.Sprintf("%02d:%02d:%02d", seconds/3600, seconds/60%60, seconds%60)
+}
+
+func parseWeekdays(names ...string) (model.Weekdays, error) {
+	if len(names) == 0 {
+		return 0, ierr.NewReason(ierr.ErrMustProvide).WithMessage("days")
+	}
+
+	var days = make([]time.Weekday, 0, len(names))
+	for _, name := range names {
+		day, ok := parseWeekday(name)
+		if !ok {
+			return 0, ierr.NewReason(ierr.ErrValidationFailed).
+				WithMessage(fmt.Sprintf("invalid day %q", name))
+		}
+
+		days = append(days, day)
+	}
+
+	return model.NewWeekdays(days...), nil
+}
+
+func parseWeekday(name string) (time.Weekday, bool) {
+	for day, dayName := range weekdayNames {
+		if dayName == name {
+			return time.Weekday(day), true
+		}
+	}
+
+	return 0, false
+}
+
+func formatWeekdays(w model.Weekdays) []string {
+	var names = make([]string, 0, 7)
+	for _, day := range w.Days() {
+		names = append(names, weekdayNames[day])
+	}
+
+	return names
+}
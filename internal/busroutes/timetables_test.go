@@ -0,0 +1,329 @@
+package busroutes
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	httpv1 "github.com/gxravel/bus-routes/internal/api/http/handler/v1"
+	"github.com/gxravel/bus-routes/internal/dataprovider"
+	ierr "github.com/gxravel/bus-routes/internal/errors"
+	"github.com/gxravel/bus-routes/internal/model"
+)
+
+func TestScheduleDepartures(t *testing.T) {
+	var (
+		outbound = model.RoutePattern{BusID: 1, Direction: model.DirectionOutbound}
+		inbound  = model.RoutePattern{BusID: 1, Direction: model.DirectionInbound}
+
+		// the trips of both patterns depart from the first step at once and from the second one in 20 minutes.
+		stopTimes = []*model.StopTime{
+			{RoutePattern: outbound, Step: 1, Departure: 0, Number: "A"},
+			{RoutePattern: outbound, Step: 2, Arrival: 1200, Departure: 1200, Number: "A"},
+			{RoutePattern: inbound, Step: 1, Departure: 0, Number: "A"},
+			{RoutePattern: inbound, Step: 2, Arrival: 1200, Departure: 1200, Number: "A"},
+		}
+
+		weekdays = model.NewWeekdays(time.Monday, time.Tuesday, time.Wednesday, time.Thursday, time.Friday)
+	)
+
+	tests := []struct {
+		name  string
+		trips []*model.Trip
+		// at is the time of wednesday.
+		at string
+		// want are the departures described as "trip/step day 15:04".
+		want []string
+	}{
+		{
+			name:  "departures at and after the time",
+			trips: []*model.Trip{{RoutePattern: outbound, ID: 1, StartTime: clock(t, "08:00"), Days: weekdays}},
+			at:    "08:00",
+			want:  []string{"1/1 Wed 08:00", "1/2 Wed 08:20", "1/1 Thu 08:00", "1/2 Thu 08:20"},
+		},
+		{
+			name:  "running trip",
+			trips: []*model.Trip{{RoutePattern: outbound, ID: 1, StartTime: clock(t, "07:50"), Days: weekdays}},
+			at:    "08:00",
+			want:  []string{"1/2 Wed 08:10", "1/1 Thu 07:50", "1/2 Thu 08:10"},
+		},
+		{
+			name:  "trip started yesterday",
+			trips: []*model.Trip{{RoutePattern: outbound, ID: 1, StartTime: clock(t, "23:50"), Days: model.NewWeekdays(time.Tuesday)}},
+			at:    "00:05",
+			want:  []string{"1/2 Wed 00:10"},
+		},
+		{
+			name:  "trip after midnight",
+			trips: []*model.Trip{{RoutePattern: outbound, ID: 1, StartTime: clock(t, "00:10"), Days: model.NewWeekdays(time.Thursday)}},
+			at:    "23:30",
+			want:  []string{"1/1 Thu 00:10", "1/2 Thu 00:30"},
+		},
+		{
+			name:  "out of service days",
+			trips: []*model.Trip{{RoutePattern: outbound, ID: 1, StartTime: clock(t, "12:00"), Days: model.NewWeekdays(time.Saturday, time.Sunday)}},
+			at:    "08:00",
+			want:  []string{},
+		},
+		{
+			name: "ordered by time",
+			trips: []*model.Trip{
+				{RoutePattern: outbound, ID: 1, StartTime: clock(t, "09:00"), Days: model.NewWeekdays(time.Wednesday)},
+				{RoutePattern: inbound, ID: 2, StartTime: clock(t, "08:50"), Days: model.NewWeekdays(time.Wednesday)},
+			},
+			at:   "08:55",
+			want: []string{"1/1 Wed 09:00", "2/2 Wed 09:10", "1/2 Wed 09:20"},
+		},
+		{
+			name:  "trip without stop times",
+			trips: []*model.Trip{{RoutePattern: model.RoutePattern{BusID: 2}, ID: 1, StartTime: clock(t, "09:00"), Days: weekdays}},
+			at:    "08:00",
+			want:  []string{},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			at, err := time.Parse("2006-01-02 15:04", "2026-10-21 "+tt.at)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			departures := scheduleDepartures(stopTimes, tt.trips, at)
+
+			var got = make([]string, 0, len(departures))
+			for _, departure := range departures {
+				got = append(got, describeDeparture(departure))
+			}
+
+			if strings.Join(got, ", ") != strings.Join(tt.want, ", ") {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestToDBStopTimes(t *testing.T) {
+	tests := []struct {
+		name      string
+		stopTimes []httpv1.StopTime
+		wantSteps []int
+		wantErr   bool
+	}{
+		{
+			name: "sorted by step",
+			stopTimes: []httpv1.StopTime{
+				{Step: 2, Arrival: 600, Departure: 660},
+				{Step: 1, Arrival: 0, Departure: 0},
+			},
+			wantSteps: []int{1, 2},
+		},
+		{
+			name:      "departure before arrival",
+			stopTimes: []httpv1.StopTime{{Step: 1, Arrival: 60, Departure: 0}},
+			wantErr:   true,
+		},
+		{
+			name:      "negative arrival",
+			stopTimes: []httpv1.StopTime{{Step: 1, Arrival: -60, Departure: 0}},
+			wantErr:   true,
+		},
+		{
+			name: "duplicate step",
+			stopTimes: []httpv1.StopTime{
+				{Step: 1, Arrival: 0, Departure: 0},
+				{Step: 1, Arrival: 60, Departure: 60},
+			},
+			wantErr: true,
+		},
+		{
+			name: "arrival before previous departure",
+			stopTimes: []httpv1.StopTime{
+				{Step: 1, Arrival: 0, Departure: 120},
+				{Step: 2, Arrival: 60, Departure: 180},
+			},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dbStopTimes, err := toDBStopTimes(tt.stopTimes...)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("toDBStopTimes() error = %v, want error %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+
+			if len(dbStopTimes) != len(tt.wantSteps) {
+				t.Fatalf("got %d stop times, want %d", len(dbStopTimes), len(tt.wantSteps))
+			}
+			for k, stopTime := range dbStopTimes {
+				if stopTime.Step != tt.wantSteps[k] {
+					t.Errorf("stop time %d has step %d, want %d", k, stopTime.Step, tt.wantSteps[k])
+				}
+			}
+		})
+	}
+}
+
+func TestSetTimetable(t *testing.T) {
+	var stopTimes = []httpv1.StopTime{{Step: 1}, {Step: 2, Arrival: 600, Departure: 600}}
+
+	tests := []struct {
+		name      string
+		timetable *httpv1.Timetable
+		// setErr is the error of the store setting the timetable.
+		setErr  error
+		wantErr ierr.TypedError
+		wantSet bool
+	}{
+		{
+			name:      "steps of the route",
+			timetable: &httpv1.Timetable{BusID: 1, StopTimes: stopTimes},
+			wantSet:   true,
+		},
+		{
+			name:      "missing bus",
+			timetable: &httpv1.Timetable{BusID: 2, StopTimes: stopTimes},
+			wantErr:   ierr.ErrNotFound,
+		},
+		{
+			name:      "missing step",
+			timetable: &httpv1.Timetable{BusID: 1, StopTimes: append(stopTimes, httpv1.StopTime{Step: 3, Arrival: 900, Departure: 900})},
+			wantErr:   ierr.ErrValidationFailed,
+		},
+		{
+			name:      "steps of the other pattern",
+			timetable: &httpv1.Timetable{BusID: 1, Direction: model.DirectionInbound, StopTimes: stopTimes},
+			wantErr:   ierr.ErrValidationFailed,
+		},
+		{
+			name:      "steps deleted after the check",
+			timetable: &httpv1.Timetable{BusID: 1, StopTimes: stopTimes},
+			setErr:    errors.New("Error 1452: Cannot add or update a child row: a foreign key constraint fails"),
+			wantErr:   ierr.ErrValidationFailed,
+			wantSet:   true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var (
+				outbound = model.RoutePattern{BusID: 1, Direction: model.DirectionOutbound}
+				store    = &fakeTimetableStore{err: tt.setErr}
+			)
+
+			r := &BusRoutes{
+				busStore: fakeBusStore{buses: map[int64]*model.Bus{1: {ID: 1, City: "Moscow"}}},
+				routeStore: fakeRouteStore{routes: []*model.Route{
+					{RoutePattern: outbound, StopID: 1, Step: 1},
+					{RoutePattern: outbound, StopID: 2, Step: 2},
+				}},
+				timetableStore: store,
+			}
+
+			err := r.SetTimetable(context.Background(), tt.timetable)
+			if tt.wantErr == nil {
+				if err != nil {
+					t.Fatalf("SetTimetable() error = %v", err)
+				}
+			} else if reason, ok := err.(*ierr.Reason); !ok || reason.Err != tt.wantErr {
+				t.Fatalf("SetTimetable() error = %v, want %v", err, tt.wantErr)
+			}
+
+			if store.set != tt.wantSet {
+				t.Errorf("timetable is set %t, want %t", store.set, tt.wantSet)
+			}
+		})
+	}
+}
+
+func TestParseClock(t *testing.T) {
+	tests := []struct {
+		value   string
+		want    int
+		wantErr bool
+	}{
+		{"00:00", 0, false},
+		{"08:30", 8*3600 + 30*60, false},
+		{"23:59:59", secondsInDay - 1, false},
+		{"24:00", 0, true},
+		{"8.30", 0, true},
+		{"", 0, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.value, func(t *testing.T) {
+			got, err := parseClock(tt.value)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parseClock(%q) error = %v, want error %v", tt.value, err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("parseClock(%q) = %d, want %d", tt.value, got, tt.want)
+			}
+			if !tt.wantErr && formatClock(got) != tt.value && formatClock(got) != tt.value+":00" {
+				t.Errorf("formatClock(%d) = %q, want %q", got, formatClock(got), tt.value)
+			}
+		})
+	}
+}
+
+func clock(t *testing.T, value string) int {
+	t.Helper()
+
+	seconds, err := parseClock(value)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return seconds
+}
+
+func describeDeparture(departure *httpv1.Departure) string {
+	return fmt.Sprintf("%d/%d %s", departure.TripID, departure.Step, departure.Time.Format("Mon 15:04"))
+}
+
+// fakeBusStore finds the buses by the first of the ids.
+type fakeBusStore struct {
+	dataprovider.BusStore
+	buses map[int64]*model.Bus
+}
+
+func (s fakeBusStore) GetByFilter(_ context.Context, filter *dataprovider.BusFilter) (*model.Bus, error) {
+	return s.buses[filter.IDs[0]], nil
+}
+
+// fakeRouteStore finds the steps of the route by the pattern.
+type fakeRouteStore struct {
+	dataprovider.RouteStore
+	routes []*model.Route
+}
+
+func (s fakeRouteStore) GetListByFilter(_ context.Context, filter *dataprovider.RouteFilter) ([]*model.Route, error) {
+	var routes = make([]*model.Route, 0, len(s.routes))
+	for _, route := range s.routes {
+		pattern := model.RoutePattern{BusID: filter.BusIDs[0], Direction: filter.Directions[0], Variant: filter.Variants[0]}
+		if route.RoutePattern == pattern {
+			routes = append(routes, route)
+		}
+	}
+
+	return routes, nil
+}
+
+// fakeTimetableStore records the timetable is set and fails with err.
+type fakeTimetableStore struct {
+	dataprovider.TimetableStore
+	set bool
+	err error
+}
+
+func (s *fakeTimetableStore) Set(context.Context, model.RoutePattern, []*model.StopTime, []*model.Trip) error {
+	s.set = true
+	return s.err
+}
@@ -0,0 +1,313 @@
+package busroutes
+
+import (
+	"context"
+	"time"
+
+	httpv1 "github.com/gxravel/bus-routes/internal/api/http/handler/v1"
+	"github.com/gxravel/bus-routes/internal/dataprovider"
+	ierr "github.com/gxravel/bus-routes/internal/errors"
+	log "github.com/gxravel/bus-routes/internal/logger"
+	"github.com/gxravel/bus-routes/internal/model"
+	"github.com/gxravel/bus-routes/internal/totp"
+)
+
+// purposeLoginTwoFactor is the purpose of the one-time token of the login waiting for the second factor.
+const purposeLoginTwoFactor = "login_2fa"
+
+// withUserTwoFactor sets whether the users have 2FA enabled.
+func (r *BusRoutes) withUserTwoFactor(ctx context.Context, users ...*model.User) error {
+	if len(users) == 0 {
+		return nil
+	}
+
+	var (
+		ids    = make([]int64, 0, len(users))
+		byUser = make(map[int64]*model.User, len(users))
+	)
+	for _, user := range users {
+		ids = append(ids, user.ID)
+		byUser[user.ID] = user
+	}
+
+	dbTOTPs, err := r.totpStore.GetListByFilter(ctx, dataprovider.NewTOTPFilter().ByUserIDs(ids...).ByEnabled(true))
+	if err != nil {
+		return err
+	}
+
+	for _, dbTOTP := range dbTOTPs {
+		byUser[dbTOTP.UserID].TwoFactor = true
+	}
+
+	return nil
+}
+
+// GetTwoFactor returns whether the user has 2FA enabled and how many recovery codes are left.
+func (r *BusRoutes) GetTwoFactor(ctx context.Context, userID int64) (*httpv1.TwoFactor, error) {
+	dbTOTP, err := r.totpStore.GetByFilter(ctx, dataprovider.NewTOTPFilter().ByUserIDs(userID).ByEnabled(true))
+	if err != nil {
+		return nil, err
+	}
+	if dbTOTP == nil {
+		return &httpv1.TwoFactor{}, nil
+	}
+
+	count, err := r.totpStore.CountRecoveryCodes(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	return &httpv1.TwoFactor{
+		Enabled:           true,
+		RecoveryCodesLeft: count,
+	}, nil
+}
+
+// EnrollTOTP generates the new TOTP secret of the user, 2FA is enabled once the code of the secret is confirmed.
+func (r *BusRoutes) EnrollTOTP(ctx context.Context, userID int64) (*httpv1.TOTPEnrollment, error) {
+	user, err := r.GetUser(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if user.TwoFactor {
+		return nil, ierr.NewReason(ierr.ErrConflict).WithMessage("two-factor authentication is enabled already")
+	}
+
+	secret, err := totp.NewSecret()
+	if err != nil {
+		return nil, err
+	}
+
+	if err := r.totpStore.Save(ctx, &model.TOTP{UserID: userID, Secret: secret}); err != nil {
+		return nil, err
+	}
+
+	return &httpv1.TOTPEnrollment{
+		Secret: secret,
+		URI:    totp.URI(r.config.TOTP.Issuer, user.Email, secret),
+	}, nil
+}
+
+// ConfirmTOTP enables 2FA of the user by the code of the enrolled secret and returns the recovery codes.
+// The tokens of the user issued without the second factor are revoked, the new one is returned instead.
+// The wrong codes count as the failed login attempts of the user from the client IP.
+func (r *BusRoutes) ConfirmTOTP(ctx context.Context, userID int64, code, ip string) (*httpv1.TwoFactorConfirmation, error) {
+	user, err := r.GetUser(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	dbTOTP, err := r.totpStore.GetByFilter(ctx, dataprovider.NewTOTPFilter().ByUserIDs(userID))
+	if err != nil {
+		return nil, err
+	}
+	if dbTOTP == nil {
+		return nil, ierr.NewReason(ierr.ErrNotFound).WithMessage("two-factor authentication is not enrolled")
+	}
+	if dbTOTP.Enabled {
+		return nil, ierr.NewReason(ierr.ErrConflict).WithMessage("two-factor authentication is enabled already")
+	}
+
+	attempt, err := r.CheckLoginAttempts(ctx, user.Email, ip)
+	if err != nil {
+		return nil, err
+	}
+
+	step, ok := totp.Validate(dbTOTP.Secret, code, time.Now(), r.config.TOTP.Skew)
+	if !ok {
+		return nil, r.failTwoFactorCode(ctx, attempt)
+	}
+
+	if err := r.ResetLoginAttempts(ctx, attempt); err != nil {
+		log.FromContext(ctx).WithErr(err).Error("reset login attempts")
+	}
+
+	codes, err := totp.NewRecoveryCodes(r.config.TOTP.RecoveryCodes)
+	if err != nil {
+		return nil, err
+	}
+
+	var hashedCodes = make([][]byte, 0, len(codes))
+	for _, code := range codes {
+		hashedCodes = append(hashedCodes, totp.HashRecoveryCode(code))
+	}
+
+	f := func(tx *dataprovider.Tx) error {
+		totpStore := r.totpStore.WithTx(tx)
+
+		if err := totpStore.Enable(ctx, userID, step); err != nil {
+			return err
+		}
+
+		return totpStore.SetRecoveryCodes(ctx, userID, hashedCodes...)
+	}
+
+	if err := dataprovider.BeginAutoCommitedTx(ctx, r.txer, f); err != nil {
+		return nil, err
+	}
+
+	if err := r.tokenManager.RevokeAll(ctx, userID); err != nil {
+		return nil, err
+	}
+
+	user.TwoFactor = true
+
+	token, err := r.NewJWT(ctx, user)
+	if err != nil {
+		return nil, err
+	}
+
+	return &httpv1.TwoFactorConfirmation{
+		RecoveryCodes: codes,
+		Token:         token,
+	}, nil
+}
+
+// DisableTOTP disables 2FA of the user by TOTP or recovery code, unless 2FA is enforced for its type.
+// The wrong codes count as the failed login attempts of the user from the client IP.
+// The tokens of the user issued with the second factor are revoked, the new one is returned instead.
+func (r *BusRoutes) DisableTOTP(ctx context.Context, userID int64, code, ip string) (*httpv1.Token, error) {
+	user, err := r.GetUser(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if !user.TwoFactor {
+		return nil, ierr.NewReason(ierr.ErrNotFound).WithMessage("two-factor authentication is not enabled")
+	}
+	if r.config.TOTP.EnforceAdmin && user.Type == model.UserAdmin {
+		return nil, ierr.NewReason(ierr.ErrPermissionDenied).WithMessage("two-factor authentication is required for admins")
+	}
+
+	attempt, err := r.CheckLoginAttempts(ctx, user.Email, ip)
+	if err != nil {
+		return nil, err
+	}
+
+	ok, err := r.checkTwoFactorCode(ctx, userID, code)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, r.failTwoFactorCode(ctx, attempt)
+	}
+
+	if err := r.ResetLoginAttempts(ctx, attempt); err != nil {
+		log.FromContext(ctx).WithErr(err).Error("reset login attempts")
+	}
+
+	if err := r.totpStore.Delete(ctx, dataprovider.NewTOTPFilter().ByUserIDs(userID)); err != nil {
+		return nil, err
+	}
+
+	if err := r.tokenManager.RevokeAll(ctx, userID); err != nil {
+		return nil, err
+	}
+
+	user.TwoFactor = false
+
+	return r.NewJWT(ctx, user)
+}
+
+// failTwoFactorCode counts the wrong code as the failed login attempt, and returns the error of it.
+func (r *BusRoutes) failTwoFactorCode(ctx context.Context, attempt *LoginAttempt) error {
+	if err := r.FailLogin(ctx, attempt); err != nil {
+		log.FromContext(ctx).WithErr(err).Error("count failed login")
+	}
+
+	return ierr.NewReason(ierr.ErrValidationFailed).WithMessage("invalid code")
+}
+
+// ResetTOTP disables 2FA of the user, who has lost both the authenticator and the recovery codes.
+// The tokens of the user are revoked, so it has to log in with the password only and enroll 2FA again.
+func (r *BusRoutes) ResetTOTP(ctx context.Context, userID int64) error {
+	user, err := r.GetUser(ctx, userID)
+	if err != nil {
+		return err
+	}
+	if !user.TwoFactor {
+		return ierr.NewReason(ierr.ErrNotFound).WithMessage("two-factor authentication is not enabled")
+	}
+
+	if err := r.totpStore.Delete(ctx, dataprovider.NewTOTPFilter().ByUserIDs(userID)); err != nil {
+		return err
+	}
+
+	return r.tokenManager.RevokeAll(ctx, userID)
+}
+
+// NewTwoFactorChallenge returns the challenge of the user, whose password is checked already, to complete the login with the code.
+func (r *BusRoutes) NewTwoFactorChallenge(ctx context.Context, userID int64) (*httpv1.TwoFactorChallenge, error) {
+	expiry := r.config.TOTP.ChallengeExpiry
+
+	token, err := r.storage.NewOneTimeToken(ctx, purposeLoginTwoFactor, userID, expiry)
+	if err != nil {
+		return nil, err
+	}
+
+	return &httpv1.TwoFactorChallenge{
+		Token:  token,
+		Expiry: time.Now().Add(expiry).Unix(),
+	}, nil
+}
+
+// LoginTwoFactor completes the login by the challenge and TOTP or recovery code.
+// The wrong codes count as the failed login attempts, the challenge is spent only by the right one.
+func (r *BusRoutes) LoginTwoFactor(ctx context.Context, login *httpv1.TwoFactorLogin, ip string) (*httpv1.Token, error) {
+	userID, err := r.storage.OneTimeTokenUser(ctx, purposeLoginTwoFactor, login.Token)
+	if err != nil {
+		return nil, err
+	}
+	if userID == 0 {
+		return nil, ierr.NewReason(ierr.ErrInvalidToken).WithMessage("the token is spent or expired")
+	}
+
+	user, err := r.GetUser(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	attempt, err := r.CheckLoginAttempts(ctx, user.Email, ip)
+	if err != nil {
+		return nil, err
+	}
+
+	ok, err := r.checkTwoFactorCode(ctx, userID, login.Code)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		if err := r.FailLogin(ctx, attempt); err != nil {
+			log.FromContext(ctx).WithErr(err).Error("count failed login")
+		}
+
+		return nil, ierr.NewReason(ierr.ErrWrongCredentials).WithMessage("invalid code")
+	}
+
+	if _, err := r.spendToken(ctx, purposeLoginTwoFactor, login.Token); err != nil {
+		return nil, err
+	}
+
+	if err := r.ResetLoginAttempts(ctx, attempt); err != nil {
+		log.FromContext(ctx).WithErr(err).Error("reset login attempts")
+	}
+
+	return r.NewJWT(ctx, user)
+}
+
+// checkTwoFactorCode returns true if the code is the unused TOTP code or recovery code of the user with 2FA enabled.
+// The accepted code can not be used again.
+func (r *BusRoutes) checkTwoFactorCode(ctx context.Context, userID int64, code string) (bool, error) {
+	dbTOTP, err := r.totpStore.GetByFilter(ctx, dataprovider.NewTOTPFilter().ByUserIDs(userID).ByEnabled(true))
+	if err != nil {
+		return false, err
+	}
+	if dbTOTP == nil || code == "" {
+		return false, nil
+	}
+
+	if step, ok := totp.Validate(dbTOTP.Secret, code, time.Now(), r.config.TOTP.Skew); ok {
+		return r.totpStore.UseStep(ctx, userID, step)
+	}
+
+	return r.totpStore.UseRecoveryCode(ctx, userID, totp.HashRecoveryCode(code))
+}
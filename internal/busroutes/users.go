@@ -2,6 +2,7 @@ package busroutes
 
 import (
 	"context"
+	"fmt"
 
 	httpv1 "github.com/gxravel/bus-routes/internal/api/http/handler/v1"
 	"github.com/gxravel/bus-routes/internal/dataprovider"
@@ -34,9 +35,45 @@ func (r *BusRoutes) GetUsers(ctx context.Context, filter *dataprovider.UserFilte
 		return nil, err
 	}
 
+	if err := r.withUserCities(ctx, dbUsers...); err != nil {
+		return nil, err
+	}
+
+	if err := r.withUserTwoFactor(ctx, dbUsers...); err != nil {
+		return nil, err
+	}
+
 	return toV1Users(dbUsers...), nil
 }
 
+// withUserCities sets the city scopes of the users.
+func (r *BusRoutes) withUserCities(ctx context.Context, users ...*model.User) error {
+	if len(users) == 0 {
+		return nil
+	}
+
+	var (
+		ids    = make([]int64, 0, len(users))
+		byUser = make(map[int64]*model.User, len(users))
+	)
+	for _, user := range users {
+		ids = append(ids, user.ID)
+		byUser[user.ID] = user
+	}
+
+	userCities, err := r.userStore.GetCities(ctx, ids...)
+	if err != nil {
+		return err
+	}
+
+	for _, userCity := range userCities {
+		user := byUser[userCity.UserID]
+		user.Cities = append(user.Cities, userCity.City)
+	}
+
+	return nil
+}
+
 func (r *BusRoutes) CheckPasswordHash(ctx context.Context, password string, filter *dataprovider.UserFilter) error {
 	dbUser, err := r.userStore.GetByFilter(ctx, filter)
 	if err != nil {
@@ -71,16 +108,168 @@ func (r *BusRoutes) AddUsers(ctx context.Context, users ...*httpv1.User) (int64,
 	return id, nil
 }
 
-func (r *BusRoutes) UpdateUser(ctx context.Context, user *httpv1.User) error {
-	return r.userStore.Update(ctx, toDBUsers(ctx, user)[0])
+// GetUser returns the user by id.
+func (r *BusRoutes) GetUser(ctx context.Context, id int64) (*httpv1.User, error) {
+	dbUser, err := r.userStore.GetByFilter(ctx, dataprovider.NewUserFilter().ByIDs(int(id)))
+	if err != nil {
+		return nil, err
+	}
+	if dbUser == nil {
+		return nil, ierr.NewReason(ierr.ErrNotFound).WithMessage(fmt.Sprintf("user %d", id))
+	}
+
+	if err := r.withUserCities(ctx, dbUser); err != nil {
+		return nil, err
+	}
+
+	if err := r.withUserTwoFactor(ctx, dbUser); err != nil {
+		return nil, err
+	}
+
+	return toV1Users(dbUser)[0], nil
+}
+
+// ChangeUserType changes the type of the user, the tokens of the user with the former type are revoked.
+func (r *BusRoutes) ChangeUserType(ctx context.Context, id int64, userType model.UserType) (*httpv1.User, error) {
+	if !model.UserTypes(model.V1BusroutesUserTypes).Exists(userType) {
+		return nil, ierr.NewReason(ierr.ErrValidationFailed).
+			WithMessage(fmt.Sprintf("unknown user type %q", userType))
+	}
+
+	user, err := r.GetUser(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if user.Type == userType {
+		return user, nil
+	}
+
+	user.Type = userType
+
+	err = r.userStore.Update(ctx, &model.User{
+		ID:    user.ID,
+		Email: user.Email,
+		Type:  user.Type,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if err := r.tokenManager.RevokeAll(ctx, id); err != nil {
+		return nil, err
+	}
+
+	return user, nil
+}
+
+// SetUserCities replaces the city scopes of the user, the tokens of the user with the former scopes are revoked.
+func (r *BusRoutes) SetUserCities(ctx context.Context, id int64, cities []string) (*httpv1.User, error) {
+	if _, err := r.GetUser(ctx, id); err != nil {
+		return nil, err
+	}
+
+	var citiesIDs = make([]int, 0, len(cities))
+	if len(cities) > 0 {
+		dbCities, err := r.cityStore.GetListByFilter(ctx, dataprovider.NewCityFilter().ByNames(cities...))
+		if err != nil {
+			return nil, err
+		}
+
+		var ids = make(map[string]int, len(dbCities))
+		for _, city := range dbCities {
+			ids[city.Name] = city.ID
+		}
+
+		var added = make(map[int]bool, len(cities))
+		for _, city := range cities {
+			id, ok := ids[city]
+			if !ok {
+				return nil, ierr.NewReason(ierr.ErrNotFound).WithMessage(fmt.Sprintf("city %s", city))
+			}
+			if !added[id] {
+				citiesIDs = append(citiesIDs, id)
+				added[id] = true
+			}
+		}
+	}
+
+	if err := r.userStore.SetCities(ctx, id, citiesIDs...); err != nil {
+		return nil, err
+	}
+
+	if err := r.tokenManager.RevokeAll(ctx, id); err != nil {
+		return nil, err
+	}
+
+	return r.GetUser(ctx, id)
+}
+
+// ChangeUserPassword checks the old password and sets the new one.
+// The tokens of the user are revoked, the new one is returned instead.
+func (r *BusRoutes) ChangeUserPassword(ctx context.Context, id int64, change *httpv1.PasswordChange) (*httpv1.Token, error) {
+	filter := dataprovider.NewUserFilter().
+		SelectPassword().
+		ByIDs(int(id))
+
+	if err := r.CheckPasswordHash(ctx, change.OldPassword, filter); err != nil {
+		return nil, err
+	}
+
+	hashedPassword, err := hashPassword(change.NewPassword)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := r.UpdateUserPassword(ctx, hashedPassword, dataprovider.NewUserFilter().ByIDs(int(id))); err != nil {
+		return nil, err
+	}
+
+	// the reset link sent before must not set the password back.
+	if err := r.storage.RevokeOneTimeTokens(ctx, purposeResetPassword, id); err != nil {
+		return nil, err
+	}
+
+	if err := r.tokenManager.RevokeAll(ctx, id); err != nil {
+		return nil, err
+	}
+
+	user, err := r.GetUser(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	return r.NewJWT(ctx, user)
 }
 
 func (r *BusRoutes) UpdateUserPassword(ctx context.Context, hashedPassword []byte, filter *dataprovider.UserFilter) error {
 	return r.userStore.UpdatePassword(ctx, hashedPassword, filter)
 }
 
-func (r *BusRoutes) DeleteUser(ctx context.Context, filter *dataprovider.UserFilter) error {
-	return r.userStore.Delete(ctx, filter)
+// DeleteUser deletes the user and revokes its tokens, its API keys are deleted by cascade.
+func (r *BusRoutes) DeleteUser(ctx context.Context, id int64) error {
+	if _, err := r.GetUser(ctx, id); err != nil {
+		return err
+	}
+
+	keys, err := r.apiKeyStore.GetListByFilter(ctx, dataprovider.NewAPIKeyFilter().ByUserIDs(id))
+	if err != nil {
+		return err
+	}
+
+	if err := r.userStore.Delete(ctx, dataprovider.NewUserFilter().ByIDs(int(id))); err != nil {
+		return err
+	}
+
+	var keysIDs = make([]int64, 0, len(keys))
+	for _, key := range keys {
+		keysIDs = append(keysIDs, key.ID)
+	}
+
+	if err := r.apiKeyTracker.Forget(ctx, keysIDs...); err != nil {
+		return err
+	}
+
+	return r.tokenManager.RevokeAll(ctx, id)
 }
 
 func toDBUsers(ctx context.Context, users ...*httpv1.User) []*model.User {
@@ -109,9 +298,12 @@ func toV1Users(dbUsers ...*model.User) []*httpv1.User {
 	var users = make([]*httpv1.User, 0, len(dbUsers))
 	for _, user := range dbUsers {
 		users = append(users, &httpv1.User{
-			ID:    user.ID,
-			Email: user.Email,
-			Type:  user.Type,
+			ID:        user.ID,
+			Email:     user.Email,
+			Type:      user.Type,
+			Cities:    user.Cities,
+			Verified:  user.Verified,
+			TwoFactor: user.TwoFactor,
 		})
 	}
 
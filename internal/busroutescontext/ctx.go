@@ -3,24 +3,83 @@ package busroutescontext
 import (
 	"context"
 
+	httpv1 "github.com/gxravel/bus-routes/internal/api/http/handler/v1"
 	"github.com/gxravel/bus-routes/internal/model"
 )
 
 type ctxKey string
 
 const (
-	UserTypesKey ctxKey = "user_types"
-	UserKey      ctxKey = "user"
-	TokenKey     ctxKey = "token"
+	PermissionsKey ctxKey = "permissions"
+	UserKey        ctxKey = "user"
+	TokenKey       ctxKey = "token"
+	ClientKey      ctxKey = "client"
+	TrustedKey     ctxKey = "trusted"
 )
 
-// GetUserTypes returns registered user types.
-func GetUserTypes(ctx context.Context) model.UserTypes {
+// Client describes the client of the request.
+type Client struct {
+	UserAgent string
+	IP        string
+}
+
+// GetPermissions returns registered permissions.
+func GetPermissions(ctx context.Context) []model.Permission {
+	if ctx == nil {
+		return nil
+	}
+
+	p, _ := ctx.Value(PermissionsKey).([]model.Permission)
+
+	return p
+}
+
+// GetUser returns the user of the authorized request.
+func GetUser(ctx context.Context) *httpv1.User {
 	if ctx == nil {
 		return nil
 	}
 
-	t, _ := ctx.Value(UserTypesKey).(model.UserTypes)
+	u, _ := ctx.Value(UserKey).(*httpv1.User)
+
+	return u
+}
+
+// GetToken returns the token of the authorized request.
+func GetToken(ctx context.Context) string {
+	if ctx == nil {
+		return ""
+	}
+
+	t, _ := ctx.Value(TokenKey).(string)
+
+	return t
+}
+
+// GetClient returns the client of the request.
+func GetClient(ctx context.Context) *Client {
+	if ctx == nil {
+		return nil
+	}
+
+	c, _ := ctx.Value(ClientKey).(*Client)
+
+	return c
+}
+
+// WithTrusted marks the request as made by the trusted internal caller, e.g. the command line tool.
+// The trusted request is not limited to the scope of any user.
+func WithTrusted(ctx context.Context) context.Context {
+	return context.WithValue(ctx, TrustedKey, true)
+}
+
+// IsTrusted returns true if the request is made by the trusted internal caller.
+func IsTrusted(ctx context.Context) bool {
+	if ctx == nil {
+		return false
+	}
+
+	t, _ := ctx.Value(TrustedKey).(bool)
 
 	return t
 }
@@ -0,0 +1,50 @@
+package database
+
+import (
+	"database/sql"
+
+	"github.com/lopezator/migrator"
+	"github.com/pkg/errors"
+)
+
+//nolint // to bypass gosec sql concat warning
+func migrationTimetable(schema string) *migrator.Migration {
+	return &migrator.Migration{
+		Name: "202610181210_timetable",
+		Func: func(tx *sql.Tx) error {
+			qs := []string{
+				`CREATE TABLE IF NOT EXISTS trip (
+					id BIGINT AUTO_INCREMENT PRIMARY KEY,
+					bus_id BIGINT NOT NULL,
+					-- start_time is the number of seconds since midnight.
+					start_time INT NOT NULL,
+					-- days is the bitmask of the service days, sunday is the lowest bit.
+					days TINYINT UNSIGNED NOT NULL,
+					INDEX(bus_id, start_time),
+					FOREIGN KEY(bus_id) REFERENCES bus(id) ON UPDATE CASCADE ON DELETE CASCADE
+				)`,
+				`CREATE TABLE IF NOT EXISTS stop_time (
+					bus_id BIGINT NOT NULL,
+					step TINYINT NOT NULL,
+					-- arrival and departure are the numbers of seconds since the trip start.
+					arrival INT NOT NULL,
+					departure INT NOT NULL,
+					PRIMARY KEY(bus_id, step),
+					FOREIGN KEY(bus_id, step) REFERENCES route(bus_id, step) ON UPDATE CASCADE ON DELETE CASCADE
+				)`,
+			}
+
+			for k, query := range qs {
+				if _, err := tx.Exec(query); err != nil {
+					return errors.Wrapf(err, "applying 202610181210_timetable migration #%d", k)
+				}
+			}
+			return nil
+		},
+	}
+}
+
+/* ROLLBACK SQL
+DROP TABLE IF EXISTS stop_time;
+DROP TABLE IF EXISTS trip;
+*/
@@ -0,0 +1,35 @@
+package database
+
+import (
+	"database/sql"
+
+	"github.com/lopezator/migrator"
+	"github.com/pkg/errors"
+)
+
+//nolint // to bypass gosec sql concat warning
+func migrationStopLocation(schema string) *migrator.Migration {
+	return &migrator.Migration{
+		Name: "202610181430_stop_location",
+		Func: func(tx *sql.Tx) error {
+			qs := []string{
+				`ALTER TABLE stop
+					-- lat and lon are WGS 84 coordinates in degrees.
+					ADD COLUMN lat DOUBLE NULL,
+					ADD COLUMN lon DOUBLE NULL,
+					ADD INDEX stop_lat_lon (lat, lon)`,
+			}
+
+			for k, query := range qs {
+				if _, err := tx.Exec(query); err != nil {
+					return errors.Wrapf(err, "applying 202610181430_stop_location migration #%d", k)
+				}
+			}
+			return nil
+		},
+	}
+}
+
+/* ROLLBACK SQL
+ALTER TABLE stop DROP INDEX stop_lat_lon, DROP COLUMN lat, DROP COLUMN lon;
+*/
@@ -0,0 +1,55 @@
+package database
+
+import (
+	"database/sql"
+
+	"github.com/lopezator/migrator"
+	"github.com/pkg/errors"
+)
+
+//nolint // to bypass gosec sql concat warning
+func migrationRoutePattern(schema string) *migrator.Migration {
+	return &migrator.Migration{
+		Name: "202610181530_route_pattern",
+		Func: func(tx *sql.Tx) error {
+			qs := []string{
+				`ALTER TABLE stop_time DROP FOREIGN KEY stop_time_ibfk_1`,
+				// the existing routes become the main variant of the outbound direction.
+				`ALTER TABLE route
+					ADD direction ENUM('outbound', 'inbound') NOT NULL DEFAULT 'outbound' AFTER bus_id,
+					-- variant is the name of a short-turn or another variant of the direction, empty for the main one.
+					ADD variant VARCHAR(64) NOT NULL DEFAULT '' AFTER direction,
+					DROP PRIMARY KEY,
+					ADD PRIMARY KEY(bus_id, direction, variant, step)`,
+				`ALTER TABLE stop_time
+					ADD direction ENUM('outbound', 'inbound') NOT NULL DEFAULT 'outbound' AFTER bus_id,
+					ADD variant VARCHAR(64) NOT NULL DEFAULT '' AFTER direction,
+					DROP PRIMARY KEY,
+					ADD PRIMARY KEY(bus_id, direction, variant, step),
+					ADD FOREIGN KEY(bus_id, direction, variant, step)
+						REFERENCES route(bus_id, direction, variant, step) ON UPDATE CASCADE ON DELETE CASCADE`,
+				`ALTER TABLE trip
+					ADD direction ENUM('outbound', 'inbound') NOT NULL DEFAULT 'outbound' AFTER bus_id,
+					ADD variant VARCHAR(64) NOT NULL DEFAULT '' AFTER direction,
+					ADD INDEX(bus_id, direction, variant, start_time)`,
+			}
+
+			for k, query := range qs {
+				if _, err := tx.Exec(query); err != nil {
+					return errors.Wrapf(err, "applying 202610181530_route_pattern migration #%d", k)
+				}
+			}
+			return nil
+		},
+	}
+}
+
+/* ROLLBACK SQL
+DELETE FROM trip WHERE direction <> 'outbound' OR variant <> '';
+ALTER TABLE trip DROP INDEX bus_id_2, DROP direction, DROP variant;
+DELETE FROM route WHERE direction <> 'outbound' OR variant <> '';
+ALTER TABLE stop_time DROP FOREIGN KEY stop_time_ibfk_1;
+ALTER TABLE stop_time DROP PRIMARY KEY, DROP direction, DROP variant, ADD PRIMARY KEY(bus_id, step);
+ALTER TABLE route DROP PRIMARY KEY, DROP direction, DROP variant, ADD PRIMARY KEY(bus_id, step);
+ALTER TABLE stop_time ADD FOREIGN KEY(bus_id, step) REFERENCES route(bus_id, step) ON UPDATE CASCADE ON DELETE CASCADE;
+*/
@@ -0,0 +1,43 @@
+package database
+
+import (
+	"database/sql"
+
+	"github.com/lopezator/migrator"
+	"github.com/pkg/errors"
+)
+
+//nolint // to bypass gosec sql concat warning
+func migrationRouteStep(schema string) *migrator.Migration {
+	return &migrator.Migration{
+		Name: "202610181600_route_step",
+		Func: func(tx *sql.Tx) error {
+			qs := []string{
+				// the columns of the foreign key can not be changed while it exists.
+				`ALTER TABLE stop_time DROP FOREIGN KEY stop_time_ibfk_1`,
+				`ALTER TABLE route MODIFY step SMALLINT NOT NULL`,
+				`ALTER TABLE stop_time MODIFY step SMALLINT NOT NULL`,
+				`ALTER TABLE stop_time
+					ADD CONSTRAINT stop_time_route_fk FOREIGN KEY(bus_id, direction, variant, step)
+						REFERENCES route(bus_id, direction, variant, step) ON UPDATE CASCADE ON DELETE CASCADE`,
+			}
+
+			for k, query := range qs {
+				if _, err := tx.Exec(query); err != nil {
+					return errors.Wrapf(err, "applying 202610181600_route_step migration #%d", k)
+				}
+			}
+			return nil
+		},
+	}
+}
+
+/* ROLLBACK SQL
+DELETE FROM route WHERE step > 127;
+ALTER TABLE stop_time DROP FOREIGN KEY stop_time_route_fk;
+ALTER TABLE route MODIFY step TINYINT NOT NULL;
+ALTER TABLE stop_time MODIFY step TINYINT NOT NULL;
+ALTER TABLE stop_time
+	ADD CONSTRAINT stop_time_ibfk_1 FOREIGN KEY(bus_id, direction, variant, step)
+		REFERENCES route(bus_id, direction, variant, step) ON UPDATE CASCADE ON DELETE CASCADE;
+*/
@@ -0,0 +1,39 @@
+package database
+
+import (
+	"database/sql"
+
+	"github.com/lopezator/migrator"
+	"github.com/pkg/errors"
+)
+
+//nolint // to bypass gosec sql concat warning
+func migrationOutbox(schema string) *migrator.Migration {
+	return &migrator.Migration{
+		Name: "202610181700_outbox",
+		Func: func(tx *sql.Tx) error {
+			qs := []string{
+				// outbox keeps the domain events until the relay publishes them.
+				`CREATE TABLE IF NOT EXISTS outbox (
+					id BIGINT AUTO_INCREMENT PRIMARY KEY,
+					-- event_id is the deduplication id of the event for the consumers.
+					event_id CHAR(36) NOT NULL UNIQUE,
+					routing_key VARCHAR(64) NOT NULL,
+					body JSON NOT NULL,
+					created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+				)`,
+			}
+
+			for k, query := range qs {
+				if _, err := tx.Exec(query); err != nil {
+					return errors.Wrapf(err, "applying 202610181700_outbox migration #%d", k)
+				}
+			}
+			return nil
+		},
+	}
+}
+
+/* ROLLBACK SQL
+DROP TABLE IF EXISTS outbox;
+*/
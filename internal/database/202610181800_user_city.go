@@ -0,0 +1,38 @@
+package database
+
+import (
+	"database/sql"
+
+	"github.com/lopezator/migrator"
+	"github.com/pkg/errors"
+)
+
+//nolint // to bypass gosec sql concat warning
+func migrationUserCity(schema string) *migrator.Migration {
+	return &migrator.Migration{
+		Name: "202610181800_user_city",
+		Func: func(tx *sql.Tx) error {
+			qs := []string{
+				// user_city limits the city scoped users to editing the data of their cities.
+				`CREATE TABLE IF NOT EXISTS user_city (
+					user_id BIGINT NOT NULL,
+					city_id INT NOT NULL,
+					PRIMARY KEY(user_id, city_id),
+					FOREIGN KEY(user_id) REFERENCES user(id) ON UPDATE CASCADE ON DELETE CASCADE,
+					FOREIGN KEY(city_id) REFERENCES city(id) ON UPDATE CASCADE ON DELETE CASCADE
+				)`,
+			}
+
+			for k, query := range qs {
+				if _, err := tx.Exec(query); err != nil {
+					return errors.Wrapf(err, "applying 202610181800_user_city migration #%d", k)
+				}
+			}
+			return nil
+		},
+	}
+}
+
+/* ROLLBACK SQL
+DROP TABLE IF EXISTS user_city;
+*/
@@ -0,0 +1,45 @@
+package database
+
+import (
+	"database/sql"
+
+	"github.com/lopezator/migrator"
+	"github.com/pkg/errors"
+)
+
+//nolint // to bypass gosec sql concat warning
+func migrationAPIKey(schema string) *migrator.Migration {
+	return &migrator.Migration{
+		Name: "202610181900_api_key",
+		Func: func(tx *sql.Tx) error {
+			qs := []string{
+				`CREATE TABLE IF NOT EXISTS api_key (
+					id BIGINT AUTO_INCREMENT PRIMARY KEY,
+					user_id BIGINT NOT NULL,
+					name VARCHAR(255) NOT NULL,
+					-- prefix is the beginning of the key to tell the keys apart, the key itself is not stored.
+					prefix VARCHAR(16) NOT NULL,
+					-- hashed_key is SHA-256 of the key.
+					hashed_key BINARY(32) NOT NULL UNIQUE,
+					-- scopes is the space separated list of the permissions.
+					scopes VARCHAR(1024) NOT NULL,
+					expires_at TIMESTAMP NULL DEFAULT NULL,
+					created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+					UNIQUE(user_id, name),
+					FOREIGN KEY(user_id) REFERENCES user(id) ON UPDATE CASCADE ON DELETE CASCADE
+				)`,
+			}
+
+			for k, query := range qs {
+				if _, err := tx.Exec(query); err != nil {
+					return errors.Wrapf(err, "applying 202610181900_api_key migration #%d", k)
+				}
+			}
+			return nil
+		},
+	}
+}
+
+/* ROLLBACK SQL
+DROP TABLE IF EXISTS api_key;
+*/
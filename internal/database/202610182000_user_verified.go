@@ -0,0 +1,33 @@
+package database
+
+import (
+	"database/sql"
+
+	"github.com/lopezator/migrator"
+	"github.com/pkg/errors"
+)
+
+//nolint // to bypass gosec sql concat warning
+func migrationUserVerified(schema string) *migrator.Migration {
+	return &migrator.Migration{
+		Name: "202610182000_user_verified",
+		Func: func(tx *sql.Tx) error {
+			qs := []string{
+				`ALTER TABLE user ADD COLUMN verified BOOLEAN NOT NULL DEFAULT FALSE AFTER type`,
+				// the users signed up before the verification are trusted.
+				`UPDATE user SET verified = TRUE`,
+			}
+
+			for k, query := range qs {
+				if _, err := tx.Exec(query); err != nil {
+					return errors.Wrapf(err, "applying 202610182000_user_verified migration #%d", k)
+				}
+			}
+			return nil
+		},
+	}
+}
+
+/* ROLLBACK SQL
+ALTER TABLE user DROP COLUMN verified;
+*/
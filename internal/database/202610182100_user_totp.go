@@ -0,0 +1,49 @@
+package database
+
+import (
+	"database/sql"
+
+	"github.com/lopezator/migrator"
+	"github.com/pkg/errors"
+)
+
+//nolint // to bypass gosec sql concat warning
+func migrationUserTOTP(schema string) *migrator.Migration {
+	return &migrator.Migration{
+		Name: "202610182100_user_totp",
+		Func: func(tx *sql.Tx) error {
+			qs := []string{
+				`CREATE TABLE IF NOT EXISTS user_totp (
+					user_id BIGINT PRIMARY KEY,
+					-- secret is the base32 encoded TOTP key.
+					secret VARCHAR(64) NOT NULL,
+					-- enabled is set, when the first code of the secret is confirmed.
+					enabled BOOLEAN NOT NULL DEFAULT FALSE,
+					-- last_step is the time step of the last accepted code, so the code can not be used twice.
+					last_step BIGINT NOT NULL DEFAULT 0,
+					created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+					FOREIGN KEY(user_id) REFERENCES user(id) ON UPDATE CASCADE ON DELETE CASCADE
+				)`,
+				`CREATE TABLE IF NOT EXISTS user_recovery_code (
+					user_id BIGINT NOT NULL,
+					-- hashed_code is SHA-256 of the recovery code.
+					hashed_code BINARY(32) NOT NULL,
+					PRIMARY KEY(user_id, hashed_code),
+					FOREIGN KEY(user_id) REFERENCES user(id) ON UPDATE CASCADE ON DELETE CASCADE
+				)`,
+			}
+
+			for k, query := range qs {
+				if _, err := tx.Exec(query); err != nil {
+					return errors.Wrapf(err, "applying 202610182100_user_totp migration #%d", k)
+				}
+			}
+			return nil
+		},
+	}
+}
+
+/* ROLLBACK SQL
+DROP TABLE IF EXISTS user_recovery_code;
+DROP TABLE IF EXISTS user_totp;
+*/
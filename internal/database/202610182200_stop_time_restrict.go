@@ -0,0 +1,38 @@
+package database
+
+import (
+	"database/sql"
+
+	"github.com/lopezator/migrator"
+	"github.com/pkg/errors"
+)
+
+//nolint // to bypass gosec sql concat warning
+func migrationStopTimeRestrict(schema string) *migrator.Migration {
+	return &migrator.Migration{
+		Name: "202610182200_stop_time_restrict",
+		Func: func(tx *sql.Tx) error {
+			qs := []string{
+				`ALTER TABLE stop_time DROP FOREIGN KEY stop_time_route_fk`,
+				// the steps with the stop times can not be deleted, so the timetable is not lost silently.
+				`ALTER TABLE stop_time
+					ADD CONSTRAINT stop_time_route_fk FOREIGN KEY(bus_id, direction, variant, step)
+						REFERENCES route(bus_id, direction, variant, step) ON UPDATE CASCADE ON DELETE RESTRICT`,
+			}
+
+			for k, query := range qs {
+				if _, err := tx.Exec(query); err != nil {
+					return errors.Wrapf(err, "applying 202610182200_stop_time_restrict migration #%d", k)
+				}
+			}
+			return nil
+		},
+	}
+}
+
+/* ROLLBACK SQL
+ALTER TABLE stop_time DROP FOREIGN KEY stop_time_route_fk;
+ALTER TABLE stop_time
+	ADD CONSTRAINT stop_time_route_fk FOREIGN KEY(bus_id, direction, variant, step)
+		REFERENCES route(bus_id, direction, variant, step) ON UPDATE CASCADE ON DELETE CASCADE;
+*/
@@ -0,0 +1,35 @@
+package database
+
+import (
+	"database/sql"
+
+	"github.com/lopezator/migrator"
+	"github.com/pkg/errors"
+)
+
+//nolint // to bypass gosec sql concat warning
+func migrationOutboxClaim(schema string) *migrator.Migration {
+	return &migrator.Migration{
+		Name: "202610182300_outbox_claim",
+		Func: func(tx *sql.Tx) error {
+			qs := []string{
+				// claimed_until is the time the relay that claimed the event has to publish it,
+				// after that the event is claimed again.
+				`ALTER TABLE outbox
+					ADD COLUMN claimed_until DATETIME(3) NULL,
+					ADD INDEX outbox_claimed_until_idx (claimed_until)`,
+			}
+
+			for k, query := range qs {
+				if _, err := tx.Exec(query); err != nil {
+					return errors.Wrapf(err, "applying 202610182300_outbox_claim migration #%d", k)
+				}
+			}
+			return nil
+		},
+	}
+}
+
+/* ROLLBACK SQL
+ALTER TABLE outbox DROP INDEX outbox_claimed_until_idx, DROP COLUMN claimed_until;
+*/
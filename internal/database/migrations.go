@@ -22,6 +22,17 @@ func migrations(schema, migrationsTable string) (*migrator.Migrator, error) {
 		migrator.Migrations(
 			migrationInit(schema),
 			migrationUser(schema),
+			migrationTimetable(schema),
+			migrationStopLocation(schema),
+			migrationRoutePattern(schema),
+			migrationRouteStep(schema),
+			migrationOutbox(schema),
+			migrationUserCity(schema),
+			migrationAPIKey(schema),
+			migrationUserVerified(schema),
+			migrationUserTOTP(schema),
+			migrationStopTimeRestrict(schema),
+			migrationOutboxClaim(schema),
 		),
 	)
 }
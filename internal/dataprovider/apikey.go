@@ -0,0 +1,43 @@
+package dataprovider
+
+import (
+	"context"
+
+	"github.com/gxravel/bus-routes/internal/model"
+)
+
+type APIKeyStore interface {
+	WithTx(*Tx) APIKeyStore
+	GetByFilter(ctx context.Context, filter *APIKeyFilter) (*model.APIKey, error)
+	GetListByFilter(ctx context.Context, filter *APIKeyFilter) ([]*model.APIKey, error)
+	Add(ctx context.Context, key *model.APIKey) (int64, error)
+	Delete(ctx context.Context, filter *APIKeyFilter) error
+}
+
+type APIKeyFilter struct {
+	IDs        []int64
+	UserIDs    []int64
+	HashedKeys [][]byte
+}
+
+func NewAPIKeyFilter() *APIKeyFilter {
+	return &APIKeyFilter{}
+}
+
+// ByIDs filters by api_key.id.
+func (f *APIKeyFilter) ByIDs(ids ...int64) *APIKeyFilter {
+	f.IDs = ids
+	return f
+}
+
+// ByUserIDs filters by api_key.user_id.
+func (f *APIKeyFilter) ByUserIDs(ids ...int64) *APIKeyFilter {
+	f.UserIDs = ids
+	return f
+}
+
+// ByHashedKeys filters by api_key.hashed_key.
+func (f *APIKeyFilter) ByHashedKeys(keys ...[]byte) *APIKeyFilter {
+	f.HashedKeys = keys
+	return f
+}
@@ -11,6 +11,8 @@ type BusStore interface {
 	GetByFilter(ctx context.Context, filter *BusFilter) (*model.Bus, error)
 	GetListByFilter(ctx context.Context, filter *BusFilter) ([]*model.Bus, error)
 	Add(ctx context.Context, buses ...*model.Bus) error
+	Update(ctx context.Context, bus *model.Bus) error
+	Delete(ctx context.Context, filter *BusFilter) error
 }
 
 type BusFilter struct {
@@ -0,0 +1,137 @@
+package mysql
+
+import (
+	"context"
+
+	"github.com/gxravel/bus-routes/internal/dataprovider"
+	"github.com/gxravel/bus-routes/internal/model"
+
+	sq "github.com/Masterminds/squirrel"
+	"github.com/jmoiron/sqlx"
+	"github.com/pkg/errors"
+)
+
+// APIKeyStore is api key mysql store.
+type APIKeyStore struct {
+	db        sqlx.ExtContext
+	txer      dataprovider.Txer
+	tableName string
+}
+
+// NewAPIKeyStore creates new instance of APIKeyStore.
+func NewAPIKeyStore(db sqlx.ExtContext, txer dataprovider.Txer) *APIKeyStore {
+	return &APIKeyStore{
+		db:        db,
+		txer:      txer,
+		tableName: "api_key",
+	}
+}
+
+// WithTx sets transaction as active connection.
+func (s *APIKeyStore) WithTx(tx *dataprovider.Tx) dataprovider.APIKeyStore {
+	return &APIKeyStore{
+		db:        tx,
+		txer:      s.txer,
+		tableName: s.tableName,
+	}
+}
+
+func apiKeyCond(f *dataprovider.APIKeyFilter) sq.Sqlizer {
+	eq := make(sq.Eq)
+	var cond sq.Sqlizer = eq
+
+	if len(f.IDs) > 0 {
+		eq["api_key.id"] = f.IDs
+	}
+	if len(f.UserIDs) > 0 {
+		eq["user_id"] = f.UserIDs
+	}
+	if len(f.HashedKeys) > 0 {
+		eq["hashed_key"] = f.HashedKeys
+	}
+
+	return cond
+}
+
+// GetByFilter returns api key depend on received filters.
+func (s *APIKeyStore) GetByFilter(ctx context.Context, filter *dataprovider.APIKeyFilter) (*model.APIKey, error) {
+	keys, err := s.GetListByFilter(ctx, filter)
+
+	switch {
+	case err != nil:
+		return nil, err
+	case len(keys) == 0:
+		return nil, nil
+	case len(keys) == 1:
+		return keys[0], nil
+	default:
+		return nil, errors.New("fetched more than 1 api key")
+	}
+}
+
+// GetListByFilter returns api keys depend on received filters.
+func (s *APIKeyStore) GetListByFilter(ctx context.Context, filter *dataprovider.APIKeyFilter) ([]*model.APIKey, error) {
+	qb := sq.
+		Select(
+			"id",
+			"user_id",
+			"name",
+			"prefix",
+			"hashed_key",
+			"scopes",
+			"UNIX_TIMESTAMP(expires_at) as expires_at",
+			"UNIX_TIMESTAMP(created_at) as created_at",
+		).
+		From(s.tableName).
+		Where(apiKeyCond(filter)).
+		OrderBy("id")
+
+	query, args, err := qb.ToSql()
+	if err != nil {
+		return nil, err
+	}
+
+	message := "select " + s.tableName + " by filter with query " + query
+
+	var result = make([]*model.APIKey, 0)
+	if err := sqlx.SelectContext(ctx, s.db, &result, query, args...); err != nil {
+		return nil, errors.Wrapf(err, message)
+	}
+
+	return result, nil
+}
+
+// Add creates new api key.
+func (s *APIKeyStore) Add(ctx context.Context, key *model.APIKey) (int64, error) {
+	var expiresAt interface{}
+	if key.ExpiresAt != nil {
+		expiresAt = sq.Expr("FROM_UNIXTIME(?)", *key.ExpiresAt)
+	}
+
+	qb := sq.Insert(s.tableName).
+		Columns("user_id", "name", "prefix", "hashed_key", "scopes", "expires_at").
+		Values(key.UserID, key.Name, key.Prefix, key.HashedKey, key.Scopes, expiresAt)
+
+	query, args, codewords, err := toSql(ctx, qb, s.tableName)
+	if err != nil {
+		return 0, err
+	}
+
+	result, err := s.db.ExecContext(ctx, query, args...)
+	if err != nil {
+		return 0, errors.Wrapf(err, codewords+" with query %s", query)
+	}
+
+	lastID, err := result.LastInsertId()
+	if err != nil {
+		return 0, errors.Wrapf(err, "failed to get last insert id while "+codewords)
+	}
+
+	return lastID, nil
+}
+
+// Delete deletes api keys depend on received filter.
+func (s *APIKeyStore) Delete(ctx context.Context, filter *dataprovider.APIKeyFilter) error {
+	qb := sq.Delete(s.tableName).Where(apiKeyCond(filter))
+	return execContext(ctx, qb, s.tableName, s.db)
+}
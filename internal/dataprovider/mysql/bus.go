@@ -16,6 +16,7 @@ import (
 type BusStore struct {
 	db        sqlx.ExtContext
 	txer      dataprovider.Txer
+	tx        *dataprovider.Tx
 	tableName string
 }
 
@@ -32,6 +33,8 @@ func NewBusStore(db sqlx.ExtContext, txer dataprovider.Txer) *BusStore {
 func (s *BusStore) WithTx(tx *dataprovider.Tx) dataprovider.BusStore {
 	return &BusStore{
 		db:        tx,
+		txer:      s.txer,
+		tx:        tx,
 		tableName: s.tableName,
 	}
 }
@@ -131,6 +134,15 @@ func (s *BusStore) Add(ctx context.Context, buses ...*model.Bus) error {
 			return err
 		}
 
+		var (
+			cities = make([]string, 0, len(ids))
+			nums   = make([]string, 0, len(buses))
+			added  = make(map[[2]string]bool, len(buses))
+		)
+		for city := range ids {
+			cities = append(cities, city)
+		}
+
 		qb := sq.Insert("bus").Columns("city_id", "num")
 		for _, bus := range buses {
 			id := ids[bus.City]
@@ -141,14 +153,99 @@ func (s *BusStore) Add(ctx context.Context, buses ...*model.Bus) error {
 			}
 
 			qb = qb.Values(id, bus.Number)
+
+			nums = append(nums, bus.Number)
+			added[[2]string{bus.City, bus.Number}] = true
+		}
+
+		if err := execContext(ctx, qb, s.tableName, tx); err != nil {
+			return err
+		}
+
+		filter := dataprovider.NewBusFilter().
+			ByCities(cities...).
+			ByNums(nums...)
+
+		created, err := s.WithTx(tx).GetListByFilter(ctx, filter)
+		if err != nil {
+			return err
+		}
+
+		var events = make([]interface{}, 0, len(buses))
+		for _, bus := range created {
+			if added[[2]string{bus.City, bus.Number}] {
+				events = append(events, model.BusEvent{ID: bus.ID, City: bus.City, Num: bus.Number})
+			}
+		}
+
+		return addEvents(ctx, tx, model.EventBusCreated, events...)
+	}
+
+	return inTx(ctx, s.txer, s.tx, f)
+}
+
+// Update updates the city and the number of the bus.
+func (s *BusStore) Update(ctx context.Context, bus *model.Bus) error {
+	f := func(tx *dataprovider.Tx) error {
+		id, err := getCityID(ctx, bus.City, s.db, s.txer, tx)
+		if err != nil {
+			return err
+		}
+		if id == 0 {
+			err := errors.Errorf("did not found the city %s", bus.City)
+			log.FromContext(ctx).Debug(err.Error())
+
+			return err
+		}
+
+		qb := sq.Update(s.tableName).
+			SetMap(map[string]interface{}{
+				"city_id": id,
+				"num":     bus.Number,
+			}).
+			Where(sq.Eq{"id": bus.ID})
+
+		if err := execContext(ctx, qb, s.tableName, tx); err != nil {
+			return err
+		}
+
+		return addEvents(ctx, tx, model.EventBusUpdated, model.BusEvent{ID: bus.ID, City: bus.City, Num: bus.Number})
+	}
+
+	return inTx(ctx, s.txer, s.tx, f)
+}
+
+// Delete deletes buses depend on received filter, their routes and timetables are deleted with them.
+func (s *BusStore) Delete(ctx context.Context, filter *dataprovider.BusFilter) error {
+	f := func(tx *dataprovider.Tx) error {
+		buses, err := s.WithTx(tx).GetListByFilter(ctx, filter)
+		if err != nil {
+			return err
+		}
+		if len(buses) == 0 {
+			return errNoRowsAffected
+		}
+
+		var (
+			ids    = make([]int64, 0, len(buses))
+			events = make([]interface{}, 0, len(buses))
+		)
+		for _, bus := range buses {
+			ids = append(ids, bus.ID)
+			events = append(events, model.BusEvent{ID: bus.ID, City: bus.City, Num: bus.Number})
+		}
+
+		if err := deleteStopTimes(ctx, tx, ids...); err != nil {
+			return err
 		}
 
+		qb := sq.Delete(s.tableName).Where(sq.Eq{"id": ids})
 		if err := execContext(ctx, qb, s.tableName, tx); err != nil {
 			return err
 		}
 
-		return nil
+		return addEvents(ctx, tx, model.EventBusDeleted, events...)
 	}
 
-	return dataprovider.BeginAutoCommitedTx(ctx, s.txer, f)
+	return inTx(ctx, s.txer, s.tx, f)
 }
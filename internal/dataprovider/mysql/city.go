@@ -15,6 +15,7 @@ import (
 type CityStore struct {
 	db        sqlx.ExtContext
 	txer      dataprovider.Txer
+	tx        *dataprovider.Tx
 	tableName string
 }
 
@@ -31,6 +32,8 @@ func NewCityStore(db sqlx.ExtContext, txer dataprovider.Txer) *CityStore {
 func (s *CityStore) WithTx(tx *dataprovider.Tx) dataprovider.CityStore {
 	return &CityStore{
 		db:        tx,
+		txer:      s.txer,
+		tx:        tx,
 		tableName: s.tableName,
 	}
 }
@@ -92,27 +95,123 @@ func (s *CityStore) GetListByFilter(ctx context.Context, filter *dataprovider.Ci
 
 // Add creates new cities.
 func (s *CityStore) Add(ctx context.Context, cities ...*model.City) error {
-	qb := sq.Insert(s.tableName).Columns("name")
-	for _, city := range cities {
-		qb = qb.Values(city.Name)
+	f := func(tx *dataprovider.Tx) error {
+		var names = make([]string, 0, len(cities))
+
+		qb := sq.Insert(s.tableName).Columns("name")
+		for _, city := range cities {
+			qb = qb.Values(city.Name)
+			names = append(names, city.Name)
+		}
+
+		if err := execContext(ctx, qb, s.tableName, tx); err != nil {
+			return err
+		}
+
+		created, err := s.WithTx(tx).GetListByFilter(ctx, dataprovider.NewCityFilter().ByNames(names...))
+		if err != nil {
+			return err
+		}
+
+		var events = make([]interface{}, 0, len(created))
+		for _, city := range created {
+			events = append(events, model.CityEvent{ID: city.ID, Name: city.Name})
+		}
+
+		return addEvents(ctx, tx, model.EventCityCreated, events...)
 	}
 
-	return execContext(ctx, qb, s.tableName, s.db)
+	return inTx(ctx, s.txer, s.tx, f)
 }
 
 // Update updates city name.
 func (s *CityStore) Update(ctx context.Context, city *model.City) error {
-	qb := sq.Update(s.tableName).
-		Set("name", city.Name).
-		Where(sq.Eq{"id": city.ID})
+	f := func(tx *dataprovider.Tx) error {
+		qb := sq.Update(s.tableName).
+			Set("name", city.Name).
+			Where(sq.Eq{"id": city.ID})
 
-	return execContext(ctx, qb, s.tableName, s.db)
+		if err := execContext(ctx, qb, s.tableName, tx); err != nil {
+			return err
+		}
+
+		return addEvents(ctx, tx, model.EventCityUpdated, model.CityEvent{ID: city.ID, Name: city.Name})
+	}
+
+	return inTx(ctx, s.txer, s.tx, f)
 }
 
 // Delete deletes city depend on received filter.
+// The buses and the stops of the city are deleted by cascade, their events are written before the event of the city,
+// the routes and the timetables go with the buses as on deleting the bus.
 func (s *CityStore) Delete(ctx context.Context, filter *dataprovider.CityFilter) error {
-	qb := sq.Delete(s.tableName).Where(cityCond(filter))
-	return execContext(ctx, qb, s.tableName, s.db)
+	f := func(tx *dataprovider.Tx) error {
+		cities, err := s.WithTx(tx).GetListByFilter(ctx, filter)
+		if err != nil {
+			return err
+		}
+		if len(cities) == 0 {
+			return errNoRowsAffected
+		}
+
+		var (
+			ids    = make([]int, 0, len(cities))
+			names  = make([]string, 0, len(cities))
+			events = make([]interface{}, 0, len(cities))
+		)
+		for _, city := range cities {
+			ids = append(ids, city.ID)
+			names = append(names, city.Name)
+			events = append(events, model.CityEvent{ID: city.ID, Name: city.Name})
+		}
+
+		busStore := NewBusStore(s.db, s.txer).WithTx(tx)
+
+		buses, err := busStore.GetListByFilter(ctx, dataprovider.NewBusFilter().ByCities(names...))
+		if err != nil {
+			return err
+		}
+
+		var (
+			busIDs    = make([]int64, 0, len(buses))
+			busEvents = make([]interface{}, 0, len(buses))
+		)
+		for _, bus := range buses {
+			busIDs = append(busIDs, bus.ID)
+			busEvents = append(busEvents, model.BusEvent{ID: bus.ID, City: bus.City, Num: bus.Number})
+		}
+
+		stops, err := NewStopStore(s.db, s.txer).WithTx(tx).GetListByFilter(ctx, dataprovider.NewStopFilter().ByCities(names...))
+		if err != nil {
+			return err
+		}
+
+		var stopEvents = make([]interface{}, 0, len(stops))
+		for _, stop := range stops {
+			stopEvents = append(stopEvents, model.NewStopEvent(stop))
+		}
+
+		if err := deleteStopTimes(ctx, tx, busIDs...); err != nil {
+			return err
+		}
+
+		qb := sq.Delete(s.tableName).Where(sq.Eq{"id": ids})
+		if err := execContext(ctx, qb, s.tableName, tx); err != nil {
+			return err
+		}
+
+		// the routes refer to the stops, so the buses are deleted first.
+		if err := addEvents(ctx, tx, model.EventBusDeleted, busEvents...); err != nil {
+			return err
+		}
+		if err := addEvents(ctx, tx, model.EventStopDeleted, stopEvents...); err != nil {
+			return err
+		}
+
+		return addEvents(ctx, tx, model.EventCityDeleted, events...)
+	}
+
+	return inTx(ctx, s.txer, s.tx, f)
 }
 
 // getCitiesIDs return the ids as a map of names.
@@ -149,6 +248,9 @@ func getCityID(ctx context.Context, name string, db sqlx.ExtContext, txer datapr
 	if err != nil {
 		return 0, errors.Wrap(err, "getting city from city store")
 	}
+	if city == nil {
+		return 0, nil
+	}
 
 	return city.ID, nil
 }
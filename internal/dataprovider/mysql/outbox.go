@@ -0,0 +1,144 @@
+package mysql
+
+import (
+	"context"
+	"time"
+
+	"github.com/gxravel/bus-routes/internal/dataprovider"
+	"github.com/gxravel/bus-routes/internal/model"
+
+	sq "github.com/Masterminds/squirrel"
+	"github.com/jmoiron/sqlx"
+	"github.com/pkg/errors"
+)
+
+const outboxTable = "outbox"
+
+// OutboxStore is outbox mysql store.
+type OutboxStore struct {
+	db        sqlx.ExtContext
+	txer      dataprovider.Txer
+	tx        *dataprovider.Tx
+	tableName string
+}
+
+// NewOutboxStore creates new instance of OutboxStore.
+func NewOutboxStore(db sqlx.ExtContext, txer dataprovider.Txer) *OutboxStore {
+	return &OutboxStore{
+		db:        db,
+		txer:      txer,
+		tableName: outboxTable,
+	}
+}
+
+// WithTx sets transaction as active connection.
+func (s *OutboxStore) WithTx(tx *dataprovider.Tx) dataprovider.OutboxStore {
+	return &OutboxStore{
+		db:        tx,
+		txer:      s.txer,
+		tx:        tx,
+		tableName: s.tableName,
+	}
+}
+
+// GetListByFilter returns events in the order they were written.
+func (s *OutboxStore) GetListByFilter(ctx context.Context, filter *dataprovider.OutboxFilter) ([]*model.Event, error) {
+	qb := sq.
+		Select(
+			"id",
+			"event_id",
+			"routing_key",
+			"body",
+		).
+		From(s.tableName).
+		OrderBy("id")
+
+	if filter.Unclaimed {
+		qb = qb.Where(sq.Or{
+			sq.Eq{"claimed_until": nil},
+			sq.Expr("claimed_until < NOW(3)"),
+		})
+	}
+	if filter.Limit > 0 {
+		qb = qb.Limit(filter.Limit)
+	}
+	if filter.ForUpdate {
+		qb = qb.Suffix("FOR UPDATE SKIP LOCKED")
+	}
+
+	query, args, err := qb.ToSql()
+	if err != nil {
+		return nil, err
+	}
+
+	message := "select " + s.tableName + " by filter with query " + query
+
+	var result = make([]*model.Event, 0)
+	if err := sqlx.SelectContext(ctx, s.db, &result, query, args...); err != nil {
+		return nil, errors.Wrapf(err, message)
+	}
+
+	return result, nil
+}
+
+// Claim claims the events for timeout, the time of the database is used,
+// so the clocks of the relays do not matter.
+func (s *OutboxStore) Claim(ctx context.Context, timeout time.Duration, ids ...int64) error {
+	qb := sq.Update(s.tableName).
+		Set("claimed_until", sq.Expr("NOW(3) + INTERVAL ? MICROSECOND", timeout.Microseconds())).
+		Where(sq.Eq{"id": ids})
+	return execContext(ctx, qb, s.tableName, s.db)
+}
+
+// Release releases the claimed events that were not published, so they are claimed again right away.
+func (s *OutboxStore) Release(ctx context.Context, ids ...int64) error {
+	qb := sq.Update(s.tableName).
+		Set("claimed_until", nil).
+		Where(sq.Eq{"id": ids})
+	return execContext(ctx, qb, s.tableName, s.db)
+}
+
+// Delete deletes the published events.
+func (s *OutboxStore) Delete(ctx context.Context, ids ...int64) error {
+	qb := sq.Delete(s.tableName).Where(sq.Eq{"id": ids})
+	return execContext(ctx, qb, s.tableName, s.db)
+}
+
+// addEvents writes the events of the mutation to the outbox in the transaction of the mutation,
+// so the events are published only if the mutation is commited.
+func addEvents(ctx context.Context, tx *dataprovider.Tx, routingKey string, data ...interface{}) error {
+	if len(data) == 0 {
+		return nil
+	}
+
+	qb := sq.Insert(outboxTable).Columns("event_id", "routing_key", "body")
+	for _, d := range data {
+		event, err := model.NewEvent(routingKey, d)
+		if err != nil {
+			return errors.Wrapf(err, "create %s event", routingKey)
+		}
+
+		qb = qb.Values(event.EventID, event.RoutingKey, event.Body)
+	}
+
+	return execContext(ctx, qb, outboxTable, tx)
+}
+
+// addRouteEvents writes the event for every distinct pattern of the routes.
+func addRouteEvents(ctx context.Context, tx *dataprovider.Tx, routingKey string, routes ...*model.Route) error {
+	var (
+		seen = make(map[model.RoutePattern]bool, len(routes))
+		data = make([]interface{}, 0)
+	)
+
+	for _, route := range routes {
+		if seen[route.RoutePattern] {
+			continue
+		}
+		seen[route.RoutePattern] = true
+
+		data = append(data, model.NewRouteEvent(route.RoutePattern))
+	}
+
+	return addEvents(ctx, tx, routingKey, data...)
+}
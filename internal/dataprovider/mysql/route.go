@@ -15,6 +15,7 @@ import (
 type RouteStore struct {
 	db        sqlx.ExtContext
 	txer      dataprovider.Txer
+	tx        *dataprovider.Tx
 	tableName string
 }
 
@@ -31,6 +32,8 @@ func NewRouteStore(db sqlx.ExtContext, txer dataprovider.Txer) *RouteStore {
 func (s *RouteStore) WithTx(tx *dataprovider.Tx) dataprovider.RouteStore {
 	return &RouteStore{
 		db:        tx,
+		txer:      s.txer,
+		tx:        tx,
 		tableName: s.tableName,
 	}
 }
@@ -42,12 +45,21 @@ func routeCond(f *dataprovider.RouteFilter) sq.Sqlizer {
 	if len(f.BusIDs) > 0 {
 		eq["route.bus_id"] = f.BusIDs
 	}
+	if len(f.Directions) > 0 {
+		eq["route.direction"] = f.Directions
+	}
+	if len(f.Variants) > 0 {
+		eq["route.variant"] = f.Variants
+	}
 	if len(f.StopIDs) > 0 {
 		eq["route.stop_id"] = f.StopIDs
 	}
 	if len(f.Steps) > 0 {
 		eq["route.step"] = f.Steps
 	}
+	if len(f.Cities) > 0 {
+		eq["city.name"] = f.Cities
+	}
 
 	return cond
 }
@@ -56,14 +68,21 @@ func (s *RouteStore) columns(filter *dataprovider.RouteFilter) []string {
 	if filter != nil && filter.DetailedView {
 		return []string{
 			"route.bus_id as bus_id",
+			"direction",
+			"variant",
+			"route.stop_id as stop_id",
 			"city.name as city",
 			"num",
 			"step",
 			"address",
+			"lat",
+			"lon",
 		}
 	}
 	return []string{
 		"bus_id",
+		"direction",
+		"variant",
 		"stop_id",
 		"step",
 	}
@@ -79,7 +98,7 @@ func (s *RouteStore) joins(qb sq.SelectBuilder, filter *dataprovider.RouteFilter
 }
 
 func (s *RouteStore) ordersBy(qb sq.SelectBuilder, filter *dataprovider.RouteFilter) sq.SelectBuilder {
-	qb = qb.OrderBy("bus_id", "step")
+	qb = qb.OrderBy("bus_id", "direction", "variant", "step")
 	return qb
 }
 
@@ -108,6 +127,9 @@ func (s *RouteStore) GetListByFilter(ctx context.Context, filter *dataprovider.R
 
 	qb = s.joins(qb, filter)
 	qb = s.ordersBy(qb, filter)
+	if filter.ForUpdate {
+		qb = qb.Suffix("FOR UPDATE")
+	}
 
 	query, args, err := qb.ToSql()
 	if err != nil {
@@ -126,28 +148,197 @@ func (s *RouteStore) GetListByFilter(ctx context.Context, filter *dataprovider.R
 
 // Add creates new routes.
 func (s *RouteStore) Add(ctx context.Context, routes ...*model.Route) error {
-	qb := sq.Insert(s.tableName).Columns(s.columns(nil)...)
-	for _, route := range routes {
-		qb = qb.Values(route.BusID, route.StopID, route.Step)
+	f := func(tx *dataprovider.Tx) error {
+		qb := sq.Insert(s.tableName).Columns(s.columns(nil)...)
+		for _, route := range routes {
+			qb = qb.Values(route.BusID, route.Direction, route.Variant, route.StopID, route.Step)
+		}
+
+		if err := execContext(ctx, qb, s.tableName, tx); err != nil {
+			return err
+		}
+
+		return addRouteEvents(ctx, tx, model.EventRouteUpdated, routes...)
 	}
 
-	return execContext(ctx, qb, s.tableName, s.db)
+	return inTx(ctx, s.txer, s.tx, f)
 }
 
 // Update updates route's stop_id.
 func (s *RouteStore) Update(ctx context.Context, route *model.Route) error {
-	qb := sq.Update(s.tableName).
-		Set("stop_id", route.StopID).
-		Where(sq.Eq{
-			"bus_id": route.BusID,
-			"step":   route.Step},
-		)
+	f := func(tx *dataprovider.Tx) error {
+		qb := sq.Update(s.tableName).
+			Set("stop_id", route.StopID).
+			Where(sq.Eq{
+				"bus_id":    route.BusID,
+				"direction": route.Direction,
+				"variant":   route.Variant,
+				"step":      route.Step},
+			)
+
+		if err := execContext(ctx, qb, s.tableName, tx); err != nil {
+			return err
+		}
+
+		return addRouteEvents(ctx, tx, model.EventRouteUpdated, route)
+	}
 
-	return execContext(ctx, qb, s.tableName, s.db)
+	return inTx(ctx, s.txer, s.tx, f)
 }
 
 // Delete deletes route depend on received filter.
 func (s *RouteStore) Delete(ctx context.Context, filter *dataprovider.RouteFilter) error {
-	qb := sq.Delete(s.tableName).Where(routeCond(filter))
-	return execContext(ctx, qb, s.tableName, s.db)
+	f := func(tx *dataprovider.Tx) error {
+		routes, err := s.WithTx(tx).GetListByFilter(ctx, filter)
+		if err != nil {
+			return err
+		}
+		if len(routes) == 0 {
+			return errNoRowsAffected
+		}
+
+		qb := sq.Delete(s.tableName).Where(routeCond(filter))
+		if err := execContext(ctx, qb, s.tableName, tx); err != nil {
+			return err
+		}
+
+		return addRouteEvents(ctx, tx, model.EventRouteUpdated, routes...)
+	}
+
+	return inTx(ctx, s.txer, s.tx, f)
+}
+
+// Replace replaces the stops of the route pattern with the routes ordered by step from 1.
+// Only the steps which differ from the current route are changed, so the stop times of the kept steps remain.
+// The steps with the changed stops are deleted and added again, so they are restricted by their stop times
+// as the deleted steps are.
+func (s *RouteStore) Replace(ctx context.Context, pattern model.RoutePattern, routes []*model.Route) error {
+	f := func(tx *dataprovider.Tx) error {
+		store := s.WithTx(tx)
+
+		current, err := store.GetListByFilter(ctx, dataprovider.NewRouteFilter().ByPattern(pattern).LockForUpdate())
+		if err != nil {
+			return err
+		}
+
+		var byStep = make(map[int]*model.Route, len(current))
+		for _, route := range current {
+			byStep[route.Step] = route
+		}
+
+		var (
+			newRoutes = make([]*model.Route, 0)
+			oldSteps  = make([]int, 0)
+			stopIDs   = make([]int64, 0, len(routes))
+		)
+
+		for _, route := range routes {
+			stopIDs = append(stopIDs, route.StopID)
+
+			existing, ok := byStep[route.Step]
+			switch {
+			case !ok:
+				newRoutes = append(newRoutes, route)
+			case existing.StopID != route.StopID:
+				oldSteps = append(oldSteps, route.Step)
+				newRoutes = append(newRoutes, route)
+			}
+		}
+
+		for _, route := range current {
+			if route.Step > len(routes) {
+				oldSteps = append(oldSteps, route.Step)
+			}
+		}
+
+		if len(oldSteps) > 0 {
+			qb := sq.Delete(s.tableName).Where(sq.And{patternEq(pattern), sq.Eq{"step": oldSteps}})
+			if err := execContext(ctx, qb, s.tableName, tx); err != nil {
+				return err
+			}
+		}
+
+		if len(newRoutes) > 0 {
+			qb := sq.Insert(s.tableName).Columns(s.columns(nil)...)
+			for _, route := range newRoutes {
+				qb = qb.Values(pattern.BusID, pattern.Direction, pattern.Variant, route.StopID, route.Step)
+			}
+
+			if err := execContext(ctx, qb, s.tableName, tx); err != nil {
+				return err
+			}
+		}
+
+		event := model.NewRouteEvent(pattern)
+		event.StopIDs = stopIDs
+
+		return addEvents(ctx, tx, model.EventRouteReplaced, event)
+	}
+
+	return inTx(ctx, s.txer, s.tx, f)
+}
+
+func patternEq(pattern model.RoutePattern) sq.Eq {
+	return sq.Eq{
+		"bus_id":    pattern.BusID,
+		"direction": pattern.Direction,
+		"variant":   pattern.Variant,
+	}
+}
+
+// ShiftSteps adds delta to the steps of the route pattern starting from the step.
+// The rows are updated in the order which keeps the steps unique.
+func (s *RouteStore) ShiftSteps(ctx context.Context, pattern model.RoutePattern, from, delta int) error {
+	f := func(tx *dataprovider.Tx) error {
+		order := "step DESC"
+		if delta < 0 {
+			order = "step"
+		}
+
+		qb := sq.Update(s.tableName).
+			Set("step", sq.Expr("step + ?", delta)).
+			Where(sq.And{patternEq(pattern), sq.GtOrEq{"step": from}}).
+			OrderBy(order)
+
+		err := execContext(ctx, qb, s.tableName, tx)
+		switch {
+		case err == errNoRowsAffected:
+			return nil
+		case err != nil:
+			return err
+		}
+
+		return addEvents(ctx, tx, model.EventRouteUpdated, model.NewRouteEvent(pattern))
+	}
+
+	return inTx(ctx, s.txer, s.tx, f)
+}
+
+// Renumber sets the steps of the route pattern in one transaction.
+// steps maps every current step of the pattern to the new one.
+func (s *RouteStore) Renumber(ctx context.Context, pattern model.RoutePattern, steps map[int]int) error {
+	f := func(tx *dataprovider.Tx) error {
+		// the steps are negated first to keep them unique while renumbering.
+		qb := sq.Update(s.tableName).
+			Set("step", sq.Expr("-step")).
+			Where(sq.And{patternEq(pattern), sq.Gt{"step": 0}})
+
+		if err := execContext(ctx, qb, s.tableName, tx); err != nil && err != errNoRowsAffected {
+			return err
+		}
+
+		for from, to := range steps {
+			qb := sq.Update(s.tableName).
+				Set("step", to).
+				Where(sq.And{patternEq(pattern), sq.Eq{"step": -from}})
+
+			if err := execContext(ctx, qb, s.tableName, tx); err != nil {
+				return err
+			}
+		}
+
+		return addEvents(ctx, tx, model.EventRouteUpdated, model.NewRouteEvent(pattern))
+	}
+
+	return inTx(ctx, s.txer, s.tx, f)
 }
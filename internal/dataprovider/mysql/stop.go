@@ -16,6 +16,7 @@ import (
 type StopStore struct {
 	db        sqlx.ExtContext
 	txer      dataprovider.Txer
+	tx        *dataprovider.Tx
 	tableName string
 }
 
@@ -32,6 +33,8 @@ func NewStopStore(db sqlx.ExtContext, txer dataprovider.Txer) *StopStore {
 func (s *StopStore) WithTx(tx *dataprovider.Tx) dataprovider.StopStore {
 	return &StopStore{
 		db:        tx,
+		txer:      s.txer,
+		tx:        tx,
 		tableName: s.tableName,
 	}
 }
@@ -44,11 +47,19 @@ func stopCond(f *dataprovider.StopFilter) sq.Sqlizer {
 		eq["stop.id"] = f.IDs
 	}
 	if len(f.Cities) > 0 {
-		eq["city"] = f.Cities
+		eq["city.name"] = f.Cities
 	}
 	if len(f.Addresses) > 0 {
 		eq["address"] = f.Addresses
 	}
+	if f.Location != nil {
+		minLat, minLon, maxLat, maxLon := f.Location.Bounds()
+		cond = sq.And{
+			eq,
+			sq.Expr("stop.lat BETWEEN ? AND ?", minLat, maxLat),
+			sq.Expr("stop.lon BETWEEN ? AND ?", minLon, maxLon),
+		}
+	}
 
 	return cond
 }
@@ -58,6 +69,8 @@ func (s *StopStore) columns(filter *dataprovider.StopFilter) []string {
 		"stop.id",
 		"city.name as city",
 		"address",
+		"lat",
+		"lon",
 	}
 
 	if filter.DoPreferIDs {
@@ -127,7 +140,16 @@ func (s *StopStore) Add(ctx context.Context, stops ...*model.Stop) error {
 			return err
 		}
 
-		qb := sq.Insert("stop").Columns("city_id", "address")
+		var (
+			cities    = make([]string, 0, len(ids))
+			addresses = make([]string, 0, len(stops))
+			added     = make(map[[2]string]bool, len(stops))
+		)
+		for city := range ids {
+			cities = append(cities, city)
+		}
+
+		qb := sq.Insert("stop").Columns("city_id", "address", "lat", "lon")
 		for _, stop := range stops {
 			id := ids[stop.City]
 			if id < 0 {
@@ -135,52 +157,114 @@ func (s *StopStore) Add(ctx context.Context, stops ...*model.Stop) error {
 					Debugf("stop [%s, %s] skipped", stop.City, stop.Address)
 				continue
 			}
-			qb = qb.Values(id, stop.Address)
+			qb = qb.Values(id, stop.Address, stop.Lat, stop.Lon)
+
+			addresses = append(addresses, stop.Address)
+			added[[2]string{stop.City, stop.Address}] = true
 		}
 
 		if err := execContext(ctx, qb, s.tableName, tx); err != nil {
 			return err
 		}
 
-		return nil
+		filter := dataprovider.NewStopFilter().
+			ByCities(cities...).
+			ByAddresses(addresses...)
+
+		created, err := s.WithTx(tx).GetListByFilter(ctx, filter)
+		if err != nil {
+			return err
+		}
+
+		var events = make([]interface{}, 0, len(stops))
+		for _, stop := range created {
+			if added[[2]string{stop.City, stop.Address}] {
+				events = append(events, model.NewStopEvent(stop))
+			}
+		}
+
+		return addEvents(ctx, tx, model.EventStopCreated, events...)
 	}
 
-	return dataprovider.BeginAutoCommitedTx(ctx, s.txer, f)
+	return inTx(ctx, s.txer, s.tx, f)
 }
 
-// Update updates stop's city_id and address.
+// Update updates stop's city_id, address and location, the ones which are not set are left as they are.
+// The location is updated only if both lat and lon are set.
 func (s *StopStore) Update(ctx context.Context, stop *model.Stop) error {
 	f := func(tx *dataprovider.Tx) error {
-		id, err := getCityID(ctx, stop.City, s.db, s.txer, tx)
-		if err != nil {
-			return err
-		}
-		if id == 0 {
-			err := errors.Errorf("did not found the city %s", stop.City)
-			log.FromContext(ctx).Debug(err.Error())
+		var values = make(map[string]interface{})
+		if stop.City != "" {
+			id, err := getCityID(ctx, stop.City, s.db, s.txer, tx)
+			if err != nil {
+				return err
+			}
+			if id == 0 {
+				err := errors.Errorf("did not found the city %s", stop.City)
+				log.FromContext(ctx).Debug(err.Error())
 
-			return err
+				return err
+			}
+
+			values["city_id"] = id
+		}
+		if stop.Address != "" {
+			values["address"] = stop.Address
+		}
+		if stop.Lat != nil && stop.Lon != nil {
+			values["lat"] = stop.Lat
+			values["lon"] = stop.Lon
+		}
+		if len(values) == 0 {
+			return errNoRowsAffected
 		}
 
 		qb := sq.Update(s.tableName).
-			SetMap(map[string]interface{}{
-				"city_id": id,
-				"address": stop.Address,
-			}).
+			SetMap(values).
 			Where(sq.Eq{"id": stop.ID})
 
-		if err := execContext(ctx, qb, s.tableName, s.db); err != nil {
+		if err := execContext(ctx, qb, s.tableName, tx); err != nil {
+			return err
+		}
+
+		updated, err := s.WithTx(tx).GetByFilter(ctx, dataprovider.NewStopFilter().ByIDs(stop.ID))
+		if err != nil {
 			return err
 		}
 
-		return nil
+		return addEvents(ctx, tx, model.EventStopUpdated, model.NewStopEvent(updated))
 	}
 
-	return dataprovider.BeginAutoCommitedTx(ctx, s.txer, f)
+	return inTx(ctx, s.txer, s.tx, f)
 }
 
 // Delete deletes stop depend on received filter.
 func (s *StopStore) Delete(ctx context.Context, filter *dataprovider.StopFilter) error {
-	qb := sq.Delete(s.tableName).Where(stopCond(filter))
-	return execContext(ctx, qb, s.tableName, s.db)
+	f := func(tx *dataprovider.Tx) error {
+		stops, err := s.WithTx(tx).GetListByFilter(ctx, filter)
+		if err != nil {
+			return err
+		}
+		if len(stops) == 0 {
+			return errNoRowsAffected
+		}
+
+		var (
+			ids    = make([]int64, 0, len(stops))
+			events = make([]interface{}, 0, len(stops))
+		)
+		for _, stop := range stops {
+			ids = append(ids, stop.ID)
+			events = append(events, model.NewStopEvent(stop))
+		}
+
+		qb := sq.Delete(s.tableName).Where(sq.Eq{"id": ids})
+		if err := execContext(ctx, qb, s.tableName, tx); err != nil {
+			return err
+		}
+
+		return addEvents(ctx, tx, model.EventStopDeleted, events...)
+	}
+
+	return inTx(ctx, s.txer, s.tx, f)
 }
@@ -0,0 +1,238 @@
+package mysql
+
+import (
+	"context"
+
+	"github.com/gxravel/bus-routes/internal/dataprovider"
+	"github.com/gxravel/bus-routes/internal/model"
+
+	sq "github.com/Masterminds/squirrel"
+	"github.com/jmoiron/sqlx"
+	"github.com/pkg/errors"
+)
+
+// TimetableStore is trip and stop_time mysql store.
+type TimetableStore struct {
+	db            sqlx.ExtContext
+	txer          dataprovider.Txer
+	tx            *dataprovider.Tx
+	tripTable     string
+	stopTimeTable string
+}
+
+// NewTimetableStore creates new instance of TimetableStore.
+func NewTimetableStore(db sqlx.ExtContext, txer dataprovider.Txer) *TimetableStore {
+	return &TimetableStore{
+		db:            db,
+		txer:          txer,
+		tripTable:     "trip",
+		stopTimeTable: "stop_time",
+	}
+}
+
+// WithTx sets transaction as active connection.
+func (s *TimetableStore) WithTx(tx *dataprovider.Tx) dataprovider.TimetableStore {
+	return &TimetableStore{
+		db:            tx,
+		txer:          s.txer,
+		tx:            tx,
+		tripTable:     s.tripTable,
+		stopTimeTable: s.stopTimeTable,
+	}
+}
+
+func tripCond(f *dataprovider.TimetableFilter) (sq.Sqlizer, error) {
+	eq := make(sq.Eq)
+	var cond = sq.And{eq}
+
+	if len(f.BusIDs) > 0 {
+		eq["trip.bus_id"] = f.BusIDs
+	}
+	if len(f.StopIDs) > 0 {
+		query, args, err := sq.
+			Select("bus_id", "direction", "variant").
+			From("route").
+			Where(sq.Eq{"stop_id": f.StopIDs}).
+			ToSql()
+		if err != nil {
+			return nil, err
+		}
+
+		cond = append(cond, sq.Expr("(trip.bus_id, trip.direction, trip.variant) IN ("+query+")", args...))
+	}
+
+	return cond, nil
+}
+
+func stopTimeCond(f *dataprovider.TimetableFilter) sq.Sqlizer {
+	eq := make(sq.Eq)
+	var cond sq.Sqlizer = eq
+
+	if len(f.BusIDs) > 0 {
+		eq["stop_time.bus_id"] = f.BusIDs
+	}
+	if len(f.StopIDs) > 0 {
+		eq["route.stop_id"] = f.StopIDs
+	}
+
+	return cond
+}
+
+func (s *TimetableStore) stopTimeColumns(filter *dataprovider.TimetableFilter) []string {
+	var result = []string{
+		"stop_time.bus_id",
+		"stop_time.direction",
+		"stop_time.variant",
+		"stop_time.step",
+		"route.stop_id",
+		"arrival",
+		"departure",
+	}
+
+	if filter.DetailedView {
+		result = append(result, "num", "address")
+	}
+
+	return result
+}
+
+func (s *TimetableStore) stopTimeJoins(qb sq.SelectBuilder, filter *dataprovider.TimetableFilter) sq.SelectBuilder {
+	qb = qb.Join("route ON stop_time.bus_id = route.bus_id AND stop_time.direction = route.direction AND " +
+		"stop_time.variant = route.variant AND stop_time.step = route.step")
+	if filter.DetailedView {
+		qb = qb.Join("bus ON stop_time.bus_id = bus.id").
+			Join("stop ON route.stop_id = stop.id")
+	}
+
+	return qb
+}
+
+// GetTrips returns trips depend on received filters.
+func (s *TimetableStore) GetTrips(ctx context.Context, filter *dataprovider.TimetableFilter) ([]*model.Trip, error) {
+	cond, err := tripCond(filter)
+	if err != nil {
+		return nil, err
+	}
+
+	qb := sq.
+		Select(
+			"id",
+			"bus_id",
+			"direction",
+			"variant",
+			"start_time",
+			"days",
+		).
+		From(s.tripTable).
+		Where(cond).
+		OrderBy("bus_id", "direction", "variant", "start_time")
+
+	query, args, err := qb.ToSql()
+	if err != nil {
+		return nil, err
+	}
+
+	message := "select " + s.tripTable + " by filter with query " + query
+
+	var result = make([]*model.Trip, 0)
+	if err := sqlx.SelectContext(ctx, s.db, &result, query, args...); err != nil {
+		return nil, errors.Wrapf(err, message)
+	}
+
+	return result, nil
+}
+
+// GetStopTimes returns stop times depend on received filters.
+func (s *TimetableStore) GetStopTimes(ctx context.Context, filter *dataprovider.TimetableFilter) ([]*model.StopTime, error) {
+	qb := sq.
+		Select(s.stopTimeColumns(filter)...).
+		From(s.stopTimeTable).
+		Where(stopTimeCond(filter)).
+		OrderBy("stop_time.bus_id", "stop_time.direction", "stop_time.variant", "stop_time.step")
+
+	qb = s.stopTimeJoins(qb, filter)
+
+	query, args, err := qb.ToSql()
+	if err != nil {
+		return nil, err
+	}
+
+	message := "select " + s.stopTimeTable + " by filter with query " + query
+
+	var result = make([]*model.StopTime, 0)
+	if err := sqlx.SelectContext(ctx, s.db, &result, query, args...); err != nil {
+		return nil, errors.Wrapf(err, message)
+	}
+
+	return result, nil
+}
+
+// Set replaces the stop times and the trips of the bus route pattern.
+func (s *TimetableStore) Set(
+	ctx context.Context,
+	pattern model.RoutePattern,
+	stopTimes []*model.StopTime,
+	trips []*model.Trip,
+) error {
+	f := func(tx *dataprovider.Tx) error {
+		where := sq.Eq{
+			"bus_id":    pattern.BusID,
+			"direction": pattern.Direction,
+			"variant":   pattern.Variant,
+		}
+
+		qb := sq.Delete(s.stopTimeTable).Where(where)
+		if err := execContext(ctx, qb, s.stopTimeTable, tx); err != nil && err != errNoRowsAffected {
+			return err
+		}
+
+		qb = sq.Delete(s.tripTable).Where(where)
+		if err := execContext(ctx, qb, s.tripTable, tx); err != nil && err != errNoRowsAffected {
+			return err
+		}
+
+		if len(stopTimes) > 0 {
+			qb := sq.Insert(s.stopTimeTable).Columns("bus_id", "direction", "variant", "step", "arrival", "departure")
+			for _, stopTime := range stopTimes {
+				qb = qb.Values(
+					pattern.BusID, pattern.Direction, pattern.Variant,
+					stopTime.Step, stopTime.Arrival, stopTime.Departure,
+				)
+			}
+
+			if err := execContext(ctx, qb, s.stopTimeTable, tx); err != nil {
+				return err
+			}
+		}
+
+		if len(trips) > 0 {
+			qb := sq.Insert(s.tripTable).Columns("bus_id", "direction", "variant", "start_time", "days")
+			for _, trip := range trips {
+				qb = qb.Values(pattern.BusID, pattern.Direction, pattern.Variant, trip.StartTime, trip.Days)
+			}
+
+			if err := execContext(ctx, qb, s.tripTable, tx); err != nil {
+				return err
+			}
+		}
+
+		return addEvents(ctx, tx, model.EventTimetableReplaced, model.NewRouteEvent(pattern))
+	}
+
+	return inTx(ctx, s.txer, s.tx, f)
+}
+
+// deleteStopTimes deletes the stop times of the buses, which are deleted with their routes,
+// as the stop times restrict the deletion of the routes.
+func deleteStopTimes(ctx context.Context, tx *dataprovider.Tx, busIDs ...int64) error {
+	if len(busIDs) == 0 {
+		return nil
+	}
+
+	qb := sq.Delete("stop_time").Where(sq.Eq{"bus_id": busIDs})
+	if err := execContext(ctx, qb, "stop_time", tx); err != nil && err != errNoRowsAffected {
+		return err
+	}
+
+	return nil
+}
@@ -0,0 +1,232 @@
+package mysql
+
+import (
+	"context"
+
+	"github.com/gxravel/bus-routes/internal/dataprovider"
+	"github.com/gxravel/bus-routes/internal/model"
+
+	sq "github.com/Masterminds/squirrel"
+	"github.com/jmoiron/sqlx"
+	"github.com/pkg/errors"
+)
+
+// TOTPStore is user totp mysql store.
+type TOTPStore struct {
+	db                sqlx.ExtContext
+	txer              dataprovider.Txer
+	tx                *dataprovider.Tx
+	tableName         string
+	recoveryCodeTable string
+}
+
+// NewTOTPStore creates new instance of TOTPStore.
+func NewTOTPStore(db sqlx.ExtContext, txer dataprovider.Txer) *TOTPStore {
+	return &TOTPStore{
+		db:                db,
+		txer:              txer,
+		tableName:         "user_totp",
+		recoveryCodeTable: "user_recovery_code",
+	}
+}
+
+// WithTx sets transaction as active connection.
+func (s *TOTPStore) WithTx(tx *dataprovider.Tx) dataprovider.TOTPStore {
+	return &TOTPStore{
+		db:                tx,
+		txer:              s.txer,
+		tx:                tx,
+		tableName:         s.tableName,
+		recoveryCodeTable: s.recoveryCodeTable,
+	}
+}
+
+func totpCond(f *dataprovider.TOTPFilter) sq.Sqlizer {
+	eq := make(sq.Eq)
+	var cond sq.Sqlizer = eq
+
+	if len(f.UserIDs) > 0 {
+		eq["user_id"] = f.UserIDs
+	}
+	if f.Enabled != nil {
+		eq["enabled"] = *f.Enabled
+	}
+
+	return cond
+}
+
+// GetByFilter returns user totp depend on received filters.
+func (s *TOTPStore) GetByFilter(ctx context.Context, filter *dataprovider.TOTPFilter) (*model.TOTP, error) {
+	totps, err := s.GetListByFilter(ctx, filter)
+
+	switch {
+	case err != nil:
+		return nil, err
+	case len(totps) == 0:
+		return nil, nil
+	case len(totps) == 1:
+		return totps[0], nil
+	default:
+		return nil, errors.New("fetched more than 1 user totp")
+	}
+}
+
+// GetListByFilter returns user totps depend on received filters.
+func (s *TOTPStore) GetListByFilter(ctx context.Context, filter *dataprovider.TOTPFilter) ([]*model.TOTP, error) {
+	qb := sq.
+		Select(
+			"user_id",
+			"secret",
+			"enabled",
+			"last_step",
+		).
+		From(s.tableName).
+		Where(totpCond(filter)).
+		OrderBy("user_id")
+
+	query, args, err := qb.ToSql()
+	if err != nil {
+		return nil, err
+	}
+
+	message := "select " + s.tableName + " by filter with query " + query
+
+	var result = make([]*model.TOTP, 0)
+	if err := sqlx.SelectContext(ctx, s.db, &result, query, args...); err != nil {
+		return nil, errors.Wrapf(err, message)
+	}
+
+	return result, nil
+}
+
+// Save creates the disabled user totp or replaces the secret of the existing one, disabling it.
+func (s *TOTPStore) Save(ctx context.Context, totp *model.TOTP) error {
+	qb := sq.Insert(s.tableName).
+		Columns("user_id", "secret").
+		Values(totp.UserID, totp.Secret).
+		Suffix("ON DUPLICATE KEY UPDATE secret = VALUES(secret), enabled = FALSE, last_step = 0")
+
+	return execContext(ctx, qb, s.tableName, s.db)
+}
+
+// Enable enables user totp confirmed by the code of the step.
+func (s *TOTPStore) Enable(ctx context.Context, userID, step int64) error {
+	qb := sq.Update(s.tableName).
+		SetMap(map[string]interface{}{
+			"enabled":   true,
+			"last_step": step,
+		}).
+		Where(sq.Eq{"user_id": userID})
+
+	return execContext(ctx, qb, s.tableName, s.db)
+}
+
+// UseStep sets the step of the accepted code, it returns false if the code of the step or a later one is used already.
+func (s *TOTPStore) UseStep(ctx context.Context, userID, step int64) (bool, error) {
+	qb := sq.Update(s.tableName).
+		Set("last_step", step).
+		Where(sq.And{
+			sq.Eq{"user_id": userID},
+			sq.Lt{"last_step": step},
+		})
+
+	err := execContext(ctx, qb, s.tableName, s.db)
+	switch {
+	case err == errNoRowsAffected:
+		return false, nil
+	case err != nil:
+		return false, err
+	}
+
+	return true, nil
+}
+
+// Delete deletes user totps depend on received filter, the recovery codes of the users are deleted as well.
+func (s *TOTPStore) Delete(ctx context.Context, filter *dataprovider.TOTPFilter) error {
+	f := func(tx *dataprovider.Tx) error {
+		// the recovery codes are kept only for the enabled totp, so there may be none.
+		qb := sq.Delete(s.recoveryCodeTable).Where(sq.Eq{"user_id": filter.UserIDs})
+
+		query, args, codewords, err := toSql(ctx, qb, s.recoveryCodeTable)
+		if err != nil {
+			return err
+		}
+
+		if _, err := tx.ExecContext(ctx, query, args...); err != nil {
+			return errors.Wrapf(err, codewords+" with query %s", query)
+		}
+
+		return execContext(ctx, sq.Delete(s.tableName).Where(totpCond(filter)), s.tableName, tx)
+	}
+
+	return inTx(ctx, s.txer, s.tx, f)
+}
+
+// SetRecoveryCodes replaces the recovery codes of the user.
+func (s *TOTPStore) SetRecoveryCodes(ctx context.Context, userID int64, hashedCodes ...[]byte) error {
+	f := func(tx *dataprovider.Tx) error {
+		// the user may have no recovery codes yet, so no rows affected is fine here.
+		qb := sq.Delete(s.recoveryCodeTable).Where(sq.Eq{"user_id": userID})
+
+		query, args, codewords, err := toSql(ctx, qb, s.recoveryCodeTable)
+		if err != nil {
+			return err
+		}
+
+		if _, err := tx.ExecContext(ctx, query, args...); err != nil {
+			return errors.Wrapf(err, codewords+" with query %s", query)
+		}
+
+		if len(hashedCodes) == 0 {
+			return nil
+		}
+
+		insert := sq.Insert(s.recoveryCodeTable).Columns("user_id", "hashed_code")
+		for _, hashedCode := range hashedCodes {
+			insert = insert.Values(userID, hashedCode)
+		}
+
+		return execContext(ctx, insert, s.recoveryCodeTable, tx)
+	}
+
+	return inTx(ctx, s.txer, s.tx, f)
+}
+
+// UseRecoveryCode deletes the recovery code of the user, it returns false if there is no such code.
+func (s *TOTPStore) UseRecoveryCode(ctx context.Context, userID int64, hashedCode []byte) (bool, error) {
+	qb := sq.Delete(s.recoveryCodeTable).
+		Where(sq.Eq{
+			"user_id":     userID,
+			"hashed_code": hashedCode,
+		})
+
+	err := execContext(ctx, qb, s.recoveryCodeTable, s.db)
+	switch {
+	case err == errNoRowsAffected:
+		return false, nil
+	case err != nil:
+		return false, err
+	}
+
+	return true, nil
+}
+
+// CountRecoveryCodes returns the number of the recovery codes left to the user.
+func (s *TOTPStore) CountRecoveryCodes(ctx context.Context, userID int64) (int, error) {
+	qb := sq.
+		Select("COUNT(*)").
+		From(s.recoveryCodeTable).
+		Where(sq.Eq{"user_id": userID})
+
+	query, args, err := qb.ToSql()
+	if err != nil {
+		return 0, err
+	}
+
+	var count int
+	if err := sqlx.GetContext(ctx, s.db, &count, query, args...); err != nil {
+		return 0, errors.Wrapf(err, "count "+s.recoveryCodeTable+" with query "+query)
+	}
+
+	return count, nil
+}
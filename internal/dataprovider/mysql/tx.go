@@ -1,6 +1,8 @@
 package mysql
 
 import (
+	"context"
+
 	"github.com/gxravel/bus-routes/internal/database"
 	"github.com/gxravel/bus-routes/internal/dataprovider"
 
@@ -25,3 +27,12 @@ func (txm *TxManager) New() (*dataprovider.Tx, error) {
 
 	return &dataprovider.Tx{Tx: sqltx}, nil
 }
+
+// inTx runs f in the active transaction if there is one, otherwise in the new auto-commited transaction.
+func inTx(ctx context.Context, txer dataprovider.Txer, tx *dataprovider.Tx, f func(*dataprovider.Tx) error) error {
+	if tx != nil {
+		return f(tx)
+	}
+
+	return dataprovider.BeginAutoCommitedTx(ctx, txer, f)
+}
@@ -15,6 +15,7 @@ import (
 type UserStore struct {
 	db        sqlx.ExtContext
 	txer      dataprovider.Txer
+	tx        *dataprovider.Tx
 	tableName string
 }
 
@@ -31,6 +32,8 @@ func NewUserStore(db sqlx.ExtContext, txer dataprovider.Txer) *UserStore {
 func (s *UserStore) WithTx(tx *dataprovider.Tx) dataprovider.UserStore {
 	return &UserStore{
 		db:        tx,
+		txer:      s.txer,
+		tx:        tx,
 		tableName: s.tableName,
 	}
 }
@@ -45,6 +48,9 @@ func userCond(f *dataprovider.UserFilter) sq.Sqlizer {
 	if len(f.Emails) > 0 {
 		eq["email"] = f.Emails
 	}
+	if len(f.Types) > 0 {
+		eq["type"] = f.Types
+	}
 
 	return cond
 }
@@ -72,6 +78,7 @@ func (s *UserStore) columns(filter *dataprovider.UserFilter) []string {
 			"id",
 			"email",
 			"type",
+			"verified",
 		}
 	}
 
@@ -99,7 +106,12 @@ func (s *UserStore) GetListByFilter(ctx context.Context, filter *dataprovider.Us
 	qb := sq.
 		Select(s.columns(filter)...).
 		From(s.tableName).
-		Where(userCond(filter))
+		Where(userCond(filter)).
+		OrderBy("id")
+
+	if filter.Paginator != nil {
+		qb = withPaginator(qb, filter.Paginator)
+	}
 
 	query, args, err := qb.ToSql()
 	if err != nil {
@@ -167,3 +179,70 @@ func (s *UserStore) UpdatePassword(ctx context.Context, hashedPassword []byte, f
 
 	return execContext(ctx, qb, s.tableName, s.db)
 }
+
+// UpdateVerified updates user's verified.
+func (s *UserStore) UpdateVerified(ctx context.Context, verified bool, filter *dataprovider.UserFilter) error {
+	qb := sq.Update(s.tableName).
+		Set("verified", verified).
+		Where(userCond(filter))
+
+	return execContext(ctx, qb, s.tableName, s.db)
+}
+
+// GetCities returns the city scopes of the users ordered by user_id and city name.
+func (s *UserStore) GetCities(ctx context.Context, userIDs ...int64) ([]*model.UserCity, error) {
+	qb := sq.
+		Select(
+			"user_id",
+			"city_id",
+			"city.name as city",
+		).
+		From("user_city").
+		Join("city ON user_city.city_id = city.id").
+		Where(sq.Eq{"user_id": userIDs}).
+		OrderBy("user_id", "city.name")
+
+	query, args, err := qb.ToSql()
+	if err != nil {
+		return nil, err
+	}
+
+	message := "select user_city by user ids with query " + query
+
+	var result = make([]*model.UserCity, 0)
+	if err := sqlx.SelectContext(ctx, s.db, &result, query, args...); err != nil {
+		return nil, errors.Wrapf(err, message)
+	}
+
+	return result, nil
+}
+
+// SetCities replaces the city scopes of the user.
+func (s *UserStore) SetCities(ctx context.Context, userID int64, citiesIDs ...int) error {
+	f := func(tx *dataprovider.Tx) error {
+		// the user may have no cities yet, so no rows affected is fine here.
+		qb := sq.Delete("user_city").Where(sq.Eq{"user_id": userID})
+
+		query, args, codewords, err := toSql(ctx, qb, "user_city")
+		if err != nil {
+			return err
+		}
+
+		if _, err := tx.ExecContext(ctx, query, args...); err != nil {
+			return errors.Wrapf(err, codewords+" with query %s", query)
+		}
+
+		if len(citiesIDs) == 0 {
+			return nil
+		}
+
+		insert := sq.Insert("user_city").Columns("user_id", "city_id")
+		for _, cityID := range citiesIDs {
+			insert = insert.Values(userID, cityID)
+		}
+
+		return execContext(ctx, insert, "user_city", tx)
+	}
+
+	return inTx(ctx, s.txer, s.tx, f)
+}
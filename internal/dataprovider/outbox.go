@@ -0,0 +1,46 @@
+package dataprovider
+
+import (
+	"context"
+	"time"
+
+	"github.com/gxravel/bus-routes/internal/model"
+)
+
+// OutboxStore keeps the events written by the mutations of the other stores until they are published.
+type OutboxStore interface {
+	WithTx(*Tx) OutboxStore
+	GetListByFilter(ctx context.Context, filter *OutboxFilter) ([]*model.Event, error)
+	Claim(ctx context.Context, timeout time.Duration, ids ...int64) error
+	Release(ctx context.Context, ids ...int64) error
+	Delete(ctx context.Context, ids ...int64) error
+}
+
+type OutboxFilter struct {
+	Limit     uint64
+	Unclaimed bool
+	ForUpdate bool
+}
+
+func NewOutboxFilter() *OutboxFilter {
+	return &OutboxFilter{}
+}
+
+// WithLimit limits the number of the events, the oldest are selected first.
+func (f *OutboxFilter) WithLimit(limit uint64) *OutboxFilter {
+	f.Limit = limit
+	return f
+}
+
+// OnlyUnclaimed selects the events not claimed by any relay or whose claim has expired.
+func (f *OutboxFilter) OnlyUnclaimed() *OutboxFilter {
+	f.Unclaimed = true
+	return f
+}
+
+// LockForUpdate locks the selected events until the end of the transaction,
+// the events locked by the other transactions are skipped.
+func (f *OutboxFilter) LockForUpdate() *OutboxFilter {
+	f.ForUpdate = true
+	return f
+}
@@ -13,13 +13,20 @@ type RouteStore interface {
 	Add(ctx context.Context, routes ...*model.Route) error
 	Update(ctx context.Context, route *model.Route) error
 	Delete(ctx context.Context, filter *RouteFilter) error
+	Replace(ctx context.Context, pattern model.RoutePattern, routes []*model.Route) error
+	ShiftSteps(ctx context.Context, pattern model.RoutePattern, from, delta int) error
+	Renumber(ctx context.Context, pattern model.RoutePattern, steps map[int]int) error
 }
 
 type RouteFilter struct {
 	BusIDs       []int64
+	Directions   []model.Direction
+	Variants     []string
 	StopIDs      []int64
-	Steps        []int8
+	Steps        []int
+	Cities       []string
 	DetailedView bool
+	ForUpdate    bool
 }
 
 func NewRouteFilter() *RouteFilter {
@@ -32,6 +39,26 @@ func (f *RouteFilter) ByBusIDs(ids ...int64) *RouteFilter {
 	return f
 }
 
+// ByDirections filters by route.direction.
+func (f *RouteFilter) ByDirections(directions ...model.Direction) *RouteFilter {
+	f.Directions = directions
+	return f
+}
+
+// ByVariants filters by route.variant, the empty variant is the main one.
+func (f *RouteFilter) ByVariants(variants ...string) *RouteFilter {
+	f.Variants = variants
+	return f
+}
+
+// ByPattern filters by route.bus_id, route.direction and route.variant.
+func (f *RouteFilter) ByPattern(pattern model.RoutePattern) *RouteFilter {
+	return f.
+		ByBusIDs(pattern.BusID).
+		ByDirections(pattern.Direction).
+		ByVariants(pattern.Variant)
+}
+
 // ByStopIDs filters by route.stop_id.
 func (f *RouteFilter) ByStopIDs(ids ...int64) *RouteFilter {
 	f.StopIDs = ids
@@ -39,13 +66,25 @@ func (f *RouteFilter) ByStopIDs(ids ...int64) *RouteFilter {
 }
 
 // BySteps filters by route.step.
-func (f *RouteFilter) BySteps(steps ...int8) *RouteFilter {
+func (f *RouteFilter) BySteps(steps ...int) *RouteFilter {
 	f.Steps = steps
 	return f
 }
 
+// ByCities filters by city.name, works only with the detailed view.
+func (f *RouteFilter) ByCities(cities ...string) *RouteFilter {
+	f.Cities = cities
+	return f
+}
+
 // ViewDetailed select joined values instead of ids.
 func (f *RouteFilter) ViewDetailed() *RouteFilter {
 	f.DetailedView = true
 	return f
 }
+
+// LockForUpdate locks the selected rows until the end of the transaction.
+func (f *RouteFilter) LockForUpdate() *RouteFilter {
+	f.ForUpdate = true
+	return f
+}
@@ -2,6 +2,7 @@ package dataprovider
 
 import (
 	"context"
+	"math"
 
 	"github.com/gxravel/bus-routes/internal/model"
 )
@@ -20,9 +21,33 @@ type StopFilter struct {
 	Cities      []string
 	CitiesIDs   []int
 	Addresses   []string
+	Location    *Location
 	DoPreferIDs bool
 }
 
+// Location describes a point and the radius around it in meters.
+type Location struct {
+	Lat    float64
+	Lon    float64
+	Radius float64
+}
+
+// metersInDegree is the length of a degree of latitude.
+const metersInDegree = 111320
+
+// Bounds returns the bounding box of the circle: min and max latitudes and longitudes.
+func (l *Location) Bounds() (minLat, minLon, maxLat, maxLon float64) {
+	dLat := l.Radius / metersInDegree
+
+	// near the poles the box covers all the longitudes.
+	dLon := 180.0
+	if cos := math.Cos(l.Lat * math.Pi / 180); cos > 0 {
+		dLon = math.Min(dLat/cos, 180)
+	}
+
+	return l.Lat - dLat, l.Lon - dLon, l.Lat + dLat, l.Lon + dLon
+}
+
 func NewStopFilter() *StopFilter {
 	return &StopFilter{}
 }
@@ -51,6 +76,16 @@ func (f *StopFilter) ByAddresses(addresses ...string) *StopFilter {
 	return f
 }
 
+// Near filters by stop.lat and stop.lon within the bounding box of the radius around the point.
+func (f *StopFilter) Near(lat, lon, radius float64) *StopFilter {
+	f.Location = &Location{
+		Lat:    lat,
+		Lon:    lon,
+		Radius: radius,
+	}
+	return f
+}
+
 // PreferIDs select ids instead of joined values.
 func (f *StopFilter) PreferIDs() *StopFilter {
 	f.DoPreferIDs = true
@@ -0,0 +1,42 @@
+package dataprovider
+
+import (
+	"context"
+
+	"github.com/gxravel/bus-routes/internal/model"
+)
+
+type TimetableStore interface {
+	WithTx(*Tx) TimetableStore
+	GetTrips(ctx context.Context, filter *TimetableFilter) ([]*model.Trip, error)
+	GetStopTimes(ctx context.Context, filter *TimetableFilter) ([]*model.StopTime, error)
+	Set(ctx context.Context, pattern model.RoutePattern, stopTimes []*model.StopTime, trips []*model.Trip) error
+}
+
+type TimetableFilter struct {
+	BusIDs       []int64
+	StopIDs      []int64
+	DetailedView bool
+}
+
+func NewTimetableFilter() *TimetableFilter {
+	return &TimetableFilter{}
+}
+
+// ByBusIDs filters by trip.bus_id and stop_time.bus_id.
+func (f *TimetableFilter) ByBusIDs(ids ...int64) *TimetableFilter {
+	f.BusIDs = ids
+	return f
+}
+
+// ByStopIDs filters by route.stop_id: the trips of the buses passing the stops and their stop times.
+func (f *TimetableFilter) ByStopIDs(ids ...int64) *TimetableFilter {
+	f.StopIDs = ids
+	return f
+}
+
+// ViewDetailed selects the bus number and the stop address with the stop times.
+func (f *TimetableFilter) ViewDetailed() *TimetableFilter {
+	f.DetailedView = true
+	return f
+}
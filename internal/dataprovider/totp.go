@@ -0,0 +1,41 @@
+package dataprovider
+
+import (
+	"context"
+
+	"github.com/gxravel/bus-routes/internal/model"
+)
+
+type TOTPStore interface {
+	WithTx(*Tx) TOTPStore
+	GetByFilter(ctx context.Context, filter *TOTPFilter) (*model.TOTP, error)
+	GetListByFilter(ctx context.Context, filter *TOTPFilter) ([]*model.TOTP, error)
+	Save(ctx context.Context, totp *model.TOTP) error
+	Enable(ctx context.Context, userID, step int64) error
+	UseStep(ctx context.Context, userID, step int64) (bool, error)
+	Delete(ctx context.Context, filter *TOTPFilter) error
+	SetRecoveryCodes(ctx context.Context, userID int64, hashedCodes ...[]byte) error
+	UseRecoveryCode(ctx context.Context, userID int64, hashedCode []byte) (bool, error)
+	CountRecoveryCodes(ctx context.Context, userID int64) (int, error)
+}
+
+type TOTPFilter struct {
+	UserIDs []int64
+	Enabled *bool
+}
+
+func NewTOTPFilter() *TOTPFilter {
+	return &TOTPFilter{}
+}
+
+// ByUserIDs filters by user_totp.user_id.
+func (f *TOTPFilter) ByUserIDs(ids ...int64) *TOTPFilter {
+	f.UserIDs = ids
+	return f
+}
+
+// ByEnabled filters by user_totp.enabled.
+func (f *TOTPFilter) ByEnabled(enabled bool) *TOTPFilter {
+	f.Enabled = &enabled
+	return f
+}
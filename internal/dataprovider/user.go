@@ -14,13 +14,18 @@ type UserStore interface {
 	Delete(ctx context.Context, filter *UserFilter) error
 	Update(ctx context.Context, user *model.User) error
 	UpdatePassword(ctx context.Context, hashedPassword []byte, filter *UserFilter) error
+	UpdateVerified(ctx context.Context, verified bool, filter *UserFilter) error
+	GetCities(ctx context.Context, userIDs ...int64) ([]*model.UserCity, error)
+	SetCities(ctx context.Context, userID int64, citiesIDs ...int) error
 }
 
 type UserFilter struct {
 	IDs              []int
 	Emails           []string
+	Types            []model.UserType
 	DoSelectPassword bool
 	DoSelectType     bool
+	Paginator        *Paginator
 }
 
 func NewUserFilter() *UserFilter {
@@ -39,6 +44,18 @@ func (f *UserFilter) ByEmails(emails ...string) *UserFilter {
 	return f
 }
 
+// ByTypes filters by user.type.
+func (f *UserFilter) ByTypes(types ...model.UserType) *UserFilter {
+	f.Types = types
+	return f
+}
+
+// WithPaginator adds pagination to filter.
+func (f *UserFilter) WithPaginator(paginator *Paginator) *UserFilter {
+	f.Paginator = paginator
+	return f
+}
+
 // SelectPassword selects user.hash_password.
 func (f *UserFilter) SelectPassword() *UserFilter {
 	f.DoSelectPassword = true
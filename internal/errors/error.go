@@ -89,3 +89,23 @@ func CheckDuplicate(err error, field string) error {
 
 	return nil
 }
+
+// CheckRestricted checks if the database error is the row, which can not be deleted as the other rows refer to it,
+// and return updated error, otherwise the error itself.
+func CheckRestricted(err error, message string) error {
+	if strings.Contains(err.Error(), "Cannot delete or update a parent row") {
+		return NewReason(ErrConflict).WithMessage(message)
+	}
+
+	return err
+}
+
+// CheckReferenced checks if the database error is the row, which can not be added as it refers to the missing row,
+// and return updated error, otherwise the error itself.
+func CheckReferenced(err error, message string) error {
+	if strings.Contains(err.Error(), "Cannot add or update a child row") {
+		return NewReason(ErrValidationFailed).WithMessage(message)
+	}
+
+	return err
+}
@@ -1,6 +1,9 @@
 package errors
 
-import "net/http"
+import (
+	"net/http"
+	"time"
+)
 
 type HTTPStatusCoder interface {
 	HTTPStatusCode() int
@@ -15,6 +18,8 @@ func (e BadRequestError) HTTPStatusCode() int { return http.StatusBadRequest }
 const (
 	ErrBadRequest  BadRequestError = "bad request"
 	ErrMustProvide BadRequestError = "must provide"
+
+	ErrUnsupportedGrantType BadRequestError = "unsupported grant type"
 )
 
 type ValidationError string
@@ -39,6 +44,7 @@ const (
 	ErrInvalidToken     AuthorizationError = "invalid token"
 	ErrInvalidJWT       AuthorizationError = "invalid JWT format"
 	ErrTokenExpired     AuthorizationError = "token expired"
+	ErrInvalidAPIKey    AuthorizationError = "invalid api key"
 )
 
 type ForbiddenError string
@@ -51,6 +57,49 @@ const (
 	ErrPermissionDenied ForbiddenError = "user does not have the permission"
 )
 
+type NotFoundError string
+
+func (e NotFoundError) Type() ReasonType    { return ReasonProcessingError }
+func (e NotFoundError) Error() string       { return string(e) }
+func (e NotFoundError) HTTPStatusCode() int { return http.StatusNotFound }
+
+const (
+	ErrNotFound NotFoundError = "not found"
+)
+
+type TooManyRequestsError string
+
+func (e TooManyRequestsError) Type() ReasonType    { return ReasonAuthError }
+func (e TooManyRequestsError) Error() string       { return string(e) }
+func (e TooManyRequestsError) HTTPStatusCode() int { return http.StatusTooManyRequests }
+
+const (
+	ErrTooManyAttempts TooManyRequestsError = "too many attempts"
+	ErrLockedOut       TooManyRequestsError = "temporarily locked out"
+	ErrTooManyEmails   TooManyRequestsError = "too many emails"
+)
+
+// RetryAfterer is the error of the request, which can be retried after the delay.
+type RetryAfterer interface {
+	RetryAfter() time.Duration
+}
+
+// RetryAfterError is TooManyRequestsError with the delay to retry the request after.
+type RetryAfterError struct {
+	TooManyRequestsError
+	Delay time.Duration
+}
+
+// NewRetryAfter creates new instance of RetryAfterError.
+func NewRetryAfter(err TooManyRequestsError, delay time.Duration) *RetryAfterError {
+	return &RetryAfterError{
+		TooManyRequestsError: err,
+		Delay:                delay,
+	}
+}
+
+func (e *RetryAfterError) RetryAfter() time.Duration { return e.Delay }
+
 type ConflictError string
 
 func (e ConflictError) Type() ReasonType    { return ReasonProcessingError }
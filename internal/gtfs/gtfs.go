@@ -0,0 +1,93 @@
+// Package gtfs reads and writes the parts of GTFS static feeds used by the service.
+// See https://developers.google.com/transit/gtfs/reference.
+package gtfs
+
+// Feed describes the GTFS static feed.
+type Feed struct {
+	Agencies  []Agency
+	Stops     []Stop
+	Routes    []Route
+	Trips     []Trip
+	StopTimes []StopTime
+	Calendar  []Calendar
+}
+
+// Agency describes a record of agency.txt.
+type Agency struct {
+	ID       string
+	Name     string
+	URL      string
+	Timezone string
+}
+
+// Stop describes a record of stops.txt.
+type Stop struct {
+	ID           string
+	Name         string
+	Lat          float64
+	Lon          float64
+	LocationType int
+}
+
+// Route describes a record of routes.txt.
+type Route struct {
+	ID        string
+	AgencyID  string
+	ShortName string
+	LongName  string
+	Type      int
+}
+
+// Trip describes a record of trips.txt.
+type Trip struct {
+	ID          string
+	RouteID     string
+	ServiceID   string
+	DirectionID int
+}
+
+// StopTime describes a record of stop_times.txt.
+// ArrivalTime and DepartureTime are the numbers of seconds since the start of the service day, -1 if not set.
+type StopTime struct {
+	TripID        string
+	ArrivalTime   int
+	DepartureTime int
+	StopID        string
+	StopSequence  int
+}
+
+// Calendar describes a record of calendar.txt.
+type Calendar struct {
+	ServiceID string
+	// Days are the service days, sunday is the first as in time.Weekday.
+	Days      [7]bool
+	StartDate string
+	EndDate   string
+}
+
+// Location types of stops.txt.
+const (
+	LocationStop    = 0
+	LocationStation = 1
+)
+
+// Directions of trips.txt.
+const (
+	DirectionOutbound = 0
+	DirectionInbound  = 1
+)
+
+// RouteTypeBus is the route_type of bus service.
+const RouteTypeBus = 3
+
+// Files of the feed.
+const (
+	FileAgency    = "agency.txt"
+	FileStops     = "stops.txt"
+	FileRoutes    = "routes.txt"
+	FileTrips     = "trips.txt"
+	FileStopTimes = "stop_times.txt"
+	FileCalendar  = "calendar.txt"
+)
+
+var calendarDays = [7]string{"sunday", "monday", "tuesday", "wednesday", "thursday", "friday", "saturday"}
@@ -0,0 +1,280 @@
+package gtfs
+
+import (
+	"archive/zip"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// record is a csv row accessed by the column names.
+type record struct {
+	columns map[string]int
+	values  []string
+}
+
+func (r record) get(column string) string {
+	i, ok := r.columns[column]
+	if !ok || i >= len(r.values) {
+		return ""
+	}
+
+	return strings.TrimSpace(r.values[i])
+}
+
+func (r record) getInt(column string) (int, error) {
+	value := r.get(column)
+	if value == "" {
+		return 0, nil
+	}
+
+	return strconv.Atoi(value)
+}
+
+func (r record) getFloat(column string) (float64, error) {
+	value := r.get(column)
+	if value == "" {
+		return 0, nil
+	}
+
+	return strconv.ParseFloat(value, 64)
+}
+
+// Read reads the feed from the zip archive.
+// agency.txt, stops.txt, routes.txt, trips.txt and stop_times.txt are required.
+func Read(r io.ReaderAt, size int64) (*Feed, error) {
+	archive, err := zip.NewReader(r, size)
+	if err != nil {
+		return nil, errors.Wrap(err, "open gtfs zip")
+	}
+
+	var files = make(map[string]*zip.File, len(archive.File))
+	for _, file := range archive.File {
+		// some feeds are zipped with the enclosing directory.
+		name := file.Name[strings.LastIndex(file.Name, "/")+1:]
+		files[name] = file
+	}
+
+	var feed = &Feed{}
+
+	readers := []struct {
+		name     string
+		required bool
+		read     func(record) error
+	}{
+		{FileAgency, true, feed.readAgency},
+		{FileStops, true, feed.readStop},
+		{FileRoutes, true, feed.readRoute},
+		{FileTrips, true, feed.readTrip},
+		{FileStopTimes, true, feed.readStopTime},
+		{FileCalendar, false, feed.readCalendar},
+	}
+
+	for _, reader := range readers {
+		file, ok := files[reader.name]
+		if !ok {
+			if reader.required {
+				return nil, errors.Errorf("gtfs feed does not contain %s", reader.name)
+			}
+			continue
+		}
+
+		if err := readFile(file, reader.read); err != nil {
+			return nil, errors.Wrapf(err, "read %s", reader.name)
+		}
+	}
+
+	return feed, nil
+}
+
+func readFile(file *zip.File, read func(record) error) error {
+	rc, err := file.Open()
+	if err != nil {
+		return err
+	}
+	defer rc.Close()
+
+	reader := csv.NewReader(rc)
+	reader.FieldsPerRecord = -1
+
+	header, err := reader.Read()
+	if err == io.EOF {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	var columns = make(map[string]int, len(header))
+	for i, column := range header {
+		if i == 0 {
+			column = strings.TrimPrefix(column, "\ufeff")
+		}
+		columns[strings.TrimSpace(column)] = i
+	}
+
+	for line := 2; ; line++ {
+		values, err := reader.Read()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		if err := read(record{columns: columns, values: values}); err != nil {
+			return errors.Wrapf(err, "line %d", line)
+		}
+	}
+}
+
+func (f *Feed) readAgency(r record) error {
+	f.Agencies = append(f.Agencies, Agency{
+		ID:       r.get("agency_id"),
+		Name:     r.get("agency_name"),
+		URL:      r.get("agency_url"),
+		Timezone: r.get("agency_timezone"),
+	})
+
+	return nil
+}
+
+func (f *Feed) readStop(r record) error {
+	lat, err := r.getFloat("stop_lat")
+	if err != nil {
+		return err
+	}
+
+	lon, err := r.getFloat("stop_lon")
+	if err != nil {
+		return err
+	}
+
+	locationType, err := r.getInt("location_type")
+	if err != nil {
+		return err
+	}
+
+	f.Stops = append(f.Stops, Stop{
+		ID:           r.get("stop_id"),
+		Name:         r.get("stop_name"),
+		Lat:          lat,
+		Lon:          lon,
+		LocationType: locationType,
+	})
+
+	return nil
+}
+
+func (f *Feed) readRoute(r record) error {
+	routeType, err := r.getInt("route_type")
+	if err != nil {
+		return err
+	}
+
+	f.Routes = append(f.Routes, Route{
+		ID:        r.get("route_id"),
+		AgencyID:  r.get("agency_id"),
+		ShortName: r.get("route_short_name"),
+		LongName:  r.get("route_long_name"),
+		Type:      routeType,
+	})
+
+	return nil
+}
+
+func (f *Feed) readTrip(r record) error {
+	directionID, err := r.getInt("direction_id")
+	if err != nil {
+		return err
+	}
+
+	f.Trips = append(f.Trips, Trip{
+		ID:          r.get("trip_id"),
+		RouteID:     r.get("route_id"),
+		ServiceID:   r.get("service_id"),
+		DirectionID: directionID,
+	})
+
+	return nil
+}
+
+func (f *Feed) readStopTime(r record) error {
+	arrival, err := ParseTime(r.get("arrival_time"))
+	if err != nil {
+		return err
+	}
+
+	departure, err := ParseTime(r.get("departure_time"))
+	if err != nil {
+		return err
+	}
+
+	sequence, err := r.getInt("stop_sequence")
+	if err != nil {
+		return err
+	}
+
+	f.StopTimes = append(f.StopTimes, StopTime{
+		TripID:        r.get("trip_id"),
+		ArrivalTime:   arrival,
+		DepartureTime: departure,
+		StopID:        r.get("stop_id"),
+		StopSequence:  sequence,
+	})
+
+	return nil
+}
+
+func (f *Feed) readCalendar(r record) error {
+	calendar := Calendar{
+		ServiceID: r.get("service_id"),
+		StartDate: r.get("start_date"),
+		EndDate:   r.get("end_date"),
+	}
+
+	for day, column := range calendarDays {
+		calendar.Days[day] = r.get(column) == "1"
+	}
+
+	f.Calendar = append(f.Calendar, calendar)
+
+	return nil
+}
+
+// ParseTime parses GTFS time in format HH:MM:SS to the number of seconds.
+// Hours may exceed 23 for the trips ending after midnight. Empty time is parsed to -1.
+func ParseTime(value string) (int, error) {
+	if value == "" {
+		return -1, nil
+	}
+
+	parts := strings.Split(value, ":")
+	if len(parts) != 3 {
+		return 0, errors.Errorf("invalid time %q", value)
+	}
+
+	var seconds int
+	for _, part := range parts {
+		n, err := strconv.Atoi(part)
+		if err != nil || n < 0 {
+			return 0, errors.Errorf("invalid time %q", value)
+		}
+		seconds = seconds*60 + n
+	}
+
+	return seconds, nil
+}
+
+// FormatTime formats the number of seconds as GTFS time in format HH:MM:SS. Negative time is formatted as empty.
+func FormatTime(seconds int) string {
+	if seconds < 0 {
+		return ""
+	}
+
+	return fmt.Sprintf("%02d:%02d:%02d", seconds/3600, seconds/60%60, seconds%60)
+}
@@ -0,0 +1,188 @@
+package gtfs
+
+import (
+	"archive/zip"
+	"bytes"
+	"strings"
+	"testing"
+)
+
+// feedFiles are the minimal valid files of the feed.
+var feedFiles = map[string]string{
+	FileAgency:    "agency_id,agency_name,agency_url,agency_timezone\n1,City Transit,https://transit.example,Europe/Moscow\n",
+	FileStops:     "stop_id,stop_name,stop_lat,stop_lon,location_type\ns1,Central,55.75,37.61,0\ns2,Park,55.76,37.62,\n",
+	FileRoutes:    "route_id,agency_id,route_short_name,route_long_name,route_type\nr1,1,42,Central - Park,3\n",
+	FileTrips:     "route_id,service_id,trip_id,direction_id\nr1,weekdays,t1,1\n",
+	FileStopTimes: "trip_id,arrival_time,departure_time,stop_id,stop_sequence\nt1,08:00:00,08:00:00,s1,1\nt1,,,s2,2\n",
+	FileCalendar:  "service_id,monday,tuesday,wednesday,thursday,friday,saturday,sunday,start_date,end_date\nweekdays,1,1,1,1,1,0,0,20260101,20261231\n",
+}
+
+func TestRead(t *testing.T) {
+	tests := []struct {
+		name string
+		// files replace the files of feedFiles, the empty content removes the file.
+		files map[string]string
+		// prefix is the directory the files are zipped with.
+		prefix  string
+		wantErr string
+		check   func(t *testing.T, feed *Feed)
+	}{
+		{
+			name: "valid feed",
+			check: func(t *testing.T, feed *Feed) {
+				if len(feed.Agencies) != 1 || feed.Agencies[0].Timezone != "Europe/Moscow" {
+					t.Errorf("agencies %+v", feed.Agencies)
+				}
+				if len(feed.Stops) != 2 || feed.Stops[0].Lat != 55.75 || feed.Stops[1].LocationType != LocationStop {
+					t.Errorf("stops %+v", feed.Stops)
+				}
+				if len(feed.Routes) != 1 || feed.Routes[0].Type != RouteTypeBus {
+					t.Errorf("routes %+v", feed.Routes)
+				}
+				if len(feed.Trips) != 1 || feed.Trips[0].DirectionID != DirectionInbound {
+					t.Errorf("trips %+v", feed.Trips)
+				}
+				if len(feed.StopTimes) != 2 || feed.StopTimes[0].DepartureTime != 8*3600 || feed.StopTimes[1].ArrivalTime != -1 {
+					t.Errorf("stop times %+v", feed.StopTimes)
+				}
+				if len(feed.Calendar) != 1 || !feed.Calendar[0].Days[1] || feed.Calendar[0].Days[0] {
+					t.Errorf("calendar %+v", feed.Calendar)
+				}
+			},
+		},
+		{
+			name:   "zipped with directory",
+			prefix: "feed/",
+			check: func(t *testing.T, feed *Feed) {
+				if len(feed.Stops) != 2 {
+					t.Errorf("stops %+v", feed.Stops)
+				}
+			},
+		},
+		{
+			name: "header with byte order mark and spaces",
+			files: map[string]string{
+				FileRoutes: "\ufeffroute_id, agency_id ,route_short_name,route_type\n r1 ,1,42,3\n",
+			},
+			check: func(t *testing.T, feed *Feed) {
+				if len(feed.Routes) != 1 || feed.Routes[0].ID != "r1" || feed.Routes[0].AgencyID != "1" {
+					t.Errorf("routes %+v", feed.Routes)
+				}
+			},
+		},
+		{
+			name:  "without optional calendar",
+			files: map[string]string{FileCalendar: ""},
+			check: func(t *testing.T, feed *Feed) {
+				if len(feed.Calendar) != 0 {
+					t.Errorf("calendar %+v", feed.Calendar)
+				}
+			},
+		},
+		{
+			name:    "without required file",
+			files:   map[string]string{FileStopTimes: ""},
+			wantErr: "does not contain stop_times.txt",
+		},
+		{
+			name: "invalid number",
+			files: map[string]string{
+				FileStops: "stop_id,stop_name,stop_lat,stop_lon\ns1,Central,55.75,37.61\ns2,Park,north,37.62\n",
+			},
+			wantErr: "read stops.txt: line 3",
+		},
+		{
+			name: "invalid time",
+			files: map[string]string{
+				FileStopTimes: "trip_id,arrival_time,departure_time,stop_id,stop_sequence\nt1,8:00,8:00,s1,1\n",
+			},
+			wantErr: "read stop_times.txt: line 2",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var files = make(map[string]string, len(feedFiles))
+			for name, content := range feedFiles {
+				files[name] = content
+			}
+			for name, content := range tt.files {
+				files[name] = content
+			}
+
+			archive := zipFiles(t, tt.prefix, files)
+
+			feed, err := Read(bytes.NewReader(archive), int64(len(archive)))
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("Read() error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			tt.check(t, feed)
+		})
+	}
+}
+
+func TestParseTime(t *testing.T) {
+	tests := []struct {
+		value   string
+		want    int
+		wantErr bool
+	}{
+		{"", -1, false},
+		{"00:00:00", 0, false},
+		{"08:30:15", 8*3600 + 30*60 + 15, false},
+		{"7:05:00", 7*3600 + 5*60, false},
+		{"25:10:00", 25*3600 + 10*60, false},
+		{"08:30", 0, true},
+		{"08:-1:00", 0, true},
+		{"eight", 0, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.value, func(t *testing.T) {
+			got, err := ParseTime(tt.value)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseTime(%q) error = %v, want error %v", tt.value, err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("ParseTime(%q) = %d, want %d", tt.value, got, tt.want)
+			}
+		})
+	}
+}
+
+// zipFiles zips the files with the non-empty content.
+func zipFiles(t *testing.T, prefix string, files map[string]string) []byte {
+	t.Helper()
+
+	var (
+		buf bytes.Buffer
+		zw  = zip.NewWriter(&buf)
+	)
+
+	for name, content := range files {
+		if content == "" {
+			continue
+		}
+
+		fw, err := zw.Create(prefix + name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := fw.Write([]byte(content)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	return buf.Bytes()
+}
@@ -0,0 +1,139 @@
+package gtfs
+
+import (
+	"archive/zip"
+	"encoding/csv"
+	"io"
+	"strconv"
+
+	"github.com/pkg/errors"
+)
+
+var headers = map[string][]string{
+	FileAgency:    {"agency_id", "agency_name", "agency_url", "agency_timezone"},
+	FileStops:     {"stop_id", "stop_name", "stop_lat", "stop_lon", "location_type"},
+	FileRoutes:    {"route_id", "agency_id", "route_short_name", "route_long_name", "route_type"},
+	FileTrips:     {"route_id", "service_id", "trip_id", "direction_id"},
+	FileStopTimes: {"trip_id", "arrival_time", "departure_time", "stop_id", "stop_sequence"},
+	FileCalendar: {
+		"service_id",
+		"monday", "tuesday", "wednesday", "thursday", "friday", "saturday", "sunday",
+		"start_date", "end_date",
+	},
+}
+
+// Writer writes the feed to the zip archive file by file without buffering the whole feed.
+type Writer struct {
+	zw  *zip.Writer
+	csv *csv.Writer
+}
+
+// NewWriter creates new instance of Writer.
+func NewWriter(w io.Writer) *Writer {
+	return &Writer{zw: zip.NewWriter(w)}
+}
+
+// Start finishes the current file and starts the next one with its header.
+// The records written after Start must belong to the file.
+func (w *Writer) Start(file string) error {
+	header, ok := headers[file]
+	if !ok {
+		return errors.Errorf("unknown gtfs file %s", file)
+	}
+
+	if err := w.flush(); err != nil {
+		return err
+	}
+
+	fw, err := w.zw.Create(file)
+	if err != nil {
+		return errors.Wrapf(err, "create %s", file)
+	}
+
+	w.csv = csv.NewWriter(fw)
+
+	return w.csv.Write(header)
+}
+
+func (w *Writer) flush() error {
+	if w.csv == nil {
+		return nil
+	}
+
+	w.csv.Flush()
+
+	return w.csv.Error()
+}
+
+// Close finishes the current file and the archive.
+func (w *Writer) Close() error {
+	if err := w.flush(); err != nil {
+		return err
+	}
+
+	return w.zw.Close()
+}
+
+func (w *Writer) write(record ...string) error {
+	if w.csv == nil {
+		return errors.New("gtfs file is not started")
+	}
+
+	return w.csv.Write(record)
+}
+
+// WriteAgency writes the record of agency.txt.
+func (w *Writer) WriteAgency(a Agency) error {
+	return w.write(a.ID, a.Name, a.URL, a.Timezone)
+}
+
+// WriteStop writes the record of stops.txt.
+func (w *Writer) WriteStop(s Stop) error {
+	return w.write(
+		s.ID,
+		s.Name,
+		strconv.FormatFloat(s.Lat, 'f', -1, 64),
+		strconv.FormatFloat(s.Lon, 'f', -1, 64),
+		strconv.Itoa(s.LocationType),
+	)
+}
+
+// WriteRoute writes the record of routes.txt.
+func (w *Writer) WriteRoute(r Route) error {
+	return w.write(r.ID, r.AgencyID, r.ShortName, r.LongName, strconv.Itoa(r.Type))
+}
+
+// WriteTrip writes the record of trips.txt.
+func (w *Writer) WriteTrip(t Trip) error {
+	return w.write(t.RouteID, t.ServiceID, t.ID, strconv.Itoa(t.DirectionID))
+}
+
+// WriteStopTime writes the record of stop_times.txt.
+func (w *Writer) WriteStopTime(st StopTime) error {
+	return w.write(
+		st.TripID,
+		FormatTime(st.ArrivalTime),
+		FormatTime(st.DepartureTime),
+		st.StopID,
+		strconv.Itoa(st.StopSequence),
+	)
+}
+
+// WriteCalendar writes the record of calendar.txt.
+func (w *Writer) WriteCalendar(c Calendar) error {
+	var record = make([]string, 0, len(headers[FileCalendar]))
+	record = append(record, c.ServiceID)
+
+	// calendar.txt starts the week with monday.
+	for k := 1; k <= 7; k++ {
+		if c.Days[k%7] {
+			record = append(record, "1")
+		} else {
+			record = append(record, "0")
+		}
+	}
+
+	record = append(record, c.StartDate, c.EndDate)
+
+	return w.write(record...)
+}
@@ -0,0 +1,144 @@
+package gtfs
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+)
+
+func TestWriterRoundTrip(t *testing.T) {
+	want := &Feed{
+		Agencies: []Agency{{ID: "1", Name: "City Transit", URL: "https://transit.example", Timezone: "Europe/Moscow"}},
+		Stops: []Stop{
+			{ID: "s1", Name: "Central, north side", Lat: 55.75, Lon: 37.61},
+			{ID: "s2", Name: `"Park"`, Lat: -33.8688, Lon: 151.2093, LocationType: LocationStation},
+		},
+		Routes: []Route{{ID: "r1", AgencyID: "1", ShortName: "42", LongName: "Central - Park", Type: RouteTypeBus}},
+		Trips: []Trip{
+			{ID: "t1", RouteID: "r1", ServiceID: "weekdays", DirectionID: DirectionOutbound},
+			{ID: "t2", RouteID: "r1", ServiceID: "weekdays", DirectionID: DirectionInbound},
+		},
+		StopTimes: []StopTime{
+			{TripID: "t1", ArrivalTime: 23*3600 + 50*60, DepartureTime: 23*3600 + 50*60, StopID: "s1", StopSequence: 1},
+			{TripID: "t1", ArrivalTime: -1, DepartureTime: -1, StopID: "s2", StopSequence: 2},
+			{TripID: "t2", ArrivalTime: 24*3600 + 10*60, DepartureTime: 24*3600 + 15*60, StopID: "s2", StopSequence: 1},
+		},
+		Calendar: []Calendar{
+			{ServiceID: "weekdays", Days: [7]bool{false, true, true, true, true, true, false}, StartDate: "20260101", EndDate: "20261231"},
+			{ServiceID: "sundays", Days: [7]bool{true}, StartDate: "20260101", EndDate: "20261231"},
+		},
+	}
+
+	var buf bytes.Buffer
+	w := NewWriter(&buf)
+
+	files := []struct {
+		name  string
+		write func() error
+	}{
+		{FileAgency, func() error { return w.WriteAgency(want.Agencies[0]) }},
+		{FileStops, func() error {
+			for _, stop := range want.Stops {
+				if err := w.WriteStop(stop); err != nil {
+					return err
+				}
+			}
+			return nil
+		}},
+		{FileRoutes, func() error { return w.WriteRoute(want.Routes[0]) }},
+		{FileTrips, func() error {
+			for _, trip := range want.Trips {
+				if err := w.WriteTrip(trip); err != nil {
+					return err
+				}
+			}
+			return nil
+		}},
+		{FileStopTimes, func() error {
+			for _, stopTime := range want.StopTimes {
+				if err := w.WriteStopTime(stopTime); err != nil {
+					return err
+				}
+			}
+			return nil
+		}},
+		{FileCalendar, func() error {
+			for _, calendar := range want.Calendar {
+				if err := w.WriteCalendar(calendar); err != nil {
+					return err
+				}
+			}
+			return nil
+		}},
+	}
+
+	for _, file := range files {
+		if err := w.Start(file.name); err != nil {
+			t.Fatal(err)
+		}
+		if err := file.write(); err != nil {
+			t.Fatalf("write %s: %v", file.name, err)
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := Read(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %+v, want %+v", got, want)
+	}
+}
+
+func TestWriterErrors(t *testing.T) {
+	tests := []struct {
+		name  string
+		write func(w *Writer) error
+	}{
+		{
+			name:  "record before start",
+			write: func(w *Writer) error { return w.WriteAgency(Agency{ID: "1"}) },
+		},
+		{
+			name:  "unknown file",
+			write: func(w *Writer) error { return w.Start("shapes.txt") },
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			if err := tt.write(NewWriter(&buf)); err == nil {
+				t.Error("got no error")
+			}
+		})
+	}
+}
+
+func TestFormatTime(t *testing.T) {
+	tests := []struct {
+		seconds int
+		want    string
+	}{
+		{-1, ""},
+		{0, "00:00:00"},
+		{8*3600 + 30*60 + 15, "08:30:15"},
+		{25*3600 + 10*60, "25:10:00"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.want, func(t *testing.T) {
+			if got := FormatTime(tt.seconds); got != tt.want {
+				t.Errorf("FormatTime(%d) = %q, want %q", tt.seconds, got, tt.want)
+			}
+
+			if seconds, err := ParseTime(tt.want); err != nil || seconds != tt.seconds {
+				t.Errorf("ParseTime(%q) = %d, %v, want %d", tt.want, seconds, err, tt.seconds)
+			}
+		})
+	}
+}
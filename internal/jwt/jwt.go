@@ -3,6 +3,8 @@ package jwt
 import (
 	"context"
 	"fmt"
+	"sort"
+	"strconv"
 	"time"
 
 	"github.com/gxravel/bus-routes/internal/config"
@@ -12,30 +14,91 @@ import (
 	"github.com/gxravel/bus-routes/internal/storage"
 
 	"github.com/dgrijalva/jwt-go"
+	"github.com/go-redis/redis/v8"
 	uuid "github.com/satori/go.uuid"
 )
 
+// The storage keys of the refresh tokens and the token families.
+// The family is the chain of the token pairs rotated from the one issued on login.
+const (
+	refreshPrefix      = "refresh:"
+	familyPrefix       = "family:"
+	userFamiliesPrefix = "user_families:"
+)
+
+// The fields of the family hash, the family is the session of the user on one device.
+const (
+	familyAccess   = "access"
+	familyRefresh  = "refresh"
+	familyUser     = "user"
+	familyIssued   = "issued"
+	familyUsed     = "used"
+	familyAgent    = "agent"
+	familyClientIP = "ip"
+	familyAPIKey   = "api_key"
+)
+
+// touchScript sets the field of the existing hash, so the revoked family is not recreated.
+var touchScript = redis.NewScript(`
+if redis.call("EXISTS", KEYS[1]) == 1 then
+	return redis.call("HSET", KEYS[1], ARGV[1], ARGV[2])
+end
+return 0
+`)
+
 // Manager includes the methods allowed to deal with the token.
 type Manager interface {
-	save(ctx context.Context, token *Details) error
-
 	Parse(tokenString string) (*Claims, error)
 	CheckIfExists(ctx context.Context, tokenUUID string) error
 	Delete(ctx context.Context, tokenUUID string) error
-	SetNew(ctx context.Context, user *User) (*Details, error)
+	SetNew(ctx context.Context, user *User, client *Client) (*Pair, error)
+	SetRotated(ctx context.Context, user *User, family string) (*Pair, error)
+	SetAccess(ctx context.Context, user *User, client *Client) (*Details, error)
+	Rotate(ctx context.Context, refreshToken string) (*Claims, error)
+	Revoke(ctx context.Context, family string) error
+	RevokeAll(ctx context.Context, userID int64) error
+	Session(ctx context.Context, family string) (*Session, error)
+	Sessions(ctx context.Context, userID int64) ([]*Session, error)
 	Verify(ctx context.Context, tokenString string) (*User, error)
+	JWKS() *JWKS
 }
 
 // User describes user built into the token
 type User struct {
-	ID    int64          `json:"id"`
-	Email string         `json:"email"`
-	Type  model.UserType `json:"type"`
+	ID        int64          `json:"id"`
+	Email     string         `json:"email"`
+	Type      model.UserType `json:"type"`
+	Cities    []string       `json:"cities,omitempty"`
+	Verified  bool           `json:"verified,omitempty"`
+	TwoFactor bool           `json:"2fa,omitempty"`
+
+	// ClientID is the id of the OAuth 2.0 client the token is issued to, the token is limited to its Scopes.
+	ClientID string             `json:"client_id,omitempty"`
+	Scopes   []model.Permission `json:"scopes,omitempty"`
+}
+
+// Client describes the client the tokens are issued to, APIKeyID is set for the OAuth 2.0 client.
+type Client struct {
+	UserAgent string
+	IP        string
+	APIKeyID  int64
+}
+
+// Session describes the family of the tokens issued on login, the times are unix seconds.
+type Session struct {
+	ID        string
+	UserID    int64
+	IssuedAt  int64
+	LastUsed  int64
+	UserAgent string
+	IP        string
+	APIKeyID  int64
 }
 
 // Claims defines JWT token claims.
 type Claims struct {
-	User *User `json:"user"`
+	User   *User  `json:"user"`
+	Family string `json:"fam,omitempty"`
 	jwt.StandardClaims
 }
 
@@ -47,23 +110,45 @@ type Details struct {
 	Subject int64
 }
 
+// Pair defines the access token with the refresh token issued with it.
+type Pair struct {
+	Access  *Details
+	Refresh *Details
+}
+
 // JWT contains the fields which interact with the token.
 type JWT struct {
 	client *storage.Client
 	config config.Config
+
+	// keys sign the access tokens, the refresh tokens are verified only by us, so they are signed with the shared key.
+	keys    *keySet
+	refresh *signer
 }
 
-func New(client *storage.Client, config config.Config) *JWT {
-	return &JWT{client: client, config: config}
+// New loads the signing keys and creates new instance of JWT.
+func New(client *storage.Client, config config.Config) (*JWT, error) {
+	keys, err := newKeySet(config)
+	if err != nil {
+		return nil, err
+	}
+
+	return &JWT{
+		client:  client,
+		config:  config,
+		keys:    keys,
+		refresh: newHMACSigner(config.JWT.RefreshKey),
+	}, nil
 }
 
-// create creates the HS512 JWT token with claims.
-func create(ctx context.Context, user *User, expiry time.Duration, key string) (*Details, error) {
+// create creates the JWT token with claims signed by the key, the kid header is set if the key has the id.
+func create(ctx context.Context, user *User, family string, expiry time.Duration, key *signer) (*Details, error) {
 	now := time.Now()
 	token := &Details{}
 	token.Expiry = now.Add(expiry).Unix()
 	claims := &Claims{
-		User: user,
+		User:   user,
+		Family: family,
 		StandardClaims: jwt.StandardClaims{
 			Id:        uuid.NewV4().String(),
 			IssuedAt:  now.Unix(),
@@ -73,10 +158,13 @@ func create(ctx context.Context, user *User, expiry time.Duration, key string) (
 	token.UUID = claims.Id
 	token.Subject = claims.User.ID
 
-	jwtToken := jwt.NewWithClaims(jwt.SigningMethodHS512, claims)
+	jwtToken := jwt.NewWithClaims(key.method, claims)
+	if key.id != "" {
+		jwtToken.Header["kid"] = key.id
+	}
 
 	var err error
-	token.String, err = jwtToken.SignedString([]byte(key))
+	token.String, err = jwtToken.SignedString(key.private)
 	if err != nil {
 		log.FromContext(ctx).
 			WithErr(err).
@@ -86,19 +174,26 @@ func create(ctx context.Context, user *User, expiry time.Duration, key string) (
 	return token, err
 }
 
-// Parse parses a string token with the key.
+// Parse parses a string access token with the key of its kid.
 func (m *JWT) Parse(tokenString string) (*Claims, error) {
-	var key = []byte(m.config.JWT.AccessKey)
+	return parse(tokenString, m.keys.verificationKey)
+}
 
-	jwtToken, err := jwt.ParseWithClaims(tokenString, &Claims{}, func(t *jwt.Token) (interface{}, error) {
+// parseRefresh parses a string refresh token with the shared refresh key.
+func (m *JWT) parseRefresh(tokenString string) (*Claims, error) {
+	return parse(tokenString, func(t *jwt.Token) (interface{}, error) {
 		if _, ok := t.Method.(*jwt.SigningMethodHMAC); !ok {
 			return nil, ierr.
 				NewReason(ierr.ErrInvalidJWT).
 				WithMessage(fmt.Sprintf("unexpected signing method: %v", t.Header["alg"]))
 		}
 
-		return key, nil
+		return m.refresh.public, nil
 	})
+}
+
+func parse(tokenString string, keyFunc jwt.Keyfunc) (*Claims, error) {
+	jwtToken, err := jwt.ParseWithClaims(tokenString, &Claims{}, keyFunc)
 	if err != nil || !jwtToken.Valid {
 		return nil, ierr.NewReason(ierr.ErrInvalidToken).WithMessage("token validation failed")
 	}
@@ -111,10 +206,9 @@ func (m *JWT) Parse(tokenString string) (*Claims, error) {
 	return claims, nil
 }
 
-// save saves the token to the storage database.
-func (m *JWT) save(ctx context.Context, token *Details) error {
-	expiry := time.Until(time.Unix(token.Expiry, 0))
-	return m.client.Set(ctx, token.UUID, token.Subject, expiry).Err()
+// JWKS returns the public keys verifying the access tokens.
+func (m *JWT) JWKS() *JWKS {
+	return m.keys.jwks()
 }
 
 // CheckIfExists checks if token exists in the storage database.
@@ -127,24 +221,219 @@ func (m *JWT) Delete(ctx context.Context, tokenUUID string) error {
 	return m.client.Del(ctx, tokenUUID).Err()
 }
 
-// SetNew returns the access token.
-func (m *JWT) SetNew(ctx context.Context, user *User) (*Details, error) {
+// SetNew returns the access and refresh tokens of the new family, which keeps the client it is issued to.
+func (m *JWT) SetNew(ctx context.Context, user *User, client *Client) (*Pair, error) {
+	if client == nil {
+		client = &Client{}
+	}
+
+	return m.save(ctx, user, uuid.NewV4().String(),
+		familyIssued, time.Now().Unix(),
+		familyAgent, client.UserAgent,
+		familyClientIP, client.IP,
+	)
+}
+
+// SetRotated returns the access and refresh tokens, which become the current pair of the family.
+func (m *JWT) SetRotated(ctx context.Context, user *User, family string) (*Pair, error) {
+	return m.save(ctx, user, family)
+}
+
+// save creates the pair of the tokens of the family and stores it with the fields of the family hash.
+func (m *JWT) save(ctx context.Context, user *User, family string, fields ...interface{}) (*Pair, error) {
 	logger := log.FromContext(ctx)
 
-	accessToken, err := create(ctx, user, m.config.JWT.AccessExpiry, m.config.JWT.AccessKey)
+	accessToken, err := create(ctx, user, family, m.config.JWT.AccessExpiry, m.keys.active)
+	if err != nil {
+		return nil, err
+	}
+
+	refreshToken, err := create(ctx, user, family, m.config.JWT.RefreshExpiry, m.refresh)
 	if err != nil {
 		return nil, err
 	}
 
-	if err = m.save(ctx, accessToken); err != nil {
+	var (
+		refreshExpiry   = time.Until(time.Unix(refreshToken.Expiry, 0))
+		familyKey       = familyPrefix + family
+		userFamiliesKey = userFamiliesPrefix + strconv.FormatInt(user.ID, 10)
+	)
+
+	_, err = m.client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.Set(ctx, accessToken.UUID, accessToken.Subject, time.Until(time.Unix(accessToken.Expiry, 0)))
+		pipe.Set(ctx, refreshPrefix+refreshToken.UUID, refreshToken.Subject, refreshExpiry)
+		pipe.HSet(ctx, familyKey, append([]interface{}{
+			familyAccess, accessToken.UUID,
+			familyRefresh, refreshToken.UUID,
+			familyUser, user.ID,
+			familyUsed, time.Now().Unix(),
+		}, fields...)...)
+		pipe.Expire(ctx, familyKey, refreshExpiry)
+		pipe.SAdd(ctx, userFamiliesKey, family)
+		pipe.Expire(ctx, userFamiliesKey, refreshExpiry)
+		return nil
+	})
+	if err != nil {
 		logger.WithErr(err).Error("failed to save token to storage")
 		return nil, err
 	}
 
+	return &Pair{
+		Access:  accessToken,
+		Refresh: refreshToken,
+	}, nil
+}
+
+// SetAccess returns the access token of the new family without the refresh token, e.g. for the OAuth 2.0 client,
+// which gets the new token with its credentials instead.
+func (m *JWT) SetAccess(ctx context.Context, user *User, client *Client) (*Details, error) {
+	if client == nil {
+		client = &Client{}
+	}
+
+	family := uuid.NewV4().String()
+
+	accessToken, err := create(ctx, user, family, m.config.JWT.AccessExpiry, m.keys.active)
+	if err != nil {
+		return nil, err
+	}
+
+	var (
+		now             = time.Now()
+		accessExpiry    = time.Until(time.Unix(accessToken.Expiry, 0))
+		familyKey       = familyPrefix + family
+		userFamiliesKey = userFamiliesPrefix + strconv.FormatInt(user.ID, 10)
+	)
+
+	// the set of the families lives as long as the longest one, which may be the refresh one.
+	familiesTTL, err := m.client.TTL(ctx, userFamiliesKey).Result()
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = m.client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.Set(ctx, accessToken.UUID, accessToken.Subject, accessExpiry)
+		pipe.HSet(ctx, familyKey,
+			familyAccess, accessToken.UUID,
+			familyUser, user.ID,
+			familyIssued, now.Unix(),
+			familyUsed, now.Unix(),
+			familyAgent, client.UserAgent,
+			familyClientIP, client.IP,
+			familyAPIKey, client.APIKeyID,
+		)
+		pipe.Expire(ctx, familyKey, accessExpiry)
+		pipe.SAdd(ctx, userFamiliesKey, family)
+		if familiesTTL < accessExpiry {
+			pipe.Expire(ctx, userFamiliesKey, accessExpiry)
+		}
+		return nil
+	})
+	if err != nil {
+		log.FromContext(ctx).WithErr(err).Error("failed to save token to storage")
+		return nil, err
+	}
+
 	return accessToken, nil
 }
 
+// Rotate spends the refresh token and deletes the access token of its family.
+// The reuse of the spent refresh token means it is stolen, so the family is revoked then.
+func (m *JWT) Rotate(ctx context.Context, refreshToken string) (*Claims, error) {
+	claims, err := m.parseRefresh(refreshToken)
+	if err != nil {
+		return nil, err
+	}
+	if claims.Family == "" || claims.User == nil {
+		return nil, ierr.NewReason(ierr.ErrInvalidToken).WithMessage("not a refresh token")
+	}
+
+	familyKey := familyPrefix + claims.Family
+
+	spent, err := m.client.Del(ctx, refreshPrefix+claims.Id).Result()
+	if err != nil {
+		return nil, err
+	}
+
+	if spent == 0 {
+		current, err := m.client.HGet(ctx, familyKey, familyRefresh).Result()
+		if err == redis.Nil {
+			return nil, ierr.NewReason(ierr.ErrTokenExpired)
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		if current != claims.Id {
+			log.FromContext(ctx).
+				WithField("user", claims.User).
+				WithStr("family", claims.Family).
+				Warn("refresh token reuse detected, revoking the family")
+
+			if err := m.Revoke(ctx, claims.Family); err != nil {
+				return nil, err
+			}
+
+			return nil, ierr.NewReason(ierr.ErrInvalidToken).WithMessage("refresh token is already used")
+		}
+
+		return nil, ierr.NewReason(ierr.ErrTokenExpired)
+	}
+
+	access, err := m.client.HGet(ctx, familyKey, familyAccess).Result()
+	if err != nil && err != redis.Nil {
+		return nil, err
+	}
+	if access != "" {
+		if err := m.Delete(ctx, access); err != nil {
+			return nil, err
+		}
+	}
+
+	return claims, nil
+}
+
+// Revoke deletes the current tokens of the family.
+func (m *JWT) Revoke(ctx context.Context, family string) error {
+	familyKey := familyPrefix + family
+
+	tokens, err := m.client.HGetAll(ctx, familyKey).Result()
+	if err != nil {
+		return err
+	}
+	if len(tokens) == 0 {
+		return nil
+	}
+
+	_, err = m.client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.Del(ctx, tokens[familyAccess], refreshPrefix+tokens[familyRefresh], familyKey)
+		pipe.SRem(ctx, userFamiliesPrefix+tokens[familyUser], family)
+		return nil
+	})
+
+	return err
+}
+
+// RevokeAll deletes the current tokens of every family of the user.
+func (m *JWT) RevokeAll(ctx context.Context, userID int64) error {
+	userFamiliesKey := userFamiliesPrefix + strconv.FormatInt(userID, 10)
+
+	families, err := m.client.SMembers(ctx, userFamiliesKey).Result()
+	if err != nil {
+		return err
+	}
+
+	for _, family := range families {
+		if err := m.Revoke(ctx, family); err != nil {
+			return err
+		}
+	}
+
+	return m.client.Del(ctx, userFamiliesKey).Err()
+}
+
 // Verify verifies token, and if it presents in storage returns the user.
+// The family of the token is marked as used now.
 func (m *JWT) Verify(ctx context.Context, tokenString string) (*User, error) {
 	claims, err := m.Parse(tokenString)
 	if err != nil {
@@ -155,5 +444,81 @@ func (m *JWT) Verify(ctx context.Context, tokenString string) (*User, error) {
 		return nil, ierr.NewReason(ierr.ErrTokenExpired)
 	}
 
+	if claims.Family != "" {
+		// the token is verified already, so failing to track the session does not fail the request.
+		err := touchScript.Run(ctx, m.client, []string{familyPrefix + claims.Family}, familyUsed, time.Now().Unix()).Err()
+		if err != nil && err != redis.Nil {
+			log.FromContext(ctx).WithErr(err).Error("failed to track the session usage")
+		}
+	}
+
 	return claims.User, nil
 }
+
+// Session returns the session of the family, it is nil if the family is revoked or expired.
+func (m *JWT) Session(ctx context.Context, family string) (*Session, error) {
+	fields, err := m.client.HGetAll(ctx, familyPrefix+family).Result()
+	if err != nil {
+		return nil, err
+	}
+	if fields[familyUser] == "" {
+		return nil, nil
+	}
+
+	return toSession(family, fields), nil
+}
+
+// Sessions returns the current sessions of the user ordered by the issue time, the expired ones are forgotten.
+func (m *JWT) Sessions(ctx context.Context, userID int64) ([]*Session, error) {
+	userFamiliesKey := userFamiliesPrefix + strconv.FormatInt(userID, 10)
+
+	families, err := m.client.SMembers(ctx, userFamiliesKey).Result()
+	if err != nil {
+		return nil, err
+	}
+
+	var (
+		sessions = make([]*Session, 0, len(families))
+		expired  = make([]interface{}, 0)
+	)
+	for _, family := range families {
+		session, err := m.Session(ctx, family)
+		if err != nil {
+			return nil, err
+		}
+		if session == nil {
+			expired = append(expired, family)
+			continue
+		}
+
+		sessions = append(sessions, session)
+	}
+
+	if len(expired) > 0 {
+		if err := m.client.SRem(ctx, userFamiliesKey, expired...).Err(); err != nil {
+			return nil, err
+		}
+	}
+
+	sort.Slice(sessions, func(i, j int) bool {
+		return sessions[i].IssuedAt < sessions[j].IssuedAt
+	})
+
+	return sessions, nil
+}
+
+// toSession converts the fields of the family hash, the families issued before the sessions are tracked lack the client.
+func toSession(family string, fields map[string]string) *Session {
+	var session = &Session{
+		ID:        family,
+		UserAgent: fields[familyAgent],
+		IP:        fields[familyClientIP],
+	}
+
+	session.UserID, _ = strconv.ParseInt(fields[familyUser], 10, 64)
+	session.IssuedAt, _ = strconv.ParseInt(fields[familyIssued], 10, 64)
+	session.LastUsed, _ = strconv.ParseInt(fields[familyUsed], 10, 64)
+	session.APIKeyID, _ = strconv.ParseInt(fields[familyAPIKey], 10, 64)
+
+	return session
+}
@@ -0,0 +1,248 @@
+package jwt
+
+import (
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"fmt"
+	"io/ioutil"
+	"math/big"
+	"sort"
+	"time"
+
+	"github.com/gxravel/bus-routes/internal/config"
+	ierr "github.com/gxravel/bus-routes/internal/errors"
+
+	"github.com/dgrijalva/jwt-go"
+	"github.com/pkg/errors"
+)
+
+// signer is the key signing the tokens with the method.
+type signer struct {
+	id      string
+	method  jwt.SigningMethod
+	private interface{}
+	public  interface{}
+	// until is the time the key stops verifying the tokens, zero is never.
+	until time.Time
+}
+
+// keySet contains the keys of the access tokens by kid, the active one signs the new tokens.
+// The tokens without kid are verified with the shared key, which are the ones signed before the keys are set,
+// so once the keys are set the shared key verifies them only until the configured time.
+type keySet struct {
+	active *signer
+	keys   map[string]*signer
+	shared *signer
+}
+
+// JWKS describes the JSON Web Key Set of the public keys (RFC 7517).
+type JWKS struct {
+	Keys []JWK `json:"keys"`
+}
+
+// JWK describes the public RSA or Ed25519 key (RFC 7518, RFC 8037).
+type JWK struct {
+	Kty string `json:"kty"`
+	Use string `json:"use"`
+	Alg string `json:"alg"`
+	Kid string `json:"kid"`
+	N   string `json:"n,omitempty"`
+	E   string `json:"e,omitempty"`
+	Crv string `json:"crv,omitempty"`
+	X   string `json:"x,omitempty"`
+}
+
+func newHMACSigner(key string) *signer {
+	return &signer{
+		method:  jwt.SigningMethodHS512,
+		private: []byte(key),
+		public:  []byte(key),
+	}
+}
+
+// retired returns true if the key does not verify the tokens at the moment.
+func (s *signer) retired(now time.Time) bool {
+	return !s.until.IsZero() && !now.Before(s.until)
+}
+
+// newKeySet loads the signing keys of the config, the shared access key signs the tokens without them.
+func newKeySet(cfg config.Config) (*keySet, error) {
+	var ks = &keySet{
+		keys: make(map[string]*signer, len(cfg.JWT.SigningKeys)),
+	}
+
+	if cfg.JWT.AccessKey != "" {
+		ks.shared = newHMACSigner(cfg.JWT.AccessKey)
+	}
+
+	for _, key := range cfg.JWT.SigningKeys {
+		if key.ID == "" {
+			return nil, errors.Errorf("signing key %s: id is empty", key.Path)
+		}
+		if _, ok := ks.keys[key.ID]; ok {
+			return nil, errors.Errorf("signing key %s: duplicate id", key.ID)
+		}
+
+		s, err := loadSigner(key.ID, key.Path)
+		if err != nil {
+			return nil, errors.Wrapf(err, "signing key %s", key.ID)
+		}
+		if s.until, err = parseTime(key.RetireAt); err != nil {
+			return nil, errors.Wrapf(err, "signing key %s: retire_at", key.ID)
+		}
+
+		ks.keys[key.ID] = s
+	}
+
+	if len(ks.keys) == 0 {
+		if ks.shared == nil {
+			return nil, errors.New("neither access key nor signing keys are set")
+		}
+
+		ks.active = ks.shared
+		return ks, nil
+	}
+
+	active, ok := ks.keys[cfg.JWT.ActiveKey]
+	if !ok {
+		return nil, errors.Errorf("active key %q is not one of the signing keys", cfg.JWT.ActiveKey)
+	}
+	if !active.until.IsZero() {
+		return nil, errors.Errorf("active key %q must not be retired", active.id)
+	}
+	ks.active = active
+
+	// the tokens of the shared key are verified only during the switch to the signing keys.
+	until, err := parseTime(cfg.JWT.SharedKeyUntil)
+	if err != nil {
+		return nil, errors.Wrap(err, "shared_key_until")
+	}
+	if ks.shared != nil {
+		if until.IsZero() {
+			ks.shared = nil
+		} else {
+			ks.shared.until = until
+		}
+	}
+
+	return ks, nil
+}
+
+// parseTime parses the time in RFC 3339, empty value is zero time.
+func parseTime(value string) (time.Time, error) {
+	if value == "" {
+		return time.Time{}, nil
+	}
+
+	return time.Parse(time.RFC3339, value)
+}
+
+// loadSigner reads the PEM private key: RSA in PKCS #1 or PKCS #8, Ed25519 in PKCS #8.
+func loadSigner(id string, path string) (*signer, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, errors.New("no PEM data is found")
+	}
+
+	var key interface{}
+	if key, err = x509.ParsePKCS8PrivateKey(block.Bytes); err != nil {
+		if key, err = x509.ParsePKCS1PrivateKey(block.Bytes); err != nil {
+			return nil, errors.Wrap(err, "parse private key")
+		}
+	}
+
+	switch key := key.(type) {
+	case *rsa.PrivateKey:
+		return &signer{
+			id:      id,
+			method:  jwt.SigningMethodRS256,
+			private: key,
+			public:  &key.PublicKey,
+		}, nil
+
+	case ed25519.PrivateKey:
+		return &signer{
+			id:      id,
+			method:  SigningMethodEd25519,
+			private: key,
+			public:  key.Public(),
+		}, nil
+
+	default:
+		return nil, errors.Errorf("unsupported key type %T", key)
+	}
+}
+
+// verificationKey returns the key of the token by its kid, and checks the method of the token is of the key.
+func (ks *keySet) verificationKey(t *jwt.Token) (interface{}, error) {
+	var key = ks.shared
+
+	if kid, ok := t.Header["kid"].(string); ok {
+		key = ks.keys[kid]
+		if key == nil {
+			return nil, ierr.
+				NewReason(ierr.ErrInvalidJWT).
+				WithMessage(fmt.Sprintf("unknown key id: %s", kid))
+		}
+	}
+
+	if key != nil && key.retired(time.Now()) {
+		return nil, ierr.
+			NewReason(ierr.ErrInvalidJWT).
+			WithMessage("the signing key of the token is retired")
+	}
+
+	if key == nil || t.Method.Alg() != key.method.Alg() {
+		return nil, ierr.
+			NewReason(ierr.ErrInvalidJWT).
+			WithMessage(fmt.Sprintf("unexpected signing method: %v", t.Header["alg"]))
+	}
+
+	return key.public, nil
+}
+
+// jwks returns the public keys that are not retired, the shared key is not published.
+func (ks *keySet) jwks() *JWKS {
+	var (
+		set = &JWKS{Keys: make([]JWK, 0, len(ks.keys))}
+		now = time.Now()
+	)
+
+	for _, s := range ks.keys {
+		if s.retired(now) {
+			continue
+		}
+
+		key := JWK{
+			Use: "sig",
+			Alg: s.method.Alg(),
+			Kid: s.id,
+		}
+
+		switch public := s.public.(type) {
+		case *rsa.PublicKey:
+			key.Kty = "RSA"
+			key.N = base64.RawURLEncoding.EncodeToString(public.N.Bytes())
+			key.E = base64.RawURLEncoding.EncodeToString(big.NewInt(int64(public.E)).Bytes())
+
+		case ed25519.PublicKey:
+			key.Kty = "OKP"
+			key.Crv = "Ed25519"
+			key.X = base64.RawURLEncoding.EncodeToString(public)
+		}
+
+		set.Keys = append(set.Keys, key)
+	}
+
+	sort.Slice(set.Keys, func(i, j int) bool { return set.Keys[i].Kid < set.Keys[j].Kid })
+
+	return set
+}
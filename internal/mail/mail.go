@@ -0,0 +1,220 @@
+package mail
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"fmt"
+	"mime"
+	"net"
+	"net/smtp"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/gxravel/bus-routes/internal/config"
+	log "github.com/gxravel/bus-routes/internal/logger"
+
+	"github.com/pkg/errors"
+)
+
+// The drivers of the mailer.
+const (
+	DriverSMTP = "smtp"
+	DriverLog  = "log"
+)
+
+// Message is the plain text email.
+type Message struct {
+	To      string
+	Subject string
+	Body    string
+}
+
+// Mailer delivers the emails.
+type Mailer interface {
+	Send(ctx context.Context, msg *Message) error
+}
+
+// New creates the mailer of the configured driver.
+func New(cfg config.Config, logger log.Logger) (Mailer, error) {
+	switch cfg.Mail.Driver {
+	case DriverSMTP:
+		return NewSMTPMailer(cfg), nil
+	case DriverLog:
+		return NewLogMailer(cfg, logger), nil
+	default:
+		return nil, errors.Errorf("unknown mail driver %q", cfg.Mail.Driver)
+	}
+}
+
+// SMTPMailer sends the emails through the SMTP server.
+type SMTPMailer struct {
+	address string
+	host    string
+	from    string
+	auth    smtp.Auth
+	timeout time.Duration
+}
+
+// NewSMTPMailer creates new instance of SMTPMailer, it authenticates with PLAIN if the username is set.
+func NewSMTPMailer(cfg config.Config) *SMTPMailer {
+	host, _, _ := net.SplitHostPort(cfg.Mail.SMTP.Address)
+
+	m := &SMTPMailer{
+		address: cfg.Mail.SMTP.Address,
+		host:    host,
+		from:    cfg.Mail.From,
+		timeout: cfg.Mail.SMTP.Timeout,
+	}
+
+	if cfg.Mail.SMTP.Username != "" {
+		m.auth = smtp.PlainAuth("", cfg.Mail.SMTP.Username, cfg.Mail.SMTP.Password, host)
+	}
+
+	return m
+}
+
+// Send sends the email, the whole SMTP session is limited by the timeout and ctx,
+// so the slow server does not hold the request.
+func (m *SMTPMailer) Send(ctx context.Context, msg *Message) error {
+	if err := m.send(ctx, msg); err != nil {
+		return errors.Wrapf(err, "send mail to %s", msg.To)
+	}
+
+	return nil
+}
+
+// send does what smtp.SendMail does on the connection with the deadline.
+func (m *SMTPMailer) send(ctx context.Context, msg *Message) error {
+	if m.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, m.timeout)
+		defer cancel()
+	}
+
+	var dialer net.Dialer
+	conn, err := dialer.DialContext(ctx, "tcp", m.address)
+	if err != nil {
+		return err
+	}
+
+	if deadline, ok := ctx.Deadline(); ok {
+		if err := conn.SetDeadline(deadline); err != nil {
+			conn.Close()
+			return err
+		}
+	}
+
+	// the canceled ctx interrupts the session by the deadline in the past.
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		select {
+		case <-ctx.Done():
+			_ = conn.SetDeadline(time.Now())
+		case <-done:
+		}
+	}()
+
+	c, err := smtp.NewClient(conn, m.host)
+	if err != nil {
+		conn.Close()
+		return err
+	}
+	defer c.Close()
+
+	if ok, _ := c.Extension("STARTTLS"); ok {
+		if err := c.StartTLS(&tls.Config{ServerName: m.host}); err != nil {
+			return err
+		}
+	}
+	if m.auth != nil {
+		if ok, _ := c.Extension("AUTH"); ok {
+			if err := c.Auth(m.auth); err != nil {
+				return err
+			}
+		}
+	}
+
+	if err := c.Mail(m.from); err != nil {
+		return err
+	}
+	if err := c.Rcpt(msg.To); err != nil {
+		return err
+	}
+
+	w, err := c.Data()
+	if err != nil {
+		return err
+	}
+	if _, err := w.Write(format(m.from, msg)); err != nil {
+		return err
+	}
+	if err := w.Close(); err != nil {
+		return err
+	}
+
+	return c.Quit()
+}
+
+// LogMailer writes the emails to the file, or to the log if the file is not set, e.g. for the local runs.
+type LogMailer struct {
+	mu     sync.Mutex
+	path   string
+	from   string
+	logger log.Logger
+}
+
+// NewLogMailer creates new instance of LogMailer.
+func NewLogMailer(cfg config.Config, logger log.Logger) *LogMailer {
+	return &LogMailer{
+		path:   cfg.Mail.File,
+		from:   cfg.Mail.From,
+		logger: logger.WithModule("mail"),
+	}
+}
+
+// Send appends the email to the file or writes it to the log.
+func (m *LogMailer) Send(ctx context.Context, msg *Message) error {
+	if m.path == "" {
+		m.logger.
+			WithStr("to", msg.To).
+			WithStr("subject", msg.Subject).
+			Info(msg.Body)
+		return nil
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	f, err := os.OpenFile(m.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		return err
+	}
+
+	if _, err := f.Write(append(format(m.from, msg), '\n')); err != nil {
+		f.Close()
+		return err
+	}
+
+	return f.Close()
+}
+
+// format formats the message as the plain text email in UTF-8.
+func format(from string, msg *Message) []byte {
+	var b bytes.Buffer
+
+	fmt.Fprintf(&b, "From: %s\r\n", from)
+	fmt.Fprintf(&b, "To: %s\r\n", msg.To)
+	fmt.Fprintf(&b, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", msg.Subject))
+	fmt.Fprintf(&b, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	b.WriteString("MIME-Version: 1.0\r\n")
+	b.WriteString("Content-Type: text/plain; charset=UTF-8\r\n")
+	b.WriteString("Content-Transfer-Encoding: 8bit\r\n")
+	b.WriteString("\r\n")
+	b.WriteString(msg.Body)
+	b.WriteString("\r\n")
+
+	return b.Bytes()
+}
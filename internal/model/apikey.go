@@ -0,0 +1,14 @@
+package model
+
+// APIKey describes API key of the service user in bus_routes.api_key.
+type APIKey struct {
+	ID        int64       `db:"id"`
+	UserID    int64       `db:"user_id"`
+	Name      string      `db:"name"`
+	Prefix    string      `db:"prefix"`
+	HashedKey []byte      `db:"hashed_key"`
+	Scopes    Permissions `db:"scopes"`
+	// ExpiresAt and CreatedAt are unix time, the key without ExpiresAt never expires.
+	ExpiresAt *int64 `db:"expires_at"`
+	CreatedAt int64  `db:"created_at"`
+}
@@ -0,0 +1,111 @@
+package model
+
+import (
+	"encoding/json"
+	"time"
+
+	uuid "github.com/satori/go.uuid"
+)
+
+// Routing keys of the domain events in format <entity>.<change>.
+const (
+	EventCityCreated       = "city.created"
+	EventCityUpdated       = "city.updated"
+	EventCityDeleted       = "city.deleted"
+	EventStopCreated       = "stop.created"
+	EventStopUpdated       = "stop.updated"
+	EventStopDeleted       = "stop.deleted"
+	EventBusCreated        = "bus.created"
+	EventBusUpdated        = "bus.updated"
+	EventBusDeleted        = "bus.deleted"
+	EventRouteUpdated      = "route.updated"
+	EventRouteReplaced     = "route.replaced"
+	EventTimetableReplaced = "timetable.replaced"
+)
+
+// Event describes domain change event in bus_routes.outbox.
+type Event struct {
+	ID         int64  `db:"id"`
+	EventID    string `db:"event_id"`
+	RoutingKey string `db:"routing_key"`
+	Body       []byte `db:"body"`
+}
+
+// EventMessage is the published body of the event, the consumers deduplicate the events by ID.
+type EventMessage struct {
+	ID         string      `json:"id"`
+	Type       string      `json:"type"`
+	OccurredAt time.Time   `json:"occurred_at"`
+	Data       interface{} `json:"data"`
+}
+
+// CityEvent is the data of the city events.
+type CityEvent struct {
+	ID   int    `json:"id"`
+	Name string `json:"name"`
+}
+
+// StopEvent is the data of the stop events.
+type StopEvent struct {
+	ID      int64    `json:"id"`
+	City    string   `json:"city"`
+	Address string   `json:"address"`
+	Lat     *float64 `json:"lat,omitempty"`
+	Lon     *float64 `json:"lon,omitempty"`
+}
+
+// BusEvent is the data of the bus events.
+type BusEvent struct {
+	ID   int64  `json:"id"`
+	City string `json:"city"`
+	Num  string `json:"num"`
+}
+
+// RouteEvent is the data of the route and the timetable events, the stops are set only when the route is replaced.
+type RouteEvent struct {
+	BusID     int64     `json:"bus_id"`
+	Direction Direction `json:"direction"`
+	Variant   string    `json:"variant"`
+	StopIDs   []int64   `json:"stop_ids,omitempty"`
+}
+
+// NewEvent creates new event with unique id and the body to publish.
+func NewEvent(routingKey string, data interface{}) (*Event, error) {
+	message := EventMessage{
+		ID:         uuid.NewV4().String(),
+		Type:       routingKey,
+		OccurredAt: time.Now().UTC(),
+		Data:       data,
+	}
+
+	body, err := json.Marshal(message)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Event{
+		EventID:    message.ID,
+		RoutingKey: routingKey,
+		Body:       body,
+	}, nil
+}
+
+// NewStopEvent creates the data of the stop events.
+func NewStopEvent(stop *Stop) StopEvent {
+	return StopEvent{
+		ID:      stop.ID,
+		City:    stop.City,
+		Address: stop.Address,
+		Lat:     stop.Lat,
+		Lon:     stop.Lon,
+	}
+}
+
+// NewRouteEvent creates the data of the route events of the pattern.
+func NewRouteEvent(pattern RoutePattern) RouteEvent {
+	return RouteEvent{
+		BusID:     pattern.BusID,
+		Direction: pattern.Direction,
+		Variant:   pattern.Variant,
+	}
+}
@@ -0,0 +1,171 @@
+package model
+
+import (
+	"database/sql/driver"
+	"fmt"
+	"strings"
+)
+
+// Permission allows the user to perform the group of the actions.
+type Permission string
+
+func (p Permission) String() string { return string(p) }
+
+const (
+	PermissionCitiesWrite      Permission = "cities:write"
+	PermissionStopsWrite       Permission = "stops:write"
+	PermissionBusesWrite       Permission = "buses:write"
+	PermissionRoutesWrite      Permission = "routes:write"
+	PermissionRoutesDetailed   Permission = "routes:detailed"
+	PermissionTimetablesWrite  Permission = "timetables:write"
+	PermissionGTFSImport       Permission = "gtfs:import"
+	PermissionGTFSExport       Permission = "gtfs:export"
+	PermissionUsersAdmin       Permission = "users:admin"
+	PermissionTokensIntrospect Permission = "tokens:introspect"
+)
+
+var (
+	V1BusroutesPermissions = []Permission{
+		PermissionCitiesWrite,
+		PermissionStopsWrite,
+		PermissionBusesWrite,
+		PermissionRoutesWrite,
+		PermissionRoutesDetailed,
+		PermissionTimetablesWrite,
+		PermissionGTFSImport,
+		PermissionGTFSExport,
+		PermissionUsersAdmin,
+		PermissionTokensIntrospect,
+	}
+)
+
+// readPermissions are the permissions of the read-only requests, the user with unverified email is limited to them.
+var readPermissions = Permissions{
+	PermissionRoutesDetailed,
+	PermissionGTFSExport,
+}
+
+// IsValid returns true if the permission is one of V1BusroutesPermissions.
+func (p Permission) IsValid() bool {
+	return Permissions(V1BusroutesPermissions).Has(p)
+}
+
+// Permissions is the set of the permissions, stored as the space separated list.
+type Permissions []Permission
+
+// Has returns true if ps has all the permissions.
+func (ps Permissions) Has(permissions ...Permission) bool {
+	for _, permission := range permissions {
+		if !ps.has(permission) {
+			return false
+		}
+	}
+
+	return true
+}
+
+func (ps Permissions) has(permission Permission) bool {
+	for _, p := range ps {
+		if p == permission {
+			return true
+		}
+	}
+
+	return false
+}
+
+// ReadOnly returns the permissions of ps which allow only the read-only requests.
+func (ps Permissions) ReadOnly() Permissions {
+	var result = make(Permissions, 0, len(ps))
+	for _, p := range ps {
+		if readPermissions.has(p) {
+			result = append(result, p)
+		}
+	}
+
+	return result
+}
+
+// String returns the space separated list of the permissions, as OAuth 2.0 scope is.
+func (ps Permissions) String() string {
+	var list = make([]string, 0, len(ps))
+	for _, p := range ps {
+		list = append(list, p.String())
+	}
+
+	return strings.Join(list, " ")
+}
+
+// Value implements driver.Valuer.
+func (ps Permissions) Value() (driver.Value, error) {
+	return ps.String(), nil
+}
+
+// Scan implements sql.Scanner.
+func (ps *Permissions) Scan(src interface{}) error {
+	var list string
+	switch src := src.(type) {
+	case []byte:
+		list = string(src)
+	case string:
+		list = src
+	case nil:
+	default:
+		return fmt.Errorf("can not scan %T into permissions", src)
+	}
+
+	*ps = make(Permissions, 0)
+	for _, p := range strings.Fields(list) {
+		*ps = append(*ps, Permission(p))
+	}
+
+	return nil
+}
+
+// rolePermissions maps the user types to their permissions.
+var rolePermissions = map[UserType]Permissions{
+	UserAdmin: {
+		PermissionCitiesWrite,
+		PermissionStopsWrite,
+		PermissionBusesWrite,
+		PermissionRoutesWrite,
+		PermissionRoutesDetailed,
+		PermissionTimetablesWrite,
+		PermissionGTFSImport,
+		PermissionGTFSExport,
+		PermissionUsersAdmin,
+		PermissionTokensIntrospect,
+	},
+	UserEditor: {
+		PermissionStopsWrite,
+		PermissionBusesWrite,
+		PermissionRoutesWrite,
+		PermissionRoutesDetailed,
+		PermissionGTFSExport,
+	},
+	UserService: {
+		PermissionRoutesDetailed,
+		PermissionGTFSExport,
+	},
+	UserGuest: {},
+}
+
+// cityScopedTypes are the user types allowed to change only the buses, the stops and the routes of their cities.
+var cityScopedTypes = map[UserType]bool{
+	UserEditor: true,
+}
+
+// IsCityScoped returns true if the user type is limited to the cities of the user.
+func (t UserType) IsCityScoped() bool {
+	return cityScopedTypes[t]
+}
+
+// Permissions returns the permissions of the user type.
+func (t UserType) Permissions() Permissions {
+	return rolePermissions[t]
+}
+
+// Can returns true if the user type has all the permissions.
+func (t UserType) Can(permissions ...Permission) bool {
+	return t.Permissions().Has(permissions...)
+}
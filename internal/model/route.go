@@ -1,13 +1,52 @@
 package model
 
+import "math"
+
+// MaxStep is the largest step of a route pattern, route.step is SMALLINT.
+const MaxStep = math.MaxInt16
+
 // Route describes route in bus_routes.route.
 type Route struct {
-	BusID  int64 `db:"bus_id"`
+	RoutePattern
 	StopID int64 `db:"stop_id"`
-	Step   int8  `db:"step"`
+	Step   int   `db:"step"`
 
 	// implicitly
-	City    string `db:"city"`
-	Address string `db:"address"`
-	Number  string `db:"num"`
+	City    string   `db:"city"`
+	Address string   `db:"address"`
+	Number  string   `db:"num"`
+	Lat     *float64 `db:"lat"`
+	Lon     *float64 `db:"lon"`
+}
+
+// RoutePattern describes the direction and the variant of a bus route, every pattern has its own steps.
+type RoutePattern struct {
+	BusID     int64     `db:"bus_id"`
+	Direction Direction `db:"direction"`
+	Variant   string    `db:"variant"`
+}
+
+type Direction string
+
+func (d Direction) String() string { return string(d) }
+
+const (
+	DirectionOutbound Direction = "outbound"
+	DirectionInbound  Direction = "inbound"
+	DefaultDirection  Direction = DirectionOutbound
+)
+
+var (
+	Directions = []Direction{DirectionOutbound, DirectionInbound}
+)
+
+// IsValid returns true if the direction is one of Directions.
+func (d Direction) IsValid() bool {
+	for _, direction := range Directions {
+		if d == direction {
+			return true
+		}
+	}
+
+	return false
 }
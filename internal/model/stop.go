@@ -2,9 +2,11 @@ package model
 
 // Stop describes stop in bus_routes.stop.
 type Stop struct {
-	ID      int64  `db:"id"`
-	CityID  int    `db:"city_id"`
-	Address string `db:"address"`
+	ID      int64    `db:"id"`
+	CityID  int      `db:"city_id"`
+	Address string   `db:"address"`
+	Lat     *float64 `db:"lat"`
+	Lon     *float64 `db:"lon"`
 
 	// implicitly
 	City string `db:"city"`
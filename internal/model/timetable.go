@@ -0,0 +1,54 @@
+package model
+
+import "time"
+
+// Trip describes trip in bus_routes.trip.
+type Trip struct {
+	RoutePattern
+	ID        int64    `db:"id"`
+	StartTime int      `db:"start_time"`
+	Days      Weekdays `db:"days"`
+}
+
+// StopTime describes stop time in bus_routes.stop_time.
+type StopTime struct {
+	RoutePattern
+	Step      int `db:"step"`
+	Arrival   int `db:"arrival"`
+	Departure int `db:"departure"`
+
+	// implicitly
+	StopID  int64  `db:"stop_id"`
+	Number  string `db:"num"`
+	Address string `db:"address"`
+}
+
+// Weekdays is the bitmask of the service days, sunday is the lowest bit as in time.Weekday.
+type Weekdays uint8
+
+// NewWeekdays creates the bitmask of days.
+func NewWeekdays(days ...time.Weekday) Weekdays {
+	var w Weekdays
+	for _, day := range days {
+		w |= 1 << uint(day)
+	}
+
+	return w
+}
+
+// Has returns true if day is in w.
+func (w Weekdays) Has(day time.Weekday) bool {
+	return w&(1<<uint(day)) != 0
+}
+
+// Days returns the days of w.
+func (w Weekdays) Days() []time.Weekday {
+	var days = make([]time.Weekday, 0, 7)
+	for day := time.Sunday; day <= time.Saturday; day++ {
+		if w.Has(day) {
+			days = append(days, day)
+		}
+	}
+
+	return days
+}
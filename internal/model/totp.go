@@ -0,0 +1,9 @@
+package model
+
+// TOTP describes the TOTP second factor of the user in bus_routes.user_totp.
+type TOTP struct {
+	UserID   int64  `db:"user_id"`
+	Secret   string `db:"secret"`
+	Enabled  bool   `db:"enabled"`
+	LastStep int64  `db:"last_step"`
+}
@@ -8,8 +8,23 @@ type User struct {
 	Email          string    `db:"email"`
 	HashedPassword []byte    `db:"hashed_password"`
 	Type           UserType  `db:"type"`
+	Verified       bool      `db:"verified"`
 	CreatedAt      time.Time `db:"created_at"`
 	UpdatedAt      time.Time `db:"updated_at"`
+
+	// from bus_routes.user_city
+	Cities []string `db:"-"`
+	// from bus_routes.user_totp
+	TwoFactor bool `db:"-"`
+}
+
+// UserCity describes the city scope of the user in bus_routes.user_city.
+type UserCity struct {
+	UserID int64 `db:"user_id"`
+	CityID int   `db:"city_id"`
+
+	// implicitly
+	City string `db:"city"`
 }
 
 type UserType string
@@ -18,13 +33,14 @@ func (t UserType) String() string { return string(t) }
 
 const (
 	UserAdmin       UserType = "admin"
+	UserEditor      UserType = "editor"
 	UserGuest       UserType = "guest"
 	UserService     UserType = "service"
 	DefaultUserType UserType = UserGuest
 )
 
 var (
-	V1BusroutesUserTypes = []UserType{UserAdmin, UserGuest, UserService}
+	V1BusroutesUserTypes = []UserType{UserAdmin, UserEditor, UserGuest, UserService}
 )
 
 type UserTypes []UserType
@@ -0,0 +1,167 @@
+package outbox
+
+import (
+	"context"
+	"time"
+
+	"github.com/gxravel/bus-routes/internal/config"
+	"github.com/gxravel/bus-routes/internal/dataprovider"
+	log "github.com/gxravel/bus-routes/internal/logger"
+	"github.com/gxravel/bus-routes/internal/model"
+	rmq "github.com/gxravel/bus-routes/pkg/rmq"
+
+	"github.com/pkg/errors"
+	"github.com/streadway/amqp"
+)
+
+const exchangeType = "topic"
+
+// Relay publishes the events of the outbox to the topic exchange in the order they were written.
+// An event is deleted only after it is published, so the delivery is at least once:
+// the consumers must deduplicate the events by the id of the message.
+type Relay struct {
+	store     dataprovider.OutboxStore
+	txer      dataprovider.Txer
+	broker    rmq.MessageBroker
+	logger    log.Logger
+	exchange  string
+	interval  time.Duration
+	batchSize uint64
+	// claimTimeout is the time to publish the claimed batch, after that the other relays claim it again.
+	claimTimeout time.Duration
+}
+
+// NewRelay creates new instance of Relay.
+func NewRelay(
+	cfg *config.Config,
+	store dataprovider.OutboxStore,
+	txer dataprovider.Txer,
+	broker rmq.MessageBroker,
+	logger log.Logger,
+) *Relay {
+	return &Relay{
+		store:     store,
+		txer:      txer,
+		broker:    broker,
+		logger:    logger.WithModule("outbox"),
+		exchange:  cfg.Outbox.Exchange,
+		interval:  cfg.Outbox.Interval,
+		batchSize: cfg.Outbox.BatchSize,
+
+		claimTimeout: cfg.Outbox.ClaimTimeout,
+	}
+}
+
+// Run declares the exchange and relays the events until ctx is done.
+func (r *Relay) Run(ctx context.Context) error {
+	if err := r.broker.DeclareExchange(r.exchange, exchangeType); err != nil {
+		return errors.Wrapf(err, "declare exchange %s", r.exchange)
+	}
+
+	ctx = log.CtxWithLogger(ctx, r.logger)
+
+	ticker := time.NewTicker(r.interval)
+	defer ticker.Stop()
+
+	for {
+		// the full batch means there are more events to relay right away.
+		n, err := r.relay(ctx)
+		if err != nil {
+			r.logger.WithErr(err).Error("relay events")
+		}
+		if err == nil && r.batchSize > 0 && n == r.batchSize {
+			continue
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
+
+// relay claims the batch of the oldest events, publishes them and deletes the published ones.
+// The claim is commited before the publishing, so a slow broker holds neither the transaction nor the locks,
+// and the relays of the other instances skip the claimed events until the claim expires.
+// The events that are not published are released for the next batch.
+func (r *Relay) relay(ctx context.Context) (uint64, error) {
+	events, err := r.claim(ctx)
+	if err != nil {
+		return 0, err
+	}
+	if len(events) == 0 {
+		return 0, nil
+	}
+
+	var (
+		ids        = make([]int64, 0, len(events))
+		publishErr error
+	)
+
+	for _, event := range events {
+		publishErr = r.broker.Publish(r.exchange, event.RoutingKey, amqp.Publishing{
+			MessageId:   event.EventID,
+			ContentType: "application/json",
+			Type:        event.RoutingKey,
+			Body:        event.Body,
+		})
+		if publishErr != nil {
+			publishErr = errors.Wrapf(publishErr, "publish event %s", event.EventID)
+			break
+		}
+
+		ids = append(ids, event.ID)
+	}
+
+	if len(ids) > 0 {
+		if err := r.store.Delete(ctx, ids...); err != nil {
+			return 0, err
+		}
+	}
+
+	if rest := events[len(ids):]; len(rest) > 0 {
+		var restIDs = make([]int64, 0, len(rest))
+		for _, event := range rest {
+			restIDs = append(restIDs, event.ID)
+		}
+
+		if err := r.store.Release(ctx, restIDs...); err != nil {
+			r.logger.WithErr(err).Error("release events")
+		}
+	}
+
+	return uint64(len(ids)), publishErr
+}
+
+// claim claims the batch of the oldest unclaimed events in the short transaction.
+func (r *Relay) claim(ctx context.Context) ([]*model.Event, error) {
+	var events []*model.Event
+
+	f := func(tx *dataprovider.Tx) error {
+		store := r.store.WithTx(tx)
+
+		filter := dataprovider.NewOutboxFilter().
+			WithLimit(r.batchSize).
+			OnlyUnclaimed().
+			LockForUpdate()
+
+		var err error
+		if events, err = store.GetListByFilter(ctx, filter); err != nil || len(events) == 0 {
+			return err
+		}
+
+		var ids = make([]int64, 0, len(events))
+		for _, event := range events {
+			ids = append(ids, event.ID)
+		}
+
+		return store.Claim(ctx, r.claimTimeout, ids...)
+	}
+
+	if err := dataprovider.BeginAutoCommitedTx(ctx, r.txer, f); err != nil {
+		return nil, err
+	}
+
+	return events, nil
+}
@@ -0,0 +1,366 @@
+package outbox
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gxravel/bus-routes/internal/dataprovider"
+	log "github.com/gxravel/bus-routes/internal/logger"
+	"github.com/gxravel/bus-routes/internal/model"
+	rmq "github.com/gxravel/bus-routes/pkg/rmq"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/streadway/amqp"
+)
+
+const testExchange = "events"
+
+func TestRelay(t *testing.T) {
+	tests := []struct {
+		name string
+		// claimed are the ids of the events claimed by the other relay until the time relative to now.
+		claimed map[int64]time.Duration
+		// declare is false if the exchange is not declared, so the broker fails to publish.
+		declare   bool
+		batchSize uint64
+
+		wantPublished []string
+		wantLeft      []int64
+		wantErr       bool
+	}{
+		{
+			name:          "publishes in order and deletes",
+			declare:       true,
+			wantPublished: []string{"city.created", "stop.created", "bus.created"},
+		},
+		{
+			name:          "batch size limits",
+			declare:       true,
+			batchSize:     2,
+			wantPublished: []string{"city.created", "stop.created"},
+			wantLeft:      []int64{3},
+		},
+		{
+			name:          "skips the events claimed by the other relay",
+			declare:       true,
+			claimed:       map[int64]time.Duration{2: time.Minute},
+			wantPublished: []string{"city.created", "bus.created"},
+			wantLeft:      []int64{2},
+		},
+		{
+			name:          "claims the events of the expired claim",
+			declare:       true,
+			claimed:       map[int64]time.Duration{2: -time.Minute},
+			wantPublished: []string{"city.created", "stop.created", "bus.created"},
+		},
+		{
+			name:     "keeps and releases the events the broker fails",
+			declare:  false,
+			wantLeft: []int64{1, 2, 3},
+			wantErr:  true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store := newMemoryOutbox("city.created", "stop.created", "bus.created")
+			var eventIDs = make(map[string]string, len(store.events))
+			for _, event := range store.events {
+				eventIDs[event.RoutingKey] = event.EventID
+			}
+			for id, until := range tt.claimed {
+				store.claimed[id] = time.Now().Add(until)
+			}
+
+			broker := rmq.NewMemory(rmq.Config{})
+			defer broker.Close()
+
+			var deliveries <-chan amqp.Delivery
+			if tt.declare {
+				deliveries = bindAll(t, broker)
+			}
+
+			relay := &Relay{
+				store:        store,
+				txer:         newTestTxer(t),
+				broker:       broker,
+				logger:       log.Default(),
+				exchange:     testExchange,
+				batchSize:    tt.batchSize,
+				claimTimeout: time.Minute,
+			}
+
+			n, err := relay.relay(context.Background())
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("relay() error = %v, want error %v", err, tt.wantErr)
+			}
+			if n != uint64(len(tt.wantPublished)) {
+				t.Errorf("relay() = %d, want %d", n, len(tt.wantPublished))
+			}
+
+			var published = make([]string, 0, len(tt.wantPublished))
+			for range tt.wantPublished {
+				select {
+				case d := <-deliveries:
+					published = append(published, d.RoutingKey)
+
+					// the consumers deduplicate the events by the id of the message.
+					if d.MessageId == "" || d.MessageId != eventIDs[d.RoutingKey] {
+						t.Errorf("event %s is published with message id %q, want %q", d.RoutingKey, d.MessageId, eventIDs[d.RoutingKey])
+					}
+					if d.ContentType != "application/json" {
+						t.Errorf("event %s is published with content type %q", d.RoutingKey, d.ContentType)
+					}
+				case <-time.After(time.Second):
+					t.Fatal("the event is not published")
+				}
+			}
+			if strings.Join(published, ",") != strings.Join(tt.wantPublished, ",") {
+				t.Errorf("published %v, want %v", published, tt.wantPublished)
+			}
+
+			if left := store.ids(); fmt.Sprint(left) != fmt.Sprint(tt.wantLeft) {
+				t.Errorf("left %v, want %v", left, tt.wantLeft)
+			}
+
+			// the failed events must be released for the next batch, the others keep their claims.
+			for _, id := range tt.wantLeft {
+				if _, ok := tt.claimed[id]; !ok && store.isClaimed(id) {
+					t.Errorf("event %d is left claimed", id)
+				}
+			}
+		})
+	}
+}
+
+func TestRelayRun(t *testing.T) {
+	store := newMemoryOutbox("city.created", "stop.created", "stop.updated", "bus.created", "route.created")
+
+	broker := rmq.NewMemory(rmq.Config{})
+	defer broker.Close()
+
+	deliveries := bindAll(t, broker)
+
+	// the interval is too long to be waited, so the events are relayed only if the full batches continue at once.
+	relay := &Relay{
+		store:        store,
+		txer:         newTestTxer(t),
+		broker:       broker,
+		logger:       log.Default(),
+		exchange:     testExchange,
+		interval:     time.Hour,
+		batchSize:    2,
+		claimTimeout: time.Minute,
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	var done = make(chan error, 1)
+	go func() { done <- relay.Run(ctx) }()
+
+	for k := 0; k < 5; k++ {
+		select {
+		case <-deliveries:
+		case <-time.After(time.Second):
+			t.Fatalf("%d of 5 events are published", k)
+		}
+	}
+
+	cancel()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatalf("Run() error = %v", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Run() is not stopped")
+	}
+
+	if left := store.ids(); len(left) != 0 {
+		t.Errorf("left %v", left)
+	}
+}
+
+// bindAll declares the exchange and the queue receiving all its events.
+func bindAll(t *testing.T, broker rmq.MessageBroker) <-chan amqp.Delivery {
+	t.Helper()
+
+	if err := broker.DeclareExchange(testExchange, exchangeType); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := broker.DeclareQueue("all"); err != nil {
+		t.Fatal(err)
+	}
+	if err := broker.BindQueue("all", "#", testExchange); err != nil {
+		t.Fatal(err)
+	}
+
+	deliveries, err := broker.Consume("all")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return deliveries
+}
+
+// memoryOutbox is dataprovider.OutboxStore keeping the events in memory, the transactions are ignored.
+type memoryOutbox struct {
+	mu      sync.Mutex
+	events  map[int64]*model.Event
+	claimed map[int64]time.Time
+}
+
+func newMemoryOutbox(routingKeys ...string) *memoryOutbox {
+	s := &memoryOutbox{
+		events:  make(map[int64]*model.Event, len(routingKeys)),
+		claimed: make(map[int64]time.Time),
+	}
+
+	for k, key := range routingKeys {
+		id := int64(k + 1)
+		s.events[id] = &model.Event{
+			ID:         id,
+			EventID:    fmt.Sprintf("event-%d", id),
+			RoutingKey: key,
+			Body:       []byte(`{}`),
+		}
+	}
+
+	return s
+}
+
+func (s *memoryOutbox) WithTx(*dataprovider.Tx) dataprovider.OutboxStore {
+	return s
+}
+
+func (s *memoryOutbox) GetListByFilter(_ context.Context, filter *dataprovider.OutboxFilter) ([]*model.Event, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var result = make([]*model.Event, 0, len(s.events))
+	for _, id := range s.sortedIDs() {
+		if filter.Limit > 0 && uint64(len(result)) == filter.Limit {
+			break
+		}
+		if until, ok := s.claimed[id]; filter.Unclaimed && ok && until.After(time.Now()) {
+			continue
+		}
+
+		result = append(result, s.events[id])
+	}
+
+	return result, nil
+}
+
+func (s *memoryOutbox) Claim(_ context.Context, timeout time.Duration, ids ...int64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, id := range ids {
+		s.claimed[id] = time.Now().Add(timeout)
+	}
+
+	return nil
+}
+
+func (s *memoryOutbox) Release(_ context.Context, ids ...int64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, id := range ids {
+		delete(s.claimed, id)
+	}
+
+	return nil
+}
+
+func (s *memoryOutbox) Delete(_ context.Context, ids ...int64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, id := range ids {
+		delete(s.events, id)
+		delete(s.claimed, id)
+	}
+
+	return nil
+}
+
+func (s *memoryOutbox) ids() []int64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.sortedIDs()
+}
+
+func (s *memoryOutbox) isClaimed(id int64) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	until, ok := s.claimed[id]
+	return ok && until.After(time.Now())
+}
+
+// sortedIDs returns the ids of the events in the order they were written, the lock must be held.
+func (s *memoryOutbox) sortedIDs() []int64 {
+	var ids = make([]int64, 0, len(s.events))
+	for id := range s.events {
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+
+	return ids
+}
+
+// testTxer begins the transactions of the driver that does nothing, the stores of the tests ignore them.
+type testTxer struct {
+	db *sqlx.DB
+}
+
+func newTestTxer(t *testing.T) *testTxer {
+	t.Helper()
+
+	db, err := sql.Open(nopDriverName, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	return &testTxer{db: sqlx.NewDb(db, "mysql")}
+}
+
+func (txer *testTxer) New() (*dataprovider.Tx, error) {
+	tx, err := txer.db.Beginx()
+	if err != nil {
+		return nil, err
+	}
+
+	return &dataprovider.Tx{Tx: tx}, nil
+}
+
+const nopDriverName = "outbox_nop"
+
+func init() {
+	sql.Register(nopDriverName, nopDriver{})
+}
+
+type nopDriver struct{}
+
+func (nopDriver) Open(string) (driver.Conn, error) { return nopConn{}, nil }
+
+type nopConn struct{}
+
+func (nopConn) Prepare(string) (driver.Stmt, error) { return nil, driver.ErrSkip }
+func (nopConn) Close() error                        { return nil }
+func (nopConn) Begin() (driver.Tx, error)           { return nopTx{}, nil }
+
+type nopTx struct{}
+
+func (nopTx) Commit() error   { return nil }
+func (nopTx) Rollback() error { return nil }
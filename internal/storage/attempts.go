@@ -0,0 +1,162 @@
+package storage
+
+import (
+	"context"
+	"strconv"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+	uuid "github.com/satori/go.uuid"
+)
+
+// The storage keys of the attempts and the locks.
+const (
+	attemptsPrefix = "attempts:"
+	lockPrefix     = "lock:"
+	hitsPrefix     = "hits:"
+	// locksKey is the sorted set of the locked keys by the time of unlocking.
+	locksKey = "locks"
+)
+
+// AddAttempt adds the attempt to the sliding window of the key atomically, and returns the number of the attempts
+// in the window with it, the time of the previous attempt, and the member of the attempt to remove it.
+// The concurrent attempts get the distinct numbers, so only one of them can be the n-th.
+func (c *Client) AddAttempt(ctx context.Context, key string, window time.Duration) (int64, time.Time, string, error) {
+	var (
+		now         = time.Now()
+		attemptsKey = attemptsPrefix + key
+		member      = uuid.NewV4().String()
+		count       *redis.IntCmd
+		last        *redis.ZSliceCmd
+	)
+
+	_, err := c.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.ZRemRangeByScore(ctx, attemptsKey, "-inf", score(now.Add(-window)))
+		pipe.ZAdd(ctx, attemptsKey, &redis.Z{
+			Score:  float64(now.UnixNano() / int64(time.Millisecond)),
+			Member: member,
+		})
+		count = pipe.ZCard(ctx, attemptsKey)
+		last = pipe.ZRevRangeWithScores(ctx, attemptsKey, 0, 1)
+		pipe.PExpire(ctx, attemptsKey, window)
+		return nil
+	})
+	if err != nil {
+		return 0, time.Time{}, "", err
+	}
+
+	var prevTime time.Time
+	for _, z := range last.Val() {
+		if z.Member != member {
+			prevTime = time.Unix(0, int64(z.Score)*int64(time.Millisecond))
+			break
+		}
+	}
+
+	return count.Val(), prevTime, member, nil
+}
+
+// Hit counts the hit of the key in the fixed window started by the first hit,
+// and returns the number of the hits in the window and the time left of it.
+func (c *Client) Hit(ctx context.Context, key string, window time.Duration) (int64, time.Duration, error) {
+	var (
+		hitsKey = hitsPrefix + key
+		count   *redis.IntCmd
+		ttl     *redis.DurationCmd
+	)
+
+	_, err := c.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.SetNX(ctx, hitsKey, 0, window)
+		count = pipe.Incr(ctx, hitsKey)
+		ttl = pipe.PTTL(ctx, hitsKey)
+		return nil
+	})
+	if err != nil {
+		return 0, 0, err
+	}
+
+	return count.Val(), ttl.Val(), nil
+}
+
+// RemoveAttempt removes the attempt of the key by its member.
+func (c *Client) RemoveAttempt(ctx context.Context, key, member string) error {
+	return c.ZRem(ctx, attemptsPrefix+key, member).Err()
+}
+
+// ResetAttempts deletes the attempts of the key.
+func (c *Client) ResetAttempts(ctx context.Context, key string) error {
+	return c.Del(ctx, attemptsPrefix+key).Err()
+}
+
+// Lock locks the key for the duration, the attempts of the key are reset.
+func (c *Client) Lock(ctx context.Context, key string, duration time.Duration) error {
+	until := time.Now().Add(duration)
+
+	_, err := c.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.Set(ctx, lockPrefix+key, until.Unix(), duration)
+		pipe.ZAdd(ctx, locksKey, &redis.Z{
+			Score:  float64(until.Unix()),
+			Member: key,
+		})
+		pipe.Del(ctx, attemptsPrefix+key)
+		return nil
+	})
+
+	return err
+}
+
+// LockTTL returns the time left until the key is unlocked, it is 0 if the key is not locked.
+func (c *Client) LockTTL(ctx context.Context, key string) (time.Duration, error) {
+	ttl, err := c.PTTL(ctx, lockPrefix+key).Result()
+	if err != nil {
+		return 0, err
+	}
+
+	// the negative ttl means there is no lock.
+	if ttl < 0 {
+		return 0, nil
+	}
+
+	return ttl, nil
+}
+
+// Unlock unlocks the key and resets its attempts.
+func (c *Client) Unlock(ctx context.Context, key string) error {
+	_, err := c.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.Del(ctx, lockPrefix+key, attemptsPrefix+key)
+		pipe.ZRem(ctx, locksKey, key)
+		return nil
+	})
+
+	return err
+}
+
+// Locks returns the locked keys with the time of unlocking.
+func (c *Client) Locks(ctx context.Context) (map[string]time.Time, error) {
+	var locks *redis.ZSliceCmd
+
+	_, err := c.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.ZRemRangeByScore(ctx, locksKey, "-inf", strconv.FormatInt(time.Now().Unix(), 10))
+		locks = pipe.ZRangeWithScores(ctx, locksKey, 0, -1)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var result = make(map[string]time.Time, len(locks.Val()))
+	for _, z := range locks.Val() {
+		key, ok := z.Member.(string)
+		if !ok {
+			continue
+		}
+		result[key] = time.Unix(int64(z.Score), 0)
+	}
+
+	return result, nil
+}
+
+// score returns the score of the attempt made at t, in milliseconds.
+func score(t time.Time) string {
+	return strconv.FormatInt(t.UnixNano()/int64(time.Millisecond), 10)
+}
@@ -0,0 +1,103 @@
+package storage
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"strconv"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+)
+
+// oneTimeTokenPrefix is the prefix of the one-time tokens storage keys, the purpose of the token follows it.
+const oneTimeTokenPrefix = "one_time:"
+
+// oneTimeUserPrefix is the prefix of the sets of the keys of the user's one-time tokens by purpose.
+const oneTimeUserPrefix = "one_time_user:"
+
+// NewOneTimeToken creates the random token of the user, which can be spent once before it expires.
+// Only the hash of the token is stored, so the storage dump does not give the tokens away.
+func (c *Client) NewOneTimeToken(ctx context.Context, purpose string, userID int64, ttl time.Duration) (string, error) {
+	var secret = make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		return "", err
+	}
+
+	var (
+		token   = base64.RawURLEncoding.EncodeToString(secret)
+		key     = oneTimeTokenKey(purpose, token)
+		userKey = oneTimeUserKey(purpose, userID)
+	)
+
+	// the set lives as long as the last token of the user, so the tokens can be revoked all at once.
+	_, err := c.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.Set(ctx, key, userID, ttl)
+		pipe.SAdd(ctx, userKey, key)
+		pipe.Expire(ctx, userKey, ttl)
+		return nil
+	})
+	if err != nil {
+		return "", err
+	}
+
+	return token, nil
+}
+
+// RevokeOneTimeTokens deletes all the unspent tokens of the user with the purpose.
+func (c *Client) RevokeOneTimeTokens(ctx context.Context, purpose string, userID int64) error {
+	userKey := oneTimeUserKey(purpose, userID)
+
+	keys, err := c.SMembers(ctx, userKey).Result()
+	if err != nil {
+		return err
+	}
+
+	return c.Del(ctx, append(keys, userKey)...).Err()
+}
+
+// SpendOneTimeToken deletes the token and returns the id of its user, it is 0 if the token is spent or expired.
+func (c *Client) SpendOneTimeToken(ctx context.Context, purpose string, token string) (int64, error) {
+	var (
+		key    = oneTimeTokenKey(purpose, token)
+		userID *redis.StringCmd
+	)
+
+	_, err := c.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		userID = pipe.Get(ctx, key)
+		pipe.Del(ctx, key)
+		return nil
+	})
+	if err == redis.Nil {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+
+	return strconv.ParseInt(userID.Val(), 10, 64)
+}
+
+// OneTimeTokenUser returns the id of the user of the token without spending it, it is 0 if the token is spent or expired.
+func (c *Client) OneTimeTokenUser(ctx context.Context, purpose string, token string) (int64, error) {
+	userID, err := c.Get(ctx, oneTimeTokenKey(purpose, token)).Int64()
+	if err == redis.Nil {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+
+	return userID, nil
+}
+
+func oneTimeUserKey(purpose string, userID int64) string {
+	return oneTimeUserPrefix + purpose + ":" + strconv.FormatInt(userID, 10)
+}
+
+func oneTimeTokenKey(purpose, token string) string {
+	hash := sha256.Sum256([]byte(token))
+	return oneTimeTokenPrefix + purpose + ":" + hex.EncodeToString(hash[:])
+}
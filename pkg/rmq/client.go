@@ -1,99 +1,484 @@
 package rabbitmq
 
 import (
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
 	"github.com/streadway/amqp"
 )
 
+var (
+	ErrClosed         = errors.New("rmq client is closed")
+	ErrNotConnected   = errors.New("rmq client is not connected")
+	ErrConfirmTimeout = errors.New("rmq publish is not confirmed in time")
+	ErrNotConfirmed   = errors.New("rmq publish is rejected by the broker")
+)
+
+// MessageBroker publishes and consumes the messages.
+// The deliveries must be acknowledged with Ack, or rejected with Nack or Reject.
+// The rejected deliveries without requeue go to the dead letter queue if it is configured.
 type MessageBroker interface {
 	Produce(x string, key string, body []byte) error
+	Publish(x string, key string, msg amqp.Publishing) error
 	Consume(qname string) (<-chan amqp.Delivery, error)
 	DeclareQueue(name string) (amqp.Queue, error)
 	DeclareExchange(name string, xType string) error
+	BindQueue(name string, key string, x string) error
+	Close() error
 }
 
+// Client is the MessageBroker which restores the lost connection, declares the topology again
+// and resumes the consumers. The published messages are confirmed by the broker.
 type Client struct {
-	conn   *amqp.Connection
-	ch     *amqp.Channel
+	cfg    Config
 	logger Logger
+
+	mu        sync.RWMutex
+	conn      *amqp.Connection
+	publisher *publisher
+	subCh     *amqp.Channel
+	ready     chan struct{}
+	topology  topology
+
+	// pubMu serializes the publishing to match the confirmations.
+	pubMu sync.Mutex
+
+	done      chan struct{}
+	closeOnce sync.Once
+}
+
+// publisher is the channel in confirm mode with the tag of the last published message.
+type publisher struct {
+	ch       *amqp.Channel
+	confirms chan amqp.Confirmation
+	tag      uint64
+}
+
+// topology is declared again after reconnection.
+type topology struct {
+	exchanges []exchange
+	queues    []string
+	bindings  []binding
+}
+
+type exchange struct {
+	name  string
+	xType string
+}
+
+type binding struct {
+	queue    string
+	key      string
+	exchange string
 }
 
 func NewClient(cfg Config, logger Logger) (MessageBroker, error) {
-	conn, err := amqp.Dial(cfg.URL)
+	c := &Client{
+		cfg:    cfg.withDefaults(),
+		logger: logger,
+		ready:  make(chan struct{}),
+		done:   make(chan struct{}),
+	}
+
+	closed, err := c.connect()
+	if err != nil {
+		logger.WithErr(err).Error("failed to connect to RMQ")
+		return nil, err
+	}
+
+	go c.reconnect(closed)
+
+	return c, nil
+}
+
+// connect opens the connection and the channels, and declares the known topology.
+// The returned channel is notified when the connection is lost.
+func (c *Client) connect() (chan *amqp.Error, error) {
+	conn, err := amqp.Dial(c.cfg.URL)
 	if err != nil {
-		logger.WithErr(err).Fatal("failed to connect to RMQ")
 		return nil, err
 	}
 
-	ch, err := conn.Channel()
+	pubCh, err := conn.Channel()
 	if err != nil {
-		logger.WithErr(err).Fatal("failed to open a channel")
+		conn.Close()
+		return nil, fmt.Errorf("open publishing channel: %w", err)
+	}
+
+	if err := pubCh.Confirm(false); err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("put channel in confirm mode: %w", err)
+	}
+
+	// the buffer keeps the late confirmations of the timed out messages until the next publishing.
+	confirms := pubCh.NotifyPublish(make(chan amqp.Confirmation, 64))
+
+	subCh, err := conn.Channel()
+	if err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("open consuming channel: %w", err)
+	}
+
+	if err := subCh.Qos(c.cfg.PrefetchCount, 0, false); err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("set qos: %w", err)
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	select {
+	case <-c.done:
+		conn.Close()
+		return nil, ErrClosed
+	default:
+	}
+
+	if err := c.declareTopology(pubCh); err != nil {
+		conn.Close()
 		return nil, err
 	}
 
-	return &Client{
-		conn:   conn,
-		ch:     ch,
-		logger: logger,
-	}, nil
+	closed := conn.NotifyClose(make(chan *amqp.Error, 1))
+
+	// the channel is closed by the broker on the channel errors, the connection is reopened then.
+	for _, ch := range []*amqp.Channel{pubCh, subCh} {
+		chClosed := ch.NotifyClose(make(chan *amqp.Error, 1))
+		go func() {
+			if err, ok := <-chClosed; ok && err != nil {
+				c.logger.WithErr(err).Warn("RMQ channel is closed, reconnecting")
+				conn.Close()
+			}
+		}()
+	}
+
+	c.conn = conn
+	c.publisher = &publisher{ch: pubCh, confirms: confirms}
+	c.subCh = subCh
+	close(c.ready)
+
+	return closed, nil
+}
+
+// reconnect restores the connection each time it is lost until the client is closed.
+func (c *Client) reconnect(closed chan *amqp.Error) {
+	for {
+		select {
+		case <-c.done:
+			return
+		case err := <-closed:
+			select {
+			case <-c.done:
+				return
+			default:
+			}
+
+			// the notification is closed without the error when the connection is closed by the client.
+			if err != nil {
+				c.logger.WithErr(err).Warn("RMQ connection is lost, reconnecting")
+			} else {
+				c.logger.Warn("RMQ connection is closed, reconnecting")
+			}
+		}
+
+		c.mu.Lock()
+		c.publisher = nil
+		c.subCh = nil
+		c.ready = make(chan struct{})
+		c.mu.Unlock()
+
+		for {
+			select {
+			case <-c.done:
+				return
+			case <-time.After(c.cfg.ReconnectDelay):
+			}
+
+			var err error
+			if closed, err = c.connect(); err == nil {
+				c.logger.Info("RMQ connection is restored")
+				break
+			}
+
+			c.logger.WithErr(err).Error("failed to reconnect to RMQ")
+		}
+	}
+}
+
+func (c *Client) declareTopology(ch *amqp.Channel) error {
+	for _, x := range c.topology.exchanges {
+		if err := declareExchange(ch, x.name, x.xType); err != nil {
+			return err
+		}
+	}
+
+	for _, name := range c.topology.queues {
+		if _, err := c.declareQueue(ch, name); err != nil {
+			return err
+		}
+	}
+
+	for _, b := range c.topology.bindings {
+		if err := ch.QueueBind(b.queue, b.key, b.exchange, false, nil); err != nil {
+			return fmt.Errorf("bind queue %s to %s: %w", b.queue, b.exchange, err)
+		}
+	}
+
+	return nil
 }
 
 func (c *Client) Produce(x string, key string, body []byte) error {
-	if err := c.ch.Publish(
+	return c.Publish(x, key, amqp.Publishing{
+		ContentType: "text/plain",
+		Body:        body,
+	})
+}
+
+// Publish publishes the persistent message and waits for the broker to confirm it.
+func (c *Client) Publish(x string, key string, msg amqp.Publishing) error {
+	c.pubMu.Lock()
+	defer c.pubMu.Unlock()
+
+	c.mu.RLock()
+	p := c.publisher
+	c.mu.RUnlock()
+
+	if p == nil {
+		return ErrNotConnected
+	}
+
+	if msg.DeliveryMode == 0 {
+		msg.DeliveryMode = amqp.Persistent
+	}
+
+	if err := p.ch.Publish(
 		x,     // exchange
 		key,   // routing key
 		false, // mandatory
 		false, // immediate
-		amqp.Publishing{
-			ContentType: "text/plain",
-			Body:        body,
-		},
+		msg,
 	); err != nil {
 		c.logger.WithErr(err).Error("failed to publish a message")
 		return err
 	}
 
-	return nil
+	p.tag++
+
+	timer := time.NewTimer(c.cfg.ConfirmTimeout)
+	defer timer.Stop()
+
+	for {
+		select {
+		case confirm, ok := <-p.confirms:
+			if !ok {
+				return ErrNotConnected
+			}
+			// the confirmations of the timed out messages are skipped.
+			if confirm.DeliveryTag < p.tag {
+				continue
+			}
+			if !confirm.Ack {
+				return ErrNotConfirmed
+			}
+			return nil
+
+		case <-timer.C:
+			c.logger.WithField("exchange", x).WithField("key", key).Error("publish confirmation timed out")
+			return ErrConfirmTimeout
+		}
+	}
 }
+
+// Consume returns the deliveries of the queue, which are resumed after the reconnection.
+// The channel is closed only when the client is closed.
 func (c *Client) Consume(qname string) (<-chan amqp.Delivery, error) {
-	msgs, err := c.ch.Consume(
+	deliveries, err := c.consume(qname)
+	if err != nil {
+		c.logger.WithErr(err).Error("failed to subsribe")
+		return nil, err
+	}
+
+	var out = make(chan amqp.Delivery)
+	go c.forward(qname, deliveries, out)
+
+	return out, nil
+}
+
+func (c *Client) consume(qname string) (<-chan amqp.Delivery, error) {
+	c.mu.RLock()
+	ch := c.subCh
+	c.mu.RUnlock()
+
+	if ch == nil {
+		return nil, ErrNotConnected
+	}
+
+	return ch.Consume(
 		qname, // queue
 		"",    // consumer
-		true,  // auto ack
+		false, // auto ack
 		false, // exclusive
 		false, // no local
 		false, // no wait
 		nil,   // args
 	)
-	if err != nil {
-		c.logger.WithErr(err).Error("failed to subsribe")
-		return nil, err
+}
+
+// forward passes the deliveries to out and consumes the queue again when the connection is restored.
+func (c *Client) forward(qname string, deliveries <-chan amqp.Delivery, out chan<- amqp.Delivery) {
+	defer close(out)
+
+	for {
+		for delivery := range deliveries {
+			select {
+			case out <- delivery:
+			case <-c.done:
+				return
+			}
+		}
+
+		var err error
+		if deliveries, err = c.resume(qname); err != nil {
+			return
+		}
 	}
+}
+
+// resume waits for the connection and consumes the queue again.
+func (c *Client) resume(qname string) (<-chan amqp.Delivery, error) {
+	for {
+		c.mu.RLock()
+		ready := c.ready
+		c.mu.RUnlock()
+
+		select {
+		case <-c.done:
+			return nil, ErrClosed
+		case <-ready:
+		}
 
-	return msgs, err
+		deliveries, err := c.consume(qname)
+		if err == nil {
+			c.logger.WithField("queue", qname).Info("RMQ consumer is resumed")
+			return deliveries, nil
+		}
+
+		c.logger.WithErr(err).WithField("queue", qname).Error("failed to resume the consumer")
+
+		select {
+		case <-c.done:
+			return nil, ErrClosed
+		case <-time.After(c.cfg.ReconnectDelay):
+		}
+	}
 }
+
+// Close closes the connection, the consumed delivery channels are closed too.
 func (c *Client) Close() error {
-	return c.ch.Close()
+	var closed bool
+	c.closeOnce.Do(func() {
+		close(c.done)
+		closed = true
+	})
+	if !closed {
+		return ErrClosed
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.conn == nil || c.conn.IsClosed() {
+		return nil
+	}
+
+	return c.conn.Close()
 }
 
+// DeclareQueue declares the durable queue, and its dead letter queue if the dead letter exchange is configured.
 func (c *Client) DeclareQueue(name string) (amqp.Queue, error) {
-	q, err := c.ch.QueueDeclare(
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.publisher == nil {
+		return amqp.Queue{}, ErrNotConnected
+	}
+
+	q, err := c.declareQueue(c.publisher.ch, name)
+	if err != nil {
+		c.logger.WithErr(err).Error("failed to declare queue")
+		return q, err
+	}
+
+	c.topology.queues = appendOnce(c.topology.queues, name)
+
+	return q, nil
+}
+
+func (c *Client) declareQueue(ch *amqp.Channel, name string) (amqp.Queue, error) {
+	var args amqp.Table
+
+	if dlx := c.cfg.DeadLetterExchange; dlx != "" {
+		if err := declareExchange(ch, dlx, amqp.ExchangeDirect); err != nil {
+			return amqp.Queue{}, err
+		}
+
+		dlq := name + deadLetterSuffix
+		if _, err := ch.QueueDeclare(dlq, true, false, false, false, nil); err != nil {
+			return amqp.Queue{}, fmt.Errorf("declare queue %s: %w", dlq, err)
+		}
+
+		if err := ch.QueueBind(dlq, name, dlx, false, nil); err != nil {
+			return amqp.Queue{}, fmt.Errorf("bind queue %s to %s: %w", dlq, dlx, err)
+		}
+
+		args = amqp.Table{
+			"x-dead-letter-exchange":    dlx,
+			"x-dead-letter-routing-key": name,
+		}
+	}
+
+	q, err := ch.QueueDeclare(
 		name,  // name
 		true,  // durable
 		false, // delete when unused
 		false, // exclusive
 		false, // no-wait
-		nil,   // arguments
+		args,  // arguments
 	)
 	if err != nil {
-		c.logger.WithErr(err).Error("failed to declare exchange")
-		return q, err
+		return q, fmt.Errorf("declare queue %s: %w", name, err)
 	}
 
 	return q, nil
 }
 
 func (c *Client) DeclareExchange(name string, xType string) error {
-	if err := c.ch.ExchangeDeclare(
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.publisher == nil {
+		return ErrNotConnected
+	}
+
+	if err := declareExchange(c.publisher.ch, name, xType); err != nil {
+		c.logger.WithErr(err).Error("failed to declare exchange")
+		return err
+	}
+
+	for _, x := range c.topology.exchanges {
+		if x.name == name {
+			return nil
+		}
+	}
+	c.topology.exchanges = append(c.topology.exchanges, exchange{name: name, xType: xType})
+
+	return nil
+}
+
+func declareExchange(ch *amqp.Channel, name string, xType string) error {
+	if err := ch.ExchangeDeclare(
 		name,  // name
 		xType, // type
 		true,  // durable
@@ -102,9 +487,43 @@ func (c *Client) DeclareExchange(name string, xType string) error {
 		false, // no-wait
 		nil,   // arguments
 	); err != nil {
-		c.logger.WithErr(err).Error("failed to declare exchange")
+		return fmt.Errorf("declare exchange %s: %w", name, err)
+	}
+
+	return nil
+}
+
+// BindQueue binds the queue to the exchange with the routing key.
+func (c *Client) BindQueue(name string, key string, x string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.publisher == nil {
+		return ErrNotConnected
+	}
+
+	if err := c.publisher.ch.QueueBind(name, key, x, false, nil); err != nil {
+		c.logger.WithErr(err).Error("failed to bind queue")
 		return err
 	}
 
+	b := binding{queue: name, key: key, exchange: x}
+	for _, known := range c.topology.bindings {
+		if known == b {
+			return nil
+		}
+	}
+	c.topology.bindings = append(c.topology.bindings, b)
+
 	return nil
 }
+
+func appendOnce(names []string, name string) []string {
+	for _, known := range names {
+		if known == name {
+			return names
+		}
+	}
+
+	return append(names, name)
+}
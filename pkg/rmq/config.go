@@ -1,5 +1,39 @@
 package rabbitmq
 
+import "time"
+
+const (
+	defaultReconnectDelay = time.Second * 2
+	defaultConfirmTimeout = time.Second * 5
+
+	// deadLetterSuffix is appended to the name of the queue to name its dead letter queue.
+	deadLetterSuffix = ".dead"
+)
+
 type Config struct {
 	URL string `mapstructure:"url"`
+
+	// ReconnectDelay is the delay between the attempts to restore the lost connection.
+	ReconnectDelay time.Duration `mapstructure:"reconnect_delay"`
+
+	// ConfirmTimeout is the time to wait for the broker to confirm the published message.
+	ConfirmTimeout time.Duration `mapstructure:"confirm_timeout"`
+
+	// PrefetchCount is the number of the unacknowledged deliveries per consumer, 0 means unlimited.
+	PrefetchCount int `mapstructure:"prefetch_count"`
+
+	// DeadLetterExchange receives the rejected messages of the declared queues if it is set.
+	// Every queue gets its dead letter queue named with ".dead" suffix.
+	DeadLetterExchange string `mapstructure:"dead_letter_exchange"`
+}
+
+func (c Config) withDefaults() Config {
+	if c.ReconnectDelay <= 0 {
+		c.ReconnectDelay = defaultReconnectDelay
+	}
+	if c.ConfirmTimeout <= 0 {
+		c.ConfirmTimeout = defaultConfirmTimeout
+	}
+
+	return c
 }
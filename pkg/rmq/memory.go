@@ -0,0 +1,484 @@
+package rabbitmq
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/streadway/amqp"
+)
+
+// Memory is the in-process MessageBroker for the tests and the runs without RabbitMQ.
+// It supports direct, topic and fanout exchanges, the default exchange, the dead letter queues,
+// the prefetch count and the redelivery of the nacked messages. The messages are not persisted.
+type Memory struct {
+	cfg Config
+
+	mu        sync.Mutex
+	exchanges map[string]*memExchange
+	queues    map[string]*memQueue
+
+	done      chan struct{}
+	closeOnce sync.Once
+}
+
+type memExchange struct {
+	xType    string
+	bindings []binding
+}
+
+type memQueue struct {
+	name     string
+	messages []amqp.Delivery
+
+	// deadLetterExchange receives the rejected messages with the queue name as the routing key.
+	deadLetterExchange string
+
+	consumers int
+
+	// changed is closed and replaced when the messages are added or acknowledged.
+	changed chan struct{}
+}
+
+// memConsumer acknowledges the deliveries of the queue it got, like the channel does.
+type memConsumer struct {
+	broker  *Memory
+	queue   *memQueue
+	tag     string
+	lastTag uint64
+	unacked map[uint64]amqp.Delivery
+}
+
+func NewMemory(cfg Config) MessageBroker {
+	return &Memory{
+		cfg:       cfg,
+		exchanges: make(map[string]*memExchange),
+		queues:    make(map[string]*memQueue),
+		done:      make(chan struct{}),
+	}
+}
+
+func (m *Memory) Produce(x string, key string, body []byte) error {
+	return m.Publish(x, key, amqp.Publishing{
+		ContentType: "text/plain",
+		Body:        body,
+	})
+}
+
+// Publish routes the message to the bound queues, the message without the queues is dropped.
+func (m *Memory) Publish(x string, key string, msg amqp.Publishing) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.isClosed() {
+		return ErrClosed
+	}
+
+	return m.route(x, key, amqp.Delivery{
+		Headers:         msg.Headers,
+		ContentType:     msg.ContentType,
+		ContentEncoding: msg.ContentEncoding,
+		DeliveryMode:    msg.DeliveryMode,
+		Priority:        msg.Priority,
+		CorrelationId:   msg.CorrelationId,
+		ReplyTo:         msg.ReplyTo,
+		Expiration:      msg.Expiration,
+		MessageId:       msg.MessageId,
+		Timestamp:       msg.Timestamp,
+		Type:            msg.Type,
+		UserId:          msg.UserId,
+		AppId:           msg.AppId,
+		Body:            msg.Body,
+	})
+}
+
+// route puts the message to the queues matching the routing key, the lock must be held.
+func (m *Memory) route(x string, key string, delivery amqp.Delivery) error {
+	delivery.Exchange = x
+	delivery.RoutingKey = key
+
+	if x == "" {
+		if q, ok := m.queues[key]; ok {
+			q.push(delivery)
+		}
+		return nil
+	}
+
+	exchange, ok := m.exchanges[x]
+	if !ok {
+		return fmt.Errorf("exchange %s is not found", x)
+	}
+
+	var routed = make(map[string]bool)
+	for _, b := range exchange.bindings {
+		if routed[b.queue] || !matchKey(exchange.xType, b.key, key) {
+			continue
+		}
+
+		if q, ok := m.queues[b.queue]; ok {
+			q.push(delivery)
+			routed[b.queue] = true
+		}
+	}
+
+	return nil
+}
+
+// Consume returns the deliveries of the queue, the channel is closed when the broker is closed.
+func (m *Memory) Consume(qname string) (<-chan amqp.Delivery, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.isClosed() {
+		return nil, ErrClosed
+	}
+
+	q, ok := m.queues[qname]
+	if !ok {
+		return nil, fmt.Errorf("queue %s is not found", qname)
+	}
+
+	q.consumers++
+
+	c := &memConsumer{
+		broker:  m,
+		queue:   q,
+		tag:     fmt.Sprintf("memory-%s-%d", qname, q.consumers),
+		unacked: make(map[uint64]amqp.Delivery),
+	}
+
+	var out = make(chan amqp.Delivery)
+	go c.run(out)
+
+	return out, nil
+}
+
+// DeclareQueue declares the queue, and its dead letter queue if the dead letter exchange is configured.
+func (m *Memory) DeclareQueue(name string) (amqp.Queue, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.isClosed() {
+		return amqp.Queue{}, ErrClosed
+	}
+
+	q := m.declareQueue(name)
+
+	if dlx := m.cfg.DeadLetterExchange; dlx != "" && q.deadLetterExchange == "" {
+		if err := m.declareExchange(dlx, amqp.ExchangeDirect); err != nil {
+			return amqp.Queue{}, err
+		}
+
+		dlq := name + deadLetterSuffix
+		m.declareQueue(dlq)
+		m.bindQueue(dlq, name, dlx)
+
+		q.deadLetterExchange = dlx
+	}
+
+	return amqp.Queue{
+		Name:      q.name,
+		Messages:  len(q.messages),
+		Consumers: q.consumers,
+	}, nil
+}
+
+func (m *Memory) declareQueue(name string) *memQueue {
+	q, ok := m.queues[name]
+	if !ok {
+		q = &memQueue{
+			name:    name,
+			changed: make(chan struct{}),
+		}
+		m.queues[name] = q
+	}
+
+	return q
+}
+
+func (m *Memory) DeclareExchange(name string, xType string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.isClosed() {
+		return ErrClosed
+	}
+
+	return m.declareExchange(name, xType)
+}
+
+func (m *Memory) declareExchange(name string, xType string) error {
+	switch xType {
+	case amqp.ExchangeDirect, amqp.ExchangeTopic, amqp.ExchangeFanout:
+	default:
+		return fmt.Errorf("exchange type %s is not supported", xType)
+	}
+
+	if exchange, ok := m.exchanges[name]; ok {
+		if exchange.xType != xType {
+			return fmt.Errorf("exchange %s is already declared with type %s", name, exchange.xType)
+		}
+		return nil
+	}
+
+	m.exchanges[name] = &memExchange{xType: xType}
+
+	return nil
+}
+
+// BindQueue binds the queue to the exchange with the routing key.
+func (m *Memory) BindQueue(name string, key string, x string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.isClosed() {
+		return ErrClosed
+	}
+
+	if _, ok := m.queues[name]; !ok {
+		return fmt.Errorf("queue %s is not found", name)
+	}
+	if _, ok := m.exchanges[x]; !ok {
+		return fmt.Errorf("exchange %s is not found", x)
+	}
+
+	m.bindQueue(name, key, x)
+
+	return nil
+}
+
+func (m *Memory) bindQueue(name string, key string, x string) {
+	exchange := m.exchanges[x]
+
+	b := binding{queue: name, key: key, exchange: x}
+	for _, known := range exchange.bindings {
+		if known == b {
+			return
+		}
+	}
+
+	exchange.bindings = append(exchange.bindings, b)
+}
+
+// Close stops the consumers, the unacknowledged messages are lost.
+func (m *Memory) Close() error {
+	var closed bool
+	m.closeOnce.Do(func() {
+		close(m.done)
+		closed = true
+	})
+	if !closed {
+		return ErrClosed
+	}
+
+	return nil
+}
+
+func (m *Memory) isClosed() bool {
+	select {
+	case <-m.done:
+		return true
+	default:
+		return false
+	}
+}
+
+func (q *memQueue) push(delivery amqp.Delivery) {
+	q.messages = append(q.messages, delivery)
+	q.signal()
+}
+
+func (q *memQueue) signal() {
+	close(q.changed)
+	q.changed = make(chan struct{})
+}
+
+// run sends the messages of the queue to out while the prefetch count allows.
+func (c *memConsumer) run(out chan<- amqp.Delivery) {
+	defer close(out)
+
+	m := c.broker
+
+	for {
+		m.mu.Lock()
+		delivery, ok := c.next()
+		changed := c.queue.changed
+		m.mu.Unlock()
+
+		if !ok {
+			select {
+			case <-m.done:
+				return
+			case <-changed:
+				continue
+			}
+		}
+
+		select {
+		case <-m.done:
+			return
+		case out <- delivery:
+		}
+	}
+}
+
+// next takes the first message of the queue, the lock must be held.
+func (c *memConsumer) next() (amqp.Delivery, bool) {
+	q := c.queue
+
+	if len(q.messages) == 0 {
+		return amqp.Delivery{}, false
+	}
+	if prefetch := c.broker.cfg.PrefetchCount; prefetch > 0 && len(c.unacked) >= prefetch {
+		return amqp.Delivery{}, false
+	}
+
+	delivery := q.messages[0]
+	q.messages = q.messages[1:]
+
+	c.lastTag++
+	delivery.Acknowledger = c
+	delivery.ConsumerTag = c.tag
+	delivery.DeliveryTag = c.lastTag
+	c.unacked[c.lastTag] = delivery
+
+	return delivery, true
+}
+
+func (c *memConsumer) Ack(tag uint64, multiple bool) error {
+	m := c.broker
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	_, err := c.settle(tag, multiple)
+
+	return err
+}
+
+// Nack returns the messages to the head of the queue if requeue is set, otherwise they are dead-lettered.
+func (c *memConsumer) Nack(tag uint64, multiple bool, requeue bool) error {
+	m := c.broker
+	q := c.queue
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	deliveries, err := c.settle(tag, multiple)
+	if err != nil {
+		return err
+	}
+
+	if requeue {
+		for k := range deliveries {
+			deliveries[k].Redelivered = true
+		}
+		q.messages = append(deliveries, q.messages...)
+		q.signal()
+
+		return nil
+	}
+
+	if q.deadLetterExchange == "" {
+		return nil
+	}
+
+	for _, delivery := range deliveries {
+		delivery.Headers = withDeath(delivery.Headers, q.name, delivery.Exchange, delivery.RoutingKey)
+
+		// the dead letter exchange is declared with the queue.
+		_ = m.route(q.deadLetterExchange, q.name, delivery)
+	}
+
+	return nil
+}
+
+func (c *memConsumer) Reject(tag uint64, requeue bool) error {
+	return c.Nack(tag, false, requeue)
+}
+
+// settle removes the delivery with the tag, or all up to it if multiple is set, from the unacknowledged ones
+// and returns them in order, the lock must be held.
+func (c *memConsumer) settle(tag uint64, multiple bool) ([]amqp.Delivery, error) {
+	var tags []uint64
+	if multiple {
+		for t := range c.unacked {
+			if t <= tag {
+				tags = append(tags, t)
+			}
+		}
+		sort.Slice(tags, func(i, j int) bool { return tags[i] < tags[j] })
+	} else {
+		if _, ok := c.unacked[tag]; !ok {
+			return nil, fmt.Errorf("unknown delivery tag %d", tag)
+		}
+		tags = []uint64{tag}
+	}
+
+	var deliveries = make([]amqp.Delivery, 0, len(tags))
+	for _, t := range tags {
+		deliveries = append(deliveries, c.unacked[t])
+		delete(c.unacked, t)
+	}
+
+	// the prefetch count may let the consumer take the next message now.
+	c.queue.signal()
+
+	return deliveries, nil
+}
+
+// matchKey reports whether the routing key of the message matches the binding key of the exchange type.
+func matchKey(xType string, bindingKey string, key string) bool {
+	switch xType {
+	case amqp.ExchangeFanout:
+		return true
+	case amqp.ExchangeTopic:
+		return matchTopic(strings.Split(bindingKey, "."), strings.Split(key, "."))
+	default:
+		return bindingKey == key
+	}
+}
+
+// matchTopic matches the words of the key, where * is exactly one word and # is zero or more words.
+func matchTopic(pattern []string, words []string) bool {
+	if len(pattern) == 0 {
+		return len(words) == 0
+	}
+
+	switch pattern[0] {
+	case "#":
+		for k := 0; k <= len(words); k++ {
+			if matchTopic(pattern[1:], words[k:]) {
+				return true
+			}
+		}
+		return false
+	case "*":
+		return len(words) > 0 && matchTopic(pattern[1:], words[1:])
+	default:
+		return len(words) > 0 && pattern[0] == words[0] && matchTopic(pattern[1:], words[1:])
+	}
+}
+
+// withDeath adds the x-death header like RabbitMQ does for the rejected message.
+func withDeath(headers amqp.Table, queue string, x string, key string) amqp.Table {
+	var result = make(amqp.Table, len(headers)+1)
+	for k, v := range headers {
+		result[k] = v
+	}
+
+	result["x-death"] = []interface{}{
+		amqp.Table{
+			"queue":        queue,
+			"reason":       "rejected",
+			"exchange":     x,
+			"routing-keys": []interface{}{key},
+			"time":         time.Now(),
+			"count":        int64(1),
+		},
+	}
+
+	return result
+}
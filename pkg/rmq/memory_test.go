@@ -0,0 +1,265 @@
+package rabbitmq
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/streadway/amqp"
+)
+
+const (
+	testTimeout = time.Second
+	// testQuiet is the time to wait to make sure no message is delivered.
+	testQuiet = time.Millisecond * 50
+)
+
+func TestMatchKey(t *testing.T) {
+	tests := []struct {
+		name       string
+		xType      string
+		bindingKey string
+		key        string
+		want       bool
+	}{
+		{"direct equal", amqp.ExchangeDirect, "stops", "stops", true},
+		{"direct differs", amqp.ExchangeDirect, "stops", "buses", false},
+		{"fanout ignores key", amqp.ExchangeFanout, "stops", "buses", true},
+		{"topic exact", amqp.ExchangeTopic, "stop.created", "stop.created", true},
+		{"topic star is one word", amqp.ExchangeTopic, "stop.*", "stop.updated", true},
+		{"topic star is not zero words", amqp.ExchangeTopic, "stop.*", "stop", false},
+		{"topic star is not two words", amqp.ExchangeTopic, "stop.*", "stop.updated.late", false},
+		{"topic hash is zero words", amqp.ExchangeTopic, "stop.#", "stop", true},
+		{"topic hash is many words", amqp.ExchangeTopic, "stop.#", "stop.updated.late", true},
+		{"topic hash in front", amqp.ExchangeTopic, "#.deleted", "bus.deleted", true},
+		{"topic hash alone", amqp.ExchangeTopic, "#", "city.created", true},
+		{"topic other entity", amqp.ExchangeTopic, "stop.*", "bus.updated", false},
+		{"topic star in the middle", amqp.ExchangeTopic, "route.*.late", "route.updated.late", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := matchKey(tt.xType, tt.bindingKey, tt.key); got != tt.want {
+				t.Errorf("matchKey(%s, %q, %q) = %v, want %v", tt.xType, tt.bindingKey, tt.key, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMemoryTopicRouting(t *testing.T) {
+	m := NewMemory(Config{})
+	defer m.Close()
+
+	if err := m.DeclareExchange("events", amqp.ExchangeTopic); err != nil {
+		t.Fatal(err)
+	}
+
+	bindings := map[string]string{
+		"stops":   "stop.*",
+		"deleted": "#.deleted",
+	}
+	for queue, key := range bindings {
+		declareBound(t, m, queue, key, "events")
+	}
+
+	for _, key := range []string{"stop.created", "bus.deleted", "stop.deleted", "city.created"} {
+		if err := m.Produce("events", key, []byte(key)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		queue string
+		want  []string
+	}{
+		{"stops", []string{"stop.created", "stop.deleted"}},
+		{"deleted", []string{"bus.deleted", "stop.deleted"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.queue, func(t *testing.T) {
+			deliveries := consume(t, m, tt.queue)
+
+			var got = make([]string, 0, len(tt.want))
+			for range tt.want {
+				d := receive(t, deliveries)
+				got = append(got, string(d.Body))
+				if err := d.Ack(false); err != nil {
+					t.Fatal(err)
+				}
+			}
+			expectNone(t, deliveries)
+
+			if strings.Join(got, ",") != strings.Join(tt.want, ",") {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMemoryPrefetch(t *testing.T) {
+	m := NewMemory(Config{PrefetchCount: 1})
+	defer m.Close()
+
+	declareBound(t, m, "stops", "", "")
+	deliveries := consume(t, m, "stops")
+
+	for _, body := range []string{"first", "second"} {
+		if err := m.Produce("", "stops", []byte(body)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	first := receive(t, deliveries)
+	if string(first.Body) != "first" {
+		t.Fatalf("got %s, want first", first.Body)
+	}
+
+	// the unacknowledged first message holds the only prefetch slot.
+	expectNone(t, deliveries)
+
+	if err := first.Ack(false); err != nil {
+		t.Fatal(err)
+	}
+
+	if second := receive(t, deliveries); string(second.Body) != "second" {
+		t.Fatalf("got %s, want second", second.Body)
+	}
+}
+
+func TestMemoryNack(t *testing.T) {
+	tests := []struct {
+		name               string
+		deadLetterExchange string
+		requeue            bool
+		// wantQueue is the queue the nacked message goes to, empty means it is dropped.
+		wantQueue string
+	}{
+		{"requeue", "dlx", true, "stops"},
+		{"dead letter", "dlx", false, "stops" + deadLetterSuffix},
+		{"drop without dead letter exchange", "", false, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := NewMemory(Config{DeadLetterExchange: tt.deadLetterExchange})
+			defer m.Close()
+
+			declareBound(t, m, "stops", "", "")
+			deliveries := consume(t, m, "stops")
+
+			if err := m.Produce("", "stops", []byte("stop")); err != nil {
+				t.Fatal(err)
+			}
+
+			d := receive(t, deliveries)
+			if d.Redelivered {
+				t.Fatal("the first delivery is redelivered")
+			}
+			if err := d.Nack(false, tt.requeue); err != nil {
+				t.Fatal(err)
+			}
+
+			switch tt.wantQueue {
+			case "":
+				expectNone(t, deliveries)
+
+			case "stops":
+				redelivered := receive(t, deliveries)
+				if !redelivered.Redelivered || string(redelivered.Body) != "stop" {
+					t.Errorf("got %s redelivered %v, want stop redelivered", redelivered.Body, redelivered.Redelivered)
+				}
+
+			default:
+				expectNone(t, deliveries)
+
+				dead := receive(t, consume(t, m, tt.wantQueue))
+				if string(dead.Body) != "stop" {
+					t.Errorf("got %s, want stop", dead.Body)
+				}
+				if _, ok := dead.Headers["x-death"]; !ok {
+					t.Error("the dead-lettered message has no x-death header")
+				}
+			}
+		})
+	}
+}
+
+func TestMemoryClose(t *testing.T) {
+	m := NewMemory(Config{})
+
+	declareBound(t, m, "stops", "", "")
+	deliveries := consume(t, m, "stops")
+
+	if err := m.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	select {
+	case _, ok := <-deliveries:
+		if ok {
+			t.Fatal("got the delivery after close")
+		}
+	case <-time.After(testTimeout):
+		t.Fatal("the deliveries are not closed")
+	}
+
+	if err := m.Produce("", "stops", []byte("stop")); err != ErrClosed {
+		t.Errorf("got %v, want %v", err, ErrClosed)
+	}
+	if err := m.Close(); err != ErrClosed {
+		t.Errorf("got %v, want %v", err, ErrClosed)
+	}
+}
+
+// declareBound declares the queue and binds it to the exchange if x is set.
+func declareBound(t *testing.T, m MessageBroker, queue, key, x string) {
+	t.Helper()
+
+	if _, err := m.DeclareQueue(queue); err != nil {
+		t.Fatal(err)
+	}
+	if x == "" {
+		return
+	}
+	if err := m.BindQueue(queue, key, x); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func consume(t *testing.T, m MessageBroker, queue string) <-chan amqp.Delivery {
+	t.Helper()
+
+	deliveries, err := m.Consume(queue)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return deliveries
+}
+
+func receive(t *testing.T, deliveries <-chan amqp.Delivery) amqp.Delivery {
+	t.Helper()
+
+	select {
+	case d, ok := <-deliveries:
+		if !ok {
+			t.Fatal("the deliveries are closed")
+		}
+		return d
+	case <-time.After(testTimeout):
+		t.Fatal("no delivery")
+	}
+
+	return amqp.Delivery{}
+}
+
+func expectNone(t *testing.T, deliveries <-chan amqp.Delivery) {
+	t.Helper()
+
+	select {
+	case d := <-deliveries:
+		t.Fatalf("got unexpected delivery %s", d.Body)
+	case <-time.After(testQuiet):
+	}
+}